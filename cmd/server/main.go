@@ -2,23 +2,46 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/raufhm/vfc/internal/auth"
+	"github.com/raufhm/vfc/internal/certs"
 	"github.com/raufhm/vfc/internal/config"
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/gql"
 	"github.com/raufhm/vfc/internal/handler"
+	"github.com/raufhm/vfc/internal/health"
 	"github.com/raufhm/vfc/internal/logger"
+	"github.com/raufhm/vfc/internal/metrics"
+	"github.com/raufhm/vfc/internal/outbox"
 	"github.com/raufhm/vfc/internal/queue"
+	"github.com/raufhm/vfc/internal/ratelimit"
 	"github.com/raufhm/vfc/internal/repository"
+	"github.com/raufhm/vfc/internal/rpc"
 	"github.com/raufhm/vfc/internal/service"
+	"github.com/raufhm/vfc/internal/stream"
+	"github.com/raufhm/vfc/internal/tracing"
+	"github.com/raufhm/vfc/internal/webhook"
 	"github.com/raufhm/vfc/internal/worker"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":    tls.NoClientCert,
+	"request": tls.VerifyClientCertIfGiven,
+	"require": tls.RequireAndVerifyClientCert,
+}
+
 func main() {
 	log, err := logger.New(true)
 	if err != nil {
@@ -36,13 +59,46 @@ func main() {
 
 	log.Info("Configuration loaded",
 		zap.String("server_port", cfg.Server.Port),
+		zap.String("grpc_port", cfg.GRPC.Port),
 		zap.Int("worker_count", cfg.Worker.Count),
+		zap.String("queue_driver", cfg.Queue.Driver),
 		zap.Int("queue_buffer_size", cfg.Queue.BufferSize))
 
+	tracerProvider, err := tracing.Setup(tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		FilePath:    cfg.Tracing.FilePath,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
+
 	repo := repository.NewInMemoryRepository()
 	log.Info("Repository initialized")
 
-	q := queue.NewInMemoryQueue(cfg.Queue.BufferSize, log)
+	var q queue.QueueProvider
+	switch cfg.Queue.Driver {
+	case "rabbitmq":
+		q = queue.NewRabbitMQQueue(queue.RabbitMQConfig{
+			URL:        cfg.RabbitMQ.URL,
+			Exchange:   cfg.RabbitMQ.Exchange,
+			Queue:      cfg.RabbitMQ.Queue,
+			Prefetch:   cfg.RabbitMQ.Prefetch,
+			MaxRetries: cfg.RabbitMQ.MaxRetries,
+		}, log)
+	case "kafka":
+		q = queue.NewKafkaQueue(queue.KafkaConfig{
+			Brokers:    cfg.Kafka.Brokers,
+			Topic:      cfg.Kafka.Topic,
+			GroupID:    cfg.Kafka.GroupID,
+			MaxRetries: cfg.Kafka.MaxRetries,
+		}, log)
+	case "memory":
+		q = queue.NewInMemoryQueue(cfg.Queue.BufferSize, log)
+	default:
+		log.Fatal("Unknown queue driver", zap.String("driver", cfg.Queue.Driver))
+	}
 	if err := q.Connect(); err != nil {
 		log.Fatal("Failed to connect to queue", zap.Error(err))
 	}
@@ -50,11 +106,185 @@ func main() {
 	svc := service.NewProductService(repo, q)
 	log.Info("Service initialized")
 
-	pool := worker.NewPool(cfg.Worker.Count, q, repo, log)
+	m := metrics.New()
+	m.WatchRepository(repo.Count)
+	if mq, ok := q.(*queue.InMemoryQueue); ok {
+		m.WatchQueue(mq.Len, mq.Cap)
+	}
+
+	// With coalescing, workers consume through the coalescer while producers
+	// keep enqueuing to q directly.
+	consumer := q
+	var coalescer *queue.Coalescer
+	if cfg.Coalesce.Enabled {
+		coalescer = queue.NewCoalescer(q, time.Duration(cfg.Coalesce.WindowMs)*time.Millisecond, log)
+		coalescer.OnCoalesce(m)
+		m.WatchCoalescer(coalescer.Held)
+		consumer = coalescer
+	}
+
+	pool := worker.NewPool(cfg.Worker.Count, consumer, repo, log)
+	pool.RestartBackoff(
+		time.Duration(cfg.Worker.RestartBackoffMs)*time.Millisecond,
+		time.Duration(cfg.Worker.RestartMaxBackoff)*time.Second)
+	pool.EventTimeout(time.Duration(cfg.Worker.EventTimeoutMs) * time.Millisecond)
+	pool.Observe(m)
+	m.WatchPool(pool.Size, pool.Running)
+
+	var autoscaler *worker.Autoscaler
+	if ac := cfg.Autoscale; ac.Enabled {
+		var depth func() int
+		if mq, ok := q.(*queue.InMemoryQueue); ok {
+			depth = mq.Len
+		}
+		autoscaler = worker.NewAutoscaler(pool, depth, worker.AutoscaleConfig{
+			Min:               ac.MinWorkers,
+			Max:               ac.MaxWorkers,
+			Interval:          time.Duration(ac.Interval) * time.Second,
+			TargetQueueDepth:  ac.TargetQueueDepth,
+			TargetLatency:     time.Duration(ac.TargetLatencyMs) * time.Millisecond,
+			ScaleUpCooldown:   time.Duration(ac.ScaleUpCooldown) * time.Second,
+			ScaleDownCooldown: time.Duration(ac.ScaleDownCooldown) * time.Second,
+		}, log)
+		autoscaler.OnScale(m)
+		pool.Observe(autoscaler)
+	}
+
+	var relay *outbox.Relay
+	var sink outbox.Sink
+	if cfg.Outbox.Enabled {
+		sink, err = newOutboxSink(cfg)
+		if err != nil {
+			log.Fatal("Failed to create outbox sink", zap.Error(err))
+		}
+		pool.UseOutbox(repo)
+		relay = outbox.NewRelay(repo, sink,
+			time.Duration(cfg.Outbox.PollIntervalMs)*time.Millisecond,
+			cfg.Outbox.BatchSize, log)
+		relay.MaxAttempts(cfg.Outbox.MaxAttempts)
+		relay.Start()
+	}
+
+	webhooks := webhook.NewStore(cfg.Webhook.LogSize)
+	dispatcher := webhook.NewDispatcher(webhooks,
+		cfg.Webhook.Workers,
+		cfg.Webhook.MaxAttempts,
+		time.Duration(cfg.Webhook.Timeout)*time.Second,
+		time.Duration(cfg.Webhook.RetryDelayMs)*time.Millisecond,
+		log)
+	dispatcher.Start()
+	pool.OnSave(dispatcher.Notify)
+
+	broker := stream.NewBroker(cfg.Stream.BufferSize, cfg.Stream.HistorySize, log)
+	pool.OnSave(broker.Publish)
+
+	if coalescer != nil {
+		coalescer.Start()
+	}
 	pool.Start()
+	if autoscaler != nil {
+		autoscaler.Start()
+	}
+	if cfg.Shutdown.SpillFile != "" {
+		replaySpilledEvents(cfg.Shutdown.SpillFile, q, log)
+	}
+
+	// Liveness only covers what a restart would fix; dependencies that come
+	// back on their own belong to readiness.
+	checks := health.NewRegistry(time.Duration(cfg.Health.CheckTimeout) * time.Millisecond)
+	checks.AddLiveness("workers", health.WorkersCheck(pool.Running, pool.Size))
+	checks.AddReadiness("repository", health.PingCheck(repo))
+	if pinger, ok := q.(health.Pinger); ok {
+		checks.AddReadiness("queue", health.PingCheck(pinger))
+	}
+	if mq, ok := q.(*queue.InMemoryQueue); ok {
+		checks.AddReadiness("queue_saturation", health.SaturationCheck(mq.Len, mq.Cap, cfg.Health.QueueSaturation))
+	}
 
 	productHandler := handler.NewProductHandler(svc, log)
-	router := handler.SetupRouter(productHandler, log)
+	webhookHandler := handler.NewWebhookHandler(webhooks, log)
+	workerHandler := handler.NewWorkerHandler(pool, cfg.Worker.MaxCount, log)
+	streamHandler := handler.NewStreamHandler(broker, log)
+	wsHandler := handler.NewWebSocketHandler(svc, broker, log)
+	wsHandler.AllowOrigins(cfg.Stream.AllowedOrigins)
+	executor, err := gql.NewExecutor(svc, gql.Limits{
+		MaxDepth:      cfg.GraphQL.MaxDepth,
+		MaxComplexity: cfg.GraphQL.MaxComplexity,
+	})
+	if err != nil {
+		log.Fatal("Failed to initialize GraphQL", zap.Error(err))
+	}
+	graphqlHandler := handler.NewGraphQLHandler(executor, log)
+	spec, err := handler.LoadOpenAPI()
+	if err != nil {
+		log.Fatal("Failed to load OpenAPI document", zap.Error(err))
+	}
+	openAPIHandler := handler.NewOpenAPIHandler(spec, log)
+	decoder := handler.NewRequestDecoder(cfg.Server.MaxBodyBytes, log)
+
+	// Tracing and metrics come first so every response is covered.
+	// Authorization runs next so unauthenticated bodies are never read, and
+	// rate limiting follows it so clients are limited by principal.
+	routes := []handler.Routes{handler.NewTracer(), handler.NewMetricsHandler(m, log)}
+	var keys *auth.KeyStore
+	var authenticators auth.Chain
+	if cfg.Auth.Enabled {
+		if cfg.Auth.APIKeysFile != "" {
+			keys = auth.NewKeyStore(cfg.Auth.APIKeysFile, time.Duration(cfg.Auth.ReloadInterval)*time.Second, log)
+			if err := keys.Load(); err != nil {
+				log.Fatal("Failed to load API keys", zap.Error(err))
+			}
+			keys.Start()
+			authenticators = append(authenticators, keys)
+		}
+
+		ttl := time.Duration(cfg.Auth.JWKSCacheTTL) * time.Second
+		var jwks *auth.JWKS
+		switch {
+		case cfg.Auth.JWKSFile != "" && cfg.Auth.JWKSURL != "":
+			log.Fatal("Only one of AUTH_JWKS_FILE and AUTH_JWKS_URL may be set")
+		case cfg.Auth.JWKSFile != "":
+			jwks = auth.NewFileJWKS(cfg.Auth.JWKSFile, ttl, log)
+		case cfg.Auth.JWKSURL != "":
+			jwks = auth.NewURLJWKS(cfg.Auth.JWKSURL, &http.Client{Timeout: 10 * time.Second}, ttl, log)
+		}
+		if jwks != nil {
+			if err := jwks.Refresh(); err != nil {
+				log.Fatal("Failed to load JWKS", zap.Error(err))
+			}
+			authenticators = append(authenticators, auth.NewJWTAuthenticator(jwks, auth.JWTConfig{
+				Issuer:   cfg.Auth.JWTIssuer,
+				Audience: cfg.Auth.JWTAudience,
+				Leeway:   time.Duration(cfg.Auth.JWTLeeway) * time.Second,
+			}, log))
+		}
+
+		// Client certificates come last so explicit credentials win.
+		if cfg.TLS.Enabled && cfg.TLS.ClientAuth != "none" {
+			roles := make(map[string]auth.Role, len(cfg.Auth.ClientCertRoles))
+			for name, role := range cfg.Auth.ClientCertRoles {
+				if !auth.Role(role).Valid() {
+					log.Fatal("Invalid client certificate role", zap.String("name", name), zap.String("role", role))
+				}
+				roles[name] = auth.Role(role)
+			}
+			authenticators = append(authenticators, auth.NewClientCertAuthenticator(roles))
+		}
+
+		if len(authenticators) == 0 {
+			log.Fatal("Authentication is enabled but no API key file, JWKS or client CA is configured")
+		}
+		routes = append(routes, handler.NewAuthorizer(authenticators, log))
+	}
+	// The HTTP and gRPC APIs share buckets so clients have one budget.
+	var readLimiter, writeLimiter *ratelimit.Limiter
+	if cfg.RateLimit.Enabled {
+		readLimiter = ratelimit.NewLimiter(ratelimit.Limit{Rate: cfg.RateLimit.ReadRate, Burst: cfg.RateLimit.ReadBurst})
+		writeLimiter = ratelimit.NewLimiter(ratelimit.Limit{Rate: cfg.RateLimit.WriteRate, Burst: cfg.RateLimit.WriteBurst})
+		routes = append(routes, handler.NewRateLimiter(readLimiter, writeLimiter, cfg.RateLimit.TrustProxy, log))
+	}
+	routes = append(routes, handler.NewHealthHandler(checks, log), decoder, openAPIHandler, workerHandler, webhookHandler, streamHandler, wsHandler, graphqlHandler)
+	router := handler.SetupRouter(productHandler, log, routes...)
 
 	server := &http.Server{
 		Addr:         ":" + cfg.Server.Port,
@@ -64,28 +294,141 @@ func main() {
 		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout) * time.Second,
 	}
 
+	var tlsCerts *certs.Reloader
+	if cfg.TLS.Enabled {
+		tlsCerts = certs.NewReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.ClientCAFile,
+			time.Duration(cfg.TLS.ReloadInterval)*time.Second, log)
+		if err := tlsCerts.Load(); err != nil {
+			log.Fatal("Failed to load TLS certificate", zap.Error(err))
+		}
+		tlsCerts.Start()
+		server.TLSConfig = tlsCerts.TLSConfig(clientAuthTypes[cfg.TLS.ClientAuth])
+	}
+
 	go func() {
-		log.Info("Server starting", zap.String("port", cfg.Server.Port))
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Info("Server starting", zap.String("port", cfg.Server.Port), zap.Bool("tls", cfg.TLS.Enabled))
+		var err error
+		if cfg.TLS.Enabled {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatal("Server failed to start", zap.Error(err))
 		}
 	}()
 
+	var grpcServer *grpc.Server
+	var productServer *rpc.ProductServer
+	if cfg.GRPC.Enabled {
+		var opts []grpc.ServerOption
+		if cfg.Auth.Enabled {
+			opts = append(opts, rpc.NewAuthorizer(authenticators, log).ServerOptions()...)
+		}
+		if cfg.RateLimit.Enabled {
+			opts = append(opts, rpc.NewRateLimiter(readLimiter, writeLimiter, log).ServerOptions()...)
+		}
+		if tlsCerts != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCerts.TLSConfig(clientAuthTypes[cfg.TLS.ClientAuth]))))
+		}
+		grpcServer = grpc.NewServer(opts...)
+		productServer = rpc.NewProductServer(svc, broker, log)
+		productServer.Register(grpcServer)
+
+		grpcListener, err := net.Listen("tcp", ":"+cfg.GRPC.Port)
+		if err != nil {
+			log.Fatal("Failed to listen for gRPC", zap.Error(err))
+		}
+
+		go func() {
+			log.Info("gRPC server starting", zap.String("port", cfg.GRPC.Port), zap.Bool("tls", cfg.TLS.Enabled))
+			if err := grpcServer.Serve(grpcListener); err != nil {
+				log.Fatal("gRPC server failed to start", zap.Error(err))
+			}
+		}()
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	log.Info("Shutting down server")
 
+	// Fail readiness first and keep serving while load balancers notice.
+	checks.SetShuttingDown()
+	if cfg.Health.ShutdownDelay > 0 {
+		log.Info("Waiting for load balancers to drain", zap.Int("delay_seconds", cfg.Health.ShutdownDelay))
+		time.Sleep(time.Duration(cfg.Health.ShutdownDelay) * time.Second)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	pool.Stop()
+	// Stop ingestion first, so every event a client got a 202 for is queued
+	// before the workers drain. Ending open streams keeps Shutdown from
+	// waiting on them.
+	broker.Close()
+
+	if grpcServer != nil {
+		productServer.Shutdown()
+		grpcServer.GracefulStop()
+	}
 
 	if err := server.Shutdown(ctx); err != nil {
 		log.Error("Server forced to shutdown", zap.Error(err))
 	}
 
+	if autoscaler != nil {
+		autoscaler.Stop()
+	}
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), time.Duration(cfg.Shutdown.DrainTimeout)*time.Second)
+	if coalescer != nil {
+		// The workers must keep consuming while the coalescer hands over
+		// the events it holds.
+		pool.Resume()
+		if err := coalescer.Stop(drainCtx); err != nil {
+			log.Warn("Coalescer did not hand over held events in time", zap.Error(err))
+		}
+	}
+	if err := pool.Drain(drainCtx); err != nil {
+		log.Warn("Workers did not drain the queue in time", zap.Error(err))
+	}
+	cancelDrain()
+
+	// Broker-backed queues redeliver unacknowledged events; the in-memory
+	// queue would lose them, along with the events cut short at the deadline
+	// and those still held for coalescing.
+	if mq, ok := q.(*queue.InMemoryQueue); ok {
+		remaining := pool.Abandoned()
+		if coalescer != nil {
+			remaining = append(remaining, coalescer.Flush()...)
+		}
+		spillRemainingEvents(cfg.Shutdown.SpillFile, append(remaining, mq.Flush()...), log)
+	}
+
+	if relay != nil {
+		relayCtx, cancelRelay := context.WithTimeout(context.Background(), time.Duration(cfg.Shutdown.DrainTimeout)*time.Second)
+		relay.Stop(relayCtx)
+		cancelRelay()
+
+		// Flushes the file or Kafka writer behind the sink.
+		if closer, ok := sink.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Error("Error closing outbox sink", zap.Error(err))
+			}
+		}
+	}
+
+	dispatcher.Stop()
+
+	if keys != nil {
+		keys.Stop()
+	}
+	if tlsCerts != nil {
+		tlsCerts.Stop()
+	}
+
 	if err := q.Close(); err != nil {
 		log.Error("Error closing queue", zap.Error(err))
 	}
@@ -94,5 +437,87 @@ func main() {
 		log.Error("Error closing repository", zap.Error(err))
 	}
 
+	// Flush spans from the shutdown itself before exiting.
+	if err := tracerProvider.Shutdown(context.Background()); err != nil {
+		log.Error("Failed to flush traces", zap.Error(err))
+	}
+
 	log.Info("Server stopped gracefully")
 }
+
+func newOutboxSink(cfg *config.Config) (outbox.Sink, error) {
+	switch cfg.Outbox.Sink {
+	case "webhook":
+		return outbox.NewWebhookSink(cfg.Outbox.WebhookURL, 10*time.Second), nil
+	case "queue":
+		return outbox.NewQueueSink(cfg.Kafka.Brokers, cfg.Outbox.Topic), nil
+	case "file":
+		return outbox.NewFileSink(cfg.Outbox.FilePath)
+	default:
+		return nil, fmt.Errorf("unknown outbox sink %q", cfg.Outbox.Sink)
+	}
+}
+
+// replaySpilledEvents enqueues the events saved at the last shutdown. The
+// file is removed only once all of them are queued again.
+func replaySpilledEvents(path string, q queue.QueueProvider, log *zap.Logger) {
+	events, corrupt, err := queue.LoadEvents(path)
+	if err != nil {
+		log.Error("Failed to load unprocessed events", zap.String("path", path), zap.Error(err))
+		return
+	}
+	if corrupt > 0 {
+		log.Error("Failed to decode unprocessed events, skipping them",
+			zap.String("path", path),
+			zap.Int("count", corrupt),
+			zap.String("moved_to", path+queue.CorruptSuffix))
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	for i, event := range events {
+		if err := q.Enqueue(context.Background(), event); err != nil {
+			// Keep only what was not queued, so the next start neither
+			// loses nor repeats events.
+			remaining := events[i:]
+			log.Error("Failed to replay unprocessed events",
+				zap.String("path", path),
+				zap.Int("replayed", i),
+				zap.Int("remaining", len(remaining)),
+				zap.Error(err))
+			if err := queue.ReplaceEvents(path, remaining); err != nil {
+				log.Error("Failed to save events left to replay", zap.String("path", path), zap.Int("count", len(remaining)), zap.Error(err))
+			}
+			return
+		}
+	}
+	if err := queue.ReplaceEvents(path, nil); err != nil {
+		log.Error("Failed to remove replayed events file", zap.String("path", path), zap.Error(err))
+	}
+	log.Info("Replayed events left unprocessed at last shutdown", zap.Int("count", len(events)))
+}
+
+// spillRemainingEvents reports the events left unprocessed after draining and
+// saves them to path, if set, for replaySpilledEvents on the next start.
+func spillRemainingEvents(path string, events []*domain.Event, log *zap.Logger) {
+	if len(events) == 0 {
+		return
+	}
+
+	for _, event := range events {
+		log.Warn("Event left unprocessed",
+			zap.String("product_id", event.ProductID),
+			zap.String("request_id", event.RequestID))
+	}
+
+	if path == "" {
+		log.Error("Events left unprocessed at shutdown were dropped", zap.Int("count", len(events)))
+		return
+	}
+	if err := queue.SaveEvents(path, events); err != nil {
+		log.Error("Failed to save unprocessed events", zap.String("path", path), zap.Int("count", len(events)), zap.Error(err))
+		return
+	}
+	log.Warn("Saved events left unprocessed at shutdown", zap.String("path", path), zap.Int("count", len(events)))
+}
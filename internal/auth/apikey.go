@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// APIKeyHeader carries the API key on requests.
+const APIKeyHeader = "X-API-Key"
+
+const hashPrefix = "sha256:"
+
+// HashKey returns the form an API key is stored in the key file.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+type keyFile struct {
+	Keys []keyEntry `json:"keys"`
+}
+
+type keyEntry struct {
+	ID   string `json:"id"`
+	Role Role   `json:"role"`
+	Hash string `json:"hash"`
+}
+
+// KeyStore authenticates API keys against a JSON file of hashed keys:
+//
+//	{"keys": [{"id": "ci", "role": "writer", "hash": "sha256:<hex>"}]}
+//
+// The file is re-read when it changes, so keys can be added, rotated and
+// revoked without a restart. A file that fails to load leaves the previous
+// keys in place.
+type KeyStore struct {
+	path     string
+	interval time.Duration
+	logger   *zap.Logger
+
+	mu      sync.RWMutex
+	keys    map[string]*Principal
+	modTime time.Time
+	size    int64
+
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewKeyStore(path string, interval time.Duration, logger *zap.Logger) *KeyStore {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &KeyStore{
+		path:     path,
+		interval: interval,
+		logger:   logger,
+		keys:     make(map[string]*Principal),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Load reads the key file.
+func (s *KeyStore) Load() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read API key file: %w", err)
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read API key file: %w", err)
+	}
+
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse API key file: %w", err)
+	}
+
+	keys := make(map[string]*Principal, len(file.Keys))
+	for i, k := range file.Keys {
+		if k.ID == "" {
+			return fmt.Errorf("API key %d has no id", i)
+		}
+		if !k.Role.Valid() {
+			return fmt.Errorf("API key %q has invalid role %q", k.ID, k.Role)
+		}
+		hash := strings.ToLower(k.Hash)
+		if !strings.HasPrefix(hash, hashPrefix) || len(hash) != len(hashPrefix)+2*sha256.Size {
+			return fmt.Errorf("API key %q hash must be sha256:<64 hex digits>", k.ID)
+		}
+		if _, err := hex.DecodeString(strings.TrimPrefix(hash, hashPrefix)); err != nil {
+			return fmt.Errorf("API key %q hash must be sha256:<64 hex digits>", k.ID)
+		}
+		keys[hash] = &Principal{ID: k.ID, Role: k.Role}
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+	s.mu.Unlock()
+
+	s.logger.Info("API keys loaded", zap.String("path", s.path), zap.Int("count", len(keys)))
+	return nil
+}
+
+// Start watches the key file for changes.
+func (s *KeyStore) Start() {
+	if s.interval <= 0 {
+		return
+	}
+
+	s.wg.Add(1)
+	go s.watch()
+}
+
+func (s *KeyStore) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *KeyStore) watch() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if s.changed() {
+				if err := s.Load(); err != nil {
+					s.logger.Error("Failed to reload API keys, keeping previous keys", zap.Error(err))
+				}
+			}
+		}
+	}
+}
+
+func (s *KeyStore) changed() bool {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		s.logger.Error("Failed to stat API key file", zap.Error(err))
+		return false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !info.ModTime().Equal(s.modTime) || info.Size() != s.size
+}
+
+// Lookup returns the principal owning key. Keys are compared by their
+// SHA-256 digest, so lookup time reveals nothing about stored keys.
+func (s *KeyStore) Lookup(key string) (*Principal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.keys[HashKey(key)]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return p, nil
+}
+
+func (s *KeyStore) Challenge() string {
+	return `ApiKey header="` + APIKeyHeader + `"`
+}
+
+// Authenticate implements Authenticator using the X-API-Key header.
+func (s *KeyStore) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	return s.Lookup(key)
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func writeKeyFile(t *testing.T, path string, keys map[string]Role) {
+	t.Helper()
+
+	body := `{"keys":[`
+	i := 0
+	for key, role := range keys {
+		if i > 0 {
+			body += ","
+		}
+		body += fmt.Sprintf(`{"id":%q,"role":%q,"hash":%q}`, key+"-id", role, HashKey(key))
+		i++
+	}
+	body += `]}`
+	require.NoError(t, os.WriteFile(path, []byte(body), 0o600))
+}
+
+func newTestKeyStore(t *testing.T, keys map[string]Role) (*KeyStore, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeyFile(t, path, keys)
+
+	logger, _ := zap.NewDevelopment()
+	store := NewKeyStore(path, 10*time.Millisecond, logger)
+	require.NoError(t, store.Load())
+	return store, path
+}
+
+func TestKeyStore_Lookup(t *testing.T) {
+	store, _ := newTestKeyStore(t, map[string]Role{"secret-1": RoleWriter})
+
+	p, err := store.Lookup("secret-1")
+	require.NoError(t, err)
+	assert.Equal(t, &Principal{ID: "secret-1-id", Role: RoleWriter}, p)
+
+	_, err = store.Lookup("secret-2")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestKeyStore_Authenticate(t *testing.T) {
+	store, _ := newTestKeyStore(t, map[string]Role{"secret-1": RoleReader})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	_, err := store.Authenticate(req)
+	assert.ErrorIs(t, err, ErrNoCredentials)
+
+	req.Header.Set(APIKeyHeader, "wrong")
+	_, err = store.Authenticate(req)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	req.Header.Set(APIKeyHeader, "secret-1")
+	p, err := store.Authenticate(req)
+	require.NoError(t, err)
+	assert.Equal(t, RoleReader, p.Role)
+}
+
+func TestKeyStore_RejectsInvalidFiles(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	tests := map[string]string{
+		"not json":     `keys`,
+		"missing id":   `{"keys":[{"role":"reader","hash":"` + HashKey("k") + `"}]}`,
+		"unknown role": `{"keys":[{"id":"a","role":"owner","hash":"` + HashKey("k") + `"}]}`,
+		"plain key":    `{"keys":[{"id":"a","role":"reader","hash":"k"}]}`,
+	}
+
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys.json")
+			require.NoError(t, os.WriteFile(path, []byte(body), 0o600))
+			assert.Error(t, NewKeyStore(path, 0, logger).Load())
+		})
+	}
+
+	assert.Error(t, NewKeyStore(filepath.Join(t.TempDir(), "missing.json"), 0, logger).Load())
+}
+
+func TestKeyStore_ReloadsOnChange(t *testing.T) {
+	store, path := newTestKeyStore(t, map[string]Role{"old-key": RoleWriter})
+	store.Start()
+	defer store.Stop()
+
+	// Rotate: the new key replaces the old one.
+	writeKeyFile(t, path, map[string]Role{"new-key": RoleWriter, "extra-key": RoleAdmin})
+	future := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, future, future))
+
+	assert.Eventually(t, func() bool {
+		_, err := store.Lookup("new-key")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	_, err := store.Lookup("old-key")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestKeyStore_KeepsKeysWhenReloadFails(t *testing.T) {
+	store, path := newTestKeyStore(t, map[string]Role{"old-key": RoleWriter})
+	store.Start()
+	defer store.Stop()
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"keys":[`), 0o600))
+	future := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, future, future))
+	time.Sleep(50 * time.Millisecond)
+
+	_, err := store.Lookup("old-key")
+	assert.NoError(t, err)
+}
+
+func TestRole_Allows(t *testing.T) {
+	assert.True(t, RoleAdmin.Allows(RoleWriter))
+	assert.True(t, RoleWriter.Allows(RoleReader))
+	assert.True(t, RoleReader.Allows(RoleNone))
+	assert.False(t, RoleReader.Allows(RoleWriter))
+	assert.False(t, RoleWriter.Allows(RoleAdmin))
+	assert.False(t, Role("owner").Valid())
+}
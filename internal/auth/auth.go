@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrNoCredentials means the request carried no credentials this
+	// authenticator understands, so another one may still accept it.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials means credentials were presented but rejected.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrForbidden means the principal's role does not allow the operation.
+	ErrForbidden = errors.New("insufficient role")
+)
+
+// Role grants a set of permissions. Each role includes those below it.
+type Role string
+
+const (
+	RoleNone   Role = ""
+	RoleReader Role = "reader"
+	RoleWriter Role = "writer"
+	RoleAdmin  Role = "admin"
+)
+
+var roleRank = map[Role]int{
+	RoleNone:   0,
+	RoleReader: 1,
+	RoleWriter: 2,
+	RoleAdmin:  3,
+}
+
+// Valid reports whether r is a role that can be granted.
+func (r Role) Valid() bool {
+	_, ok := roleRank[r]
+	return ok && r != RoleNone
+}
+
+// Allows reports whether r includes the permissions of required.
+func (r Role) Allows(required Role) bool {
+	return roleRank[r] >= roleRank[required]
+}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	ID   string
+	Role Role
+}
+
+// Authenticator identifies the caller of an HTTP request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Challenger is implemented by authenticators that can describe the
+// credentials they expect in a WWW-Authenticate header.
+type Challenger interface {
+	Challenge() string
+}
+
+// Chain tries each authenticator in turn until one finds credentials it
+// understands.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return p, err
+	}
+	return nil, ErrNoCredentials
+}
+
+func (c Chain) Challenge() string {
+	var challenges []string
+	for _, a := range c {
+		if ch, ok := a.(Challenger); ok {
+			challenges = append(challenges, ch.Challenge())
+		}
+	}
+	return strings.Join(challenges, ", ")
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal stored on ctx, if any.
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// PrincipalID returns the ID of the principal on ctx, or "" if there is none.
+func PrincipalID(ctx context.Context) string {
+	if p, ok := PrincipalFrom(ctx); ok {
+		return p.ID
+	}
+	return ""
+}
+
+// Allowed reports whether the principal on ctx has at least role required.
+// Without a principal, which is the case when authentication is disabled,
+// everything is allowed.
+func Allowed(ctx context.Context, required Role) bool {
+	p, ok := PrincipalFrom(ctx)
+	return !ok || p.Role.Allows(required)
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"crypto/x509"
+	"net/http"
+)
+
+// ClientCertificate returns the verified client certificate of a mutual-TLS
+// request, or nil if the client did not present one.
+func ClientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// ClientCertAuthenticator identifies mutual-TLS clients by the common name of
+// their verified certificate. Clients missing from the role table are
+// authenticated with no role, so they can only reach public routes.
+type ClientCertAuthenticator struct {
+	roles map[string]Role
+}
+
+func NewClientCertAuthenticator(roles map[string]Role) *ClientCertAuthenticator {
+	return &ClientCertAuthenticator{roles: roles}
+}
+
+func (a *ClientCertAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	cert := ClientCertificate(r)
+	if cert == nil || cert.Subject.CommonName == "" {
+		return nil, ErrNoCredentials
+	}
+	name := cert.Subject.CommonName
+	return &Principal{ID: name, Role: a.roles[name]}, nil
+}
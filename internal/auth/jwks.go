@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrKeyNotFound means no key in the set matches a token's kid.
+var ErrKeyNotFound = errors.New("signing key not found")
+
+// minRefreshInterval limits refetches triggered by unknown key IDs, so tokens
+// with made-up kids cannot be used to hammer the JWKS source.
+const minRefreshInterval = 10 * time.Second
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	// Symmetric
+	K string `json:"k"`
+}
+
+// JWKS serves signing keys from a JSON Web Key Set read from a file or URL.
+// Keys are cached for the TTL and refetched early when a token names a key
+// the cache does not have, which picks up rotated keys without a restart.
+type JWKS struct {
+	source string
+	fetch  func() ([]byte, error)
+	ttl    time.Duration
+	logger *zap.Logger
+
+	mu        sync.Mutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+	inflight  *refreshCall
+}
+
+// refreshCall is a fetch in progress that other callers can wait for.
+type refreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+// NewFileJWKS reads the key set from path.
+func NewFileJWKS(path string, ttl time.Duration, logger *zap.Logger) *JWKS {
+	return newJWKS(path, func() ([]byte, error) {
+		return os.ReadFile(path)
+	}, ttl, logger)
+}
+
+// NewURLJWKS fetches the key set from url.
+func NewURLJWKS(url string, client *http.Client, ttl time.Duration, logger *zap.Logger) *JWKS {
+	return newJWKS(url, func() ([]byte, error) {
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	}, ttl, logger)
+}
+
+func newJWKS(source string, fetch func() ([]byte, error), ttl time.Duration, logger *zap.Logger) *JWKS {
+	return &JWKS{
+		source: source,
+		fetch:  fetch,
+		ttl:    ttl,
+		logger: logger,
+	}
+}
+
+// Refresh loads the key set now. Concurrent calls share one fetch.
+func (s *JWKS) Refresh() error {
+	s.mu.Lock()
+	if call := s.inflight; call != nil {
+		s.mu.Unlock()
+		<-call.done
+		return call.err
+	}
+	call := &refreshCall{done: make(chan struct{})}
+	s.inflight = call
+	// Counting the attempt rather than the success keeps a failing source
+	// from being refetched on every request.
+	s.fetchedAt = time.Now()
+	s.mu.Unlock()
+
+	keys, err := s.load()
+
+	s.mu.Lock()
+	if err == nil {
+		s.keys = keys
+	}
+	s.inflight = nil
+	s.mu.Unlock()
+
+	call.err = err
+	close(call.done)
+	return err
+}
+
+// load fetches and parses the key set without holding the lock, so a slow
+// source does not block lookups of cached keys.
+func (s *JWKS) load() (map[string]interface{}, error) {
+	data, err := s.fetch()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS from %s: %w", s.source, err)
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS from %s: %w", s.source, err)
+	}
+
+	s.logger.Info("JWKS loaded", zap.String("source", s.source), zap.Int("count", len(keys)))
+	return keys, nil
+}
+
+// Key returns the key with the given ID. Lookups during a refresh use the
+// cached keys, except before the first load, which they wait for.
+func (s *JWKS) Key(kid string) (interface{}, error) {
+	key, known, due := s.lookup(kid)
+	if due {
+		if err := s.Refresh(); err != nil {
+			// Keep serving the cached keys; the source may be briefly down.
+			s.logger.Error("Failed to refresh JWKS", zap.Error(err))
+		}
+		key, known, _ = s.lookup(kid)
+	}
+
+	if !known {
+		return nil, fmt.Errorf("%w: kid %q", ErrKeyNotFound, kid)
+	}
+	return key, nil
+}
+
+// lookup returns the cached key for kid and whether the set should be
+// refetched: when it is older than the TTL, or when kid is unknown and the
+// last attempt is older than minRefreshInterval.
+func (s *JWKS) lookup(kid string) (key interface{}, known, due bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, known = s.keys[kid]
+	age := time.Since(s.fetchedAt)
+	due = s.keys == nil || age > s.ttl || (!known && age > minRefreshInterval)
+	return key, known, due
+}
+
+func parseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("invalid e")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y: %w", err)
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		if !key.Curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return key, nil
+
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return nil, errors.New("invalid k")
+		}
+		return secret, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// DefaultScopeRoles maps the scopes our gateway issues to roles.
+var DefaultScopeRoles = map[string]Role{
+	"products:read":  RoleReader,
+	"products:write": RoleWriter,
+	"admin":          RoleAdmin,
+}
+
+// KeySource resolves the key a token was signed with from its kid header.
+type KeySource interface {
+	Key(kid string) (interface{}, error)
+}
+
+type JWTConfig struct {
+	Issuer     string
+	Audience   string
+	Leeway     time.Duration
+	ScopeRoles map[string]Role
+}
+
+// JWTAuthenticator validates bearer tokens signed with RS256, ES256 or HS256.
+// The principal is the token subject and its role is the highest one granted
+// by the token's scopes.
+type JWTAuthenticator struct {
+	keys   KeySource
+	config JWTConfig
+	logger *zap.Logger
+	parser *jwt.Parser
+}
+
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	// Scope is the space-separated OAuth form; Scp is the list form some
+	// issuers use instead.
+	Scope string   `json:"scope"`
+	Scp   []string `json:"scp"`
+}
+
+func NewJWTAuthenticator(keys KeySource, config JWTConfig, logger *zap.Logger) *JWTAuthenticator {
+	if config.ScopeRoles == nil {
+		config.ScopeRoles = DefaultScopeRoles
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "ES256", "HS256"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(config.Leeway),
+	}
+	if config.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		opts = append(opts, jwt.WithAudience(config.Audience))
+	}
+
+	return &JWTAuthenticator{
+		keys:   keys,
+		config: config,
+		logger: logger,
+		parser: jwt.NewParser(opts...),
+	}
+}
+
+// Authenticate implements Authenticator using the Authorization header.
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, ErrNoCredentials
+	}
+
+	return a.Verify(strings.TrimSpace(token))
+}
+
+// Verify validates token and returns its principal.
+func (a *JWTAuthenticator) Verify(token string) (*Principal, error) {
+	var claims tokenClaims
+	if _, err := a.parser.ParseWithClaims(token, &claims, a.key); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+
+	return &Principal{ID: claims.Subject, Role: a.role(claims)}, nil
+}
+
+func (a *JWTAuthenticator) Challenge() string {
+	return "Bearer"
+}
+
+// key returns the verification key for token, refusing keys whose type does
+// not match the signing method so an RSA public key can never be used as an
+// HMAC secret.
+func (a *JWTAuthenticator) key(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, err := a.keys.Key(kid)
+	if err != nil {
+		return nil, err
+	}
+
+	switch token.Method.(type) {
+	case *jwt.SigningMethodRSA:
+		if k, ok := key.(*rsa.PublicKey); ok {
+			return k, nil
+		}
+	case *jwt.SigningMethodECDSA:
+		if k, ok := key.(*ecdsa.PublicKey); ok {
+			return k, nil
+		}
+	case *jwt.SigningMethodHMAC:
+		if k, ok := key.([]byte); ok {
+			return k, nil
+		}
+	}
+	return nil, errors.New("key type does not match signing method")
+}
+
+func (a *JWTAuthenticator) role(claims tokenClaims) Role {
+	scopes := append(strings.Fields(claims.Scope), claims.Scp...)
+
+	role := RoleNone
+	for _, scope := range scopes {
+		if granted, ok := a.config.ScopeRoles[scope]; ok && granted.Allows(role) {
+			role = granted
+		}
+	}
+	return role
+}
@@ -0,0 +1,334 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testSigners holds locally generated keys and the JWKS that publishes them.
+type testSigners struct {
+	rsa    *rsa.PrivateKey
+	ec     *ecdsa.PrivateKey
+	secret []byte
+}
+
+func newTestSigners(t *testing.T) *testSigners {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return &testSigners{rsa: rsaKey, ec: ecKey, secret: []byte("0123456789abcdef0123456789abcdef")}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (s *testSigners) jwks(rsaKid string) []byte {
+	set := map[string][]map[string]string{"keys": {
+		{"kty": "RSA", "kid": rsaKid, "use": "sig", "n": b64(s.rsa.N.Bytes()), "e": b64(big.NewInt(int64(s.rsa.E)).Bytes())},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(s.ec.X.Bytes()), "y": b64(s.ec.Y.Bytes())},
+		{"kty": "oct", "kid": "hs-1", "k": b64(s.secret)},
+	}}
+	data, _ := json.Marshal(set)
+	return data
+}
+
+func (s *testSigners) sign(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+
+	var key interface{}
+	switch method {
+	case jwt.SigningMethodRS256:
+		key = s.rsa
+	case jwt.SigningMethodES256:
+		key = s.ec
+	default:
+		key = s.secret
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func validClaims(scope string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "gateway-client",
+		"iss":   "https://gateway.example",
+		"aud":   "vfc",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"scope": scope,
+	}
+}
+
+func newTestJWT(t *testing.T, signers *testSigners) (*JWTAuthenticator, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, signers.jwks("rsa-1"), 0o600))
+
+	logger, _ := zap.NewDevelopment()
+	keys := NewFileJWKS(path, time.Hour, logger)
+	require.NoError(t, keys.Refresh())
+
+	return NewJWTAuthenticator(keys, JWTConfig{
+		Issuer:   "https://gateway.example",
+		Audience: "vfc",
+	}, logger), path
+}
+
+func TestJWTAuthenticator_AcceptsSupportedAlgorithms(t *testing.T) {
+	signers := newTestSigners(t)
+	a, _ := newTestJWT(t, signers)
+
+	tests := []struct {
+		method jwt.SigningMethod
+		kid    string
+	}{
+		{jwt.SigningMethodRS256, "rsa-1"},
+		{jwt.SigningMethodES256, "ec-1"},
+		{jwt.SigningMethodHS256, "hs-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method.Alg(), func(t *testing.T) {
+			p, err := a.Verify(signers.sign(t, tt.method, tt.kid, validClaims("products:read")))
+			require.NoError(t, err)
+			assert.Equal(t, &Principal{ID: "gateway-client", Role: RoleReader}, p)
+		})
+	}
+}
+
+func TestJWTAuthenticator_RejectsInvalidTokens(t *testing.T) {
+	signers := newTestSigners(t)
+	a, _ := newTestJWT(t, signers)
+
+	with := func(key string, value interface{}) jwt.MapClaims {
+		claims := validClaims("products:read")
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	tests := map[string]string{
+		"expired":         signers.sign(t, jwt.SigningMethodRS256, "rsa-1", with("exp", time.Now().Add(-time.Minute).Unix())),
+		"no expiry":       signers.sign(t, jwt.SigningMethodRS256, "rsa-1", with("exp", nil)),
+		"wrong audience":  signers.sign(t, jwt.SigningMethodRS256, "rsa-1", with("aud", "other")),
+		"wrong issuer":    signers.sign(t, jwt.SigningMethodRS256, "rsa-1", with("iss", "https://evil.example")),
+		"no subject":      signers.sign(t, jwt.SigningMethodRS256, "rsa-1", with("sub", nil)),
+		"unknown kid":     signers.sign(t, jwt.SigningMethodRS256, "rsa-2", validClaims("products:read")),
+		"kid of rsa key":  signers.sign(t, jwt.SigningMethodHS256, "rsa-1", validClaims("products:read")),
+		"unsupported alg": signers.sign(t, jwt.SigningMethodHS512, "hs-1", validClaims("products:read")),
+		"garbage":         "not.a.token",
+	}
+
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := a.Verify(token)
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+		})
+	}
+
+	t.Run("alg none", func(t *testing.T) {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims("admin")).SignedString(jwt.UnsafeAllowNoneSignatureType)
+		require.NoError(t, err)
+		_, err = a.Verify(token)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+}
+
+func TestJWTAuthenticator_MapsScopesToRoles(t *testing.T) {
+	signers := newTestSigners(t)
+	a, _ := newTestJWT(t, signers)
+
+	tests := map[string]Role{
+		"":                             RoleNone,
+		"openid":                       RoleNone,
+		"products:read":                RoleReader,
+		"products:read products:write": RoleWriter,
+		"products:write products:read": RoleWriter,
+		"admin products:read":          RoleAdmin,
+	}
+
+	for scope, role := range tests {
+		p, err := a.Verify(signers.sign(t, jwt.SigningMethodES256, "ec-1", validClaims(scope)))
+		require.NoError(t, err, scope)
+		assert.Equal(t, role, p.Role, scope)
+	}
+
+	claims := validClaims("")
+	claims["scp"] = []string{"products:write"}
+	p, err := a.Verify(signers.sign(t, jwt.SigningMethodES256, "ec-1", claims))
+	require.NoError(t, err)
+	assert.Equal(t, RoleWriter, p.Role)
+}
+
+func TestJWTAuthenticator_Authenticate(t *testing.T) {
+	signers := newTestSigners(t)
+	a, _ := newTestJWT(t, signers)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	_, err := a.Authenticate(req)
+	assert.ErrorIs(t, err, ErrNoCredentials)
+
+	req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	_, err = a.Authenticate(req)
+	assert.ErrorIs(t, err, ErrNoCredentials)
+
+	req.Header.Set("Authorization", "Bearer "+signers.sign(t, jwt.SigningMethodRS256, "rsa-1", validClaims("products:write")))
+	p, err := a.Authenticate(req)
+	require.NoError(t, err)
+	assert.Equal(t, RoleWriter, p.Role)
+}
+
+func TestFileJWKS_PicksUpRotatedKeys(t *testing.T) {
+	signers := newTestSigners(t)
+	a, path := newTestJWT(t, signers)
+
+	// Publish the RSA key under a new kid, as a gateway does when rotating.
+	require.NoError(t, os.WriteFile(path, signers.jwks("rsa-2"), 0o600))
+	a.keys.(*JWKS).fetchedAt = time.Now().Add(-time.Minute)
+
+	_, err := a.Verify(signers.sign(t, jwt.SigningMethodRS256, "rsa-2", validClaims("products:read")))
+	assert.NoError(t, err)
+}
+
+func TestURLJWKS_CachesKeys(t *testing.T) {
+	signers := newTestSigners(t)
+
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write(signers.jwks("rsa-1"))
+	}))
+	defer server.Close()
+
+	logger, _ := zap.NewDevelopment()
+	keys := NewURLJWKS(server.URL, server.Client(), time.Hour, logger)
+	a := NewJWTAuthenticator(keys, JWTConfig{}, logger)
+
+	for i := 0; i < 5; i++ {
+		_, err := a.Verify(signers.sign(t, jwt.SigningMethodRS256, "rsa-1", validClaims("products:read")))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), fetches.Load())
+
+	// An unknown kid right after a fetch does not trigger another one.
+	_, err := a.Verify(signers.sign(t, jwt.SigningMethodRS256, "rsa-9", validClaims("products:read")))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	assert.Equal(t, int32(1), fetches.Load())
+}
+
+func TestURLJWKS_KeepsKeysWhenSourceFails(t *testing.T) {
+	signers := newTestSigners(t)
+
+	var fail atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(signers.jwks("rsa-1"))
+	}))
+	defer server.Close()
+
+	logger, _ := zap.NewDevelopment()
+	keys := NewURLJWKS(server.URL, server.Client(), time.Millisecond, logger)
+	require.NoError(t, keys.Refresh())
+
+	fail.Store(true)
+	time.Sleep(5 * time.Millisecond)
+
+	key, err := keys.Key("rsa-1")
+	require.NoError(t, err)
+	assert.IsType(t, &rsa.PublicKey{}, key)
+}
+
+func TestURLJWKS_SlowRefreshDoesNotBlockCachedKeys(t *testing.T) {
+	signers := newTestSigners(t)
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		w.Write(signers.jwks("rsa-1"))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	logger, _ := zap.NewDevelopment()
+	keys := NewURLJWKS(server.URL, server.Client(), time.Hour, logger)
+	require.NoError(t, keys.Refresh())
+
+	// Several tokens with an unknown kid force one refetch, which hangs.
+	keys.mu.Lock()
+	keys.fetchedAt = time.Now().Add(-time.Minute)
+	keys.mu.Unlock()
+	for i := 0; i < 3; i++ {
+		go keys.Key("rsa-9")
+	}
+	require.Eventually(t, func() bool { return fetches.Load() == 2 }, time.Second, time.Millisecond)
+
+	done := make(chan error)
+	go func() {
+		_, err := keys.Key("rsa-1")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("cached key lookup waited for the refresh")
+	}
+	assert.Equal(t, int32(2), fetches.Load())
+}
+
+func TestChain(t *testing.T) {
+	signers := newTestSigners(t)
+	jwtAuth, _ := newTestJWT(t, signers)
+	keys, _ := newTestKeyStore(t, map[string]Role{"api-key": RoleAdmin})
+	chain := Chain{keys, jwtAuth}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	_, err := chain.Authenticate(req)
+	assert.ErrorIs(t, err, ErrNoCredentials)
+
+	req.Header.Set("Authorization", "Bearer "+signers.sign(t, jwt.SigningMethodRS256, "rsa-1", validClaims("products:read")))
+	p, err := chain.Authenticate(req)
+	require.NoError(t, err)
+	assert.Equal(t, "gateway-client", p.ID)
+
+	req.Header.Set(APIKeyHeader, "api-key")
+	p, err = chain.Authenticate(req)
+	require.NoError(t, err)
+	assert.Equal(t, RoleAdmin, p.Role)
+
+	assert.Equal(t, `ApiKey header="X-API-Key", Bearer`, chain.Challenge())
+}
@@ -0,0 +1,216 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Reloader serves a certificate and key, and optionally a client CA bundle,
+// from files that are re-read when they change. Handshakes always use the
+// latest files that loaded successfully, so certificates can be rotated
+// without a restart; files that fail to load leave the previous ones in place.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	interval time.Duration
+	logger   *zap.Logger
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+	// configs caches the handshake configuration per client auth type
+	// until the next reload.
+	configs map[tls.ClientAuthType]*tls.Config
+
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewReloader creates a Reloader. caFile may be empty when client
+// certificates are not verified.
+func NewReloader(certFile, keyFile, caFile string, interval time.Duration, logger *zap.Logger) *Reloader {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		interval: interval,
+		logger:   logger,
+		modTimes: make(map[string]time.Time),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Load reads the certificate, key and client CA files.
+func (r *Reloader) Load() error {
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	if cert.Leaf == nil && len(cert.Certificate) > 0 {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return fmt.Errorf("failed to parse TLS certificate: %w", err)
+		}
+	}
+
+	var clientCAs *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("client CA file %s contains no certificates", r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	r.configs = make(map[tls.ClientAuthType]*tls.Config)
+	r.mu.Unlock()
+
+	r.logger.Info("TLS certificate loaded",
+		zap.String("subject", cert.Leaf.Subject.String()),
+		zap.Time("not_after", cert.Leaf.NotAfter))
+	return nil
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+	return files
+}
+
+func (r *Reloader) stat() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time)
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS file: %w", err)
+		}
+		modTimes[f] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// Start watches the files for changes.
+func (r *Reloader) Start() {
+	if r.interval <= 0 {
+		return
+	}
+
+	r.wg.Add(1)
+	go r.watch()
+}
+
+func (r *Reloader) Stop() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+func (r *Reloader) watch() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			if r.changed() {
+				if err := r.Load(); err != nil {
+					r.logger.Error("Failed to reload TLS certificate, keeping previous certificate", zap.Error(err))
+				}
+			}
+		}
+	}
+}
+
+func (r *Reloader) changed() bool {
+	modTimes, err := r.stat()
+	if err != nil {
+		r.logger.Error("Failed to stat TLS files", zap.Error(err))
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for f, t := range modTimes {
+		if !t.Equal(r.modTimes[f]) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCertificate returns the current certificate for a handshake.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// TLSConfig returns a server configuration that uses the current
+// certificate and client CAs on every handshake.
+func (r *Reloader) TLSConfig(clientAuth tls.ClientAuthType) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.config(clientAuth)
+		},
+	}
+}
+
+// config returns the handshake configuration for the loaded files, building
+// it once per reload so handshakes share it, along with its session ticket
+// keys.
+func (r *Reloader) config(clientAuth tls.ClientAuthType) (*tls.Config, error) {
+	r.mu.RLock()
+	config, ok := r.configs[clientAuth]
+	r.mu.RUnlock()
+	if ok {
+		return config, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cert == nil {
+		return nil, errors.New("no TLS certificate loaded")
+	}
+	if config, ok := r.configs[clientAuth]; ok {
+		return config, nil
+	}
+	config = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*r.cert},
+		ClientAuth:   clientAuth,
+		ClientCAs:    r.clientCAs,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	r.configs[clientAuth] = config
+	return config, nil
+}
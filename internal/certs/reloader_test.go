@@ -0,0 +1,213 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/raufhm/vfc/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key for name signed by the CA.
+func (ca *testCA) issue(t *testing.T, name string, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+type testFiles struct {
+	cert, key, ca string
+}
+
+func writeFiles(t *testing.T, dir string, certPEM, keyPEM, caPEM []byte) testFiles {
+	t.Helper()
+
+	f := testFiles{
+		cert: filepath.Join(dir, "tls.crt"),
+		key:  filepath.Join(dir, "tls.key"),
+		ca:   filepath.Join(dir, "ca.crt"),
+	}
+	require.NoError(t, os.WriteFile(f.cert, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(f.key, keyPEM, 0o600))
+	require.NoError(t, os.WriteFile(f.ca, caPEM, 0o600))
+	return f
+}
+
+func TestReloader_PicksUpRotatedCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "localhost", 2, x509.ExtKeyUsageServerAuth)
+	files := writeFiles(t, t.TempDir(), certPEM, keyPEM, ca.pem)
+
+	logger, _ := zap.NewDevelopment()
+	r := NewReloader(files.cert, files.key, files.ca, time.Hour, logger)
+	require.NoError(t, r.Load())
+	assert.False(t, r.changed())
+
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cert.Leaf.SerialNumber.Int64())
+
+	// Handshakes share one configuration until the files change.
+	getConfig := r.TLSConfig(tls.NoClientCert).GetConfigForClient
+	before, err := getConfig(nil)
+	require.NoError(t, err)
+	again, err := getConfig(nil)
+	require.NoError(t, err)
+	assert.Same(t, before, again)
+
+	certPEM, keyPEM = ca.issue(t, "localhost", 3, x509.ExtKeyUsageServerAuth)
+	writeFiles(t, filepath.Dir(files.cert), certPEM, keyPEM, ca.pem)
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(files.cert, future, future))
+	require.True(t, r.changed())
+	require.NoError(t, r.Load())
+
+	cert, err = r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), cert.Leaf.SerialNumber.Int64())
+
+	after, err := getConfig(nil)
+	require.NoError(t, err)
+	assert.NotSame(t, before, after)
+	assert.Equal(t, int64(3), after.Certificates[0].Leaf.SerialNumber.Int64())
+}
+
+func TestReloader_KeepsCertificateWhenFilesAreInvalid(t *testing.T) {
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "localhost", 2, x509.ExtKeyUsageServerAuth)
+	files := writeFiles(t, t.TempDir(), certPEM, keyPEM, ca.pem)
+
+	logger, _ := zap.NewDevelopment()
+	r := NewReloader(files.cert, files.key, files.ca, time.Hour, logger)
+	require.NoError(t, r.Load())
+
+	// A key that does not match the certificate, as when only one file of a
+	// rotation has been written so far.
+	_, otherKey := ca.issue(t, "localhost", 3, x509.ExtKeyUsageServerAuth)
+	require.NoError(t, os.WriteFile(files.key, otherKey, 0o600))
+	assert.Error(t, r.Load())
+
+	require.NoError(t, os.WriteFile(files.ca, []byte("not a certificate"), 0o600))
+	assert.Error(t, r.Load())
+
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cert.Leaf.SerialNumber.Int64())
+}
+
+func TestReloader_MutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, "localhost", 2, x509.ExtKeyUsageServerAuth)
+	files := writeFiles(t, t.TempDir(), certPEM, keyPEM, ca.pem)
+
+	logger, _ := zap.NewDevelopment()
+	r := NewReloader(files.cert, files.key, files.ca, time.Hour, logger)
+	require.NoError(t, r.Load())
+
+	authenticator := auth.NewClientCertAuthenticator(map[string]auth.Role{"orders-service": auth.RoleWriter})
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		p, err := authenticator.Authenticate(req)
+		if err != nil {
+			io.WriteString(w, "anonymous")
+			return
+		}
+		io.WriteString(w, p.ID+":"+string(p.Role))
+	}))
+	server.TLS = r.TLSConfig(tls.VerifyClientCertIfGiven)
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.pem)
+	get := func(certs ...tls.Certificate) (string, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      roots,
+			Certificates: certs,
+		}}}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	body, err := get()
+	require.NoError(t, err)
+	assert.Equal(t, "anonymous", body)
+
+	clientPEM, clientKey := ca.issue(t, "orders-service", 4, x509.ExtKeyUsageClientAuth)
+	clientCert, err := tls.X509KeyPair(clientPEM, clientKey)
+	require.NoError(t, err)
+	body, err = get(clientCert)
+	require.NoError(t, err)
+	assert.Equal(t, "orders-service:writer", body)
+
+	// A certificate from another CA fails the handshake.
+	rogue, rogueKey := newTestCA(t).issue(t, "orders-service", 5, x509.ExtKeyUsageClientAuth)
+	rogueCert, err := tls.X509KeyPair(rogue, rogueKey)
+	require.NoError(t, err)
+	_, err = get(rogueCert)
+	assert.Error(t, err)
+}
@@ -2,14 +2,31 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server ServerConfig
-	Worker WorkerConfig
-	Queue  QueueConfig
+	Server    ServerConfig
+	GRPC      GRPCConfig
+	Worker    WorkerConfig
+	Queue     QueueConfig
+	RabbitMQ  RabbitMQConfig
+	Kafka     KafkaConfig
+	Outbox    OutboxConfig
+	Webhook   WebhookConfig
+	Stream    StreamConfig
+	GraphQL   GraphQLConfig
+	Auth      AuthConfig
+	RateLimit RateLimitConfig
+	TLS       TLSConfig
+	Tracing   TracingConfig
+	Health    HealthConfig
+	Autoscale AutoscaleConfig
+	Shutdown  ShutdownConfig
+	Coalesce  CoalesceConfig
 }
 
 type ServerConfig struct {
@@ -17,22 +34,225 @@ type ServerConfig struct {
 	ReadTimeout  int
 	WriteTimeout int
 	IdleTimeout  int
+	MaxBodyBytes int64
 }
 
+// GRPCConfig exposes the gRPC API when Enabled. It shares the HTTP API's
+// authentication and TLS settings.
+type GRPCConfig struct {
+	Enabled bool
+	Port    string
+}
+
+// WorkerConfig sizes the worker pool. MaxCount caps resizing through the
+// admin API. A worker that panics restarts after RestartBackoffMs, doubling
+// up to RestartMaxBackoff seconds while it keeps panicking. EventTimeoutMs
+// limits the time spent on one event; zero means no limit.
 type WorkerConfig struct {
-	Count int
+	Count             int
+	MaxCount          int
+	RestartBackoffMs  int
+	RestartMaxBackoff int
+	EventTimeoutMs    int
 }
 
 type QueueConfig struct {
+	Driver     string
 	BufferSize int
 }
 
+type RabbitMQConfig struct {
+	URL        string
+	Exchange   string
+	Queue      string
+	Prefetch   int
+	MaxRetries int
+}
+
+type OutboxConfig struct {
+	Enabled        bool
+	Sink           string
+	WebhookURL     string
+	FilePath       string
+	Topic          string
+	PollIntervalMs int
+	BatchSize      int
+	MaxAttempts    int
+}
+
+type WebhookConfig struct {
+	Workers      int
+	MaxAttempts  int
+	Timeout      int
+	RetryDelayMs int
+	LogSize      int
+}
+
+// StreamConfig sizes the live update streams. AllowedOrigins lists the
+// browser origins, besides the API's own host, that may open /ws.
+type StreamConfig struct {
+	BufferSize     int
+	HistorySize    int
+	AllowedOrigins []string
+}
+
+type GraphQLConfig struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+type AuthConfig struct {
+	Enabled        bool
+	APIKeysFile    string
+	ReloadInterval int
+	JWKSFile       string
+	JWKSURL        string
+	JWKSCacheTTL   int
+	JWTIssuer      string
+	JWTAudience    string
+	JWTLeeway      int
+	// ClientCertRoles maps mutual-TLS client certificate common names to
+	// roles, read from AUTH_CLIENT_CERT_ROLES as "name=role,name=role".
+	ClientCertRoles map[string]string
+}
+
+// RateLimitConfig sets per-client token buckets: requests per second and
+// burst size, separately for read and write routes.
+type RateLimitConfig struct {
+	Enabled    bool
+	ReadRate   float64
+	ReadBurst  int
+	WriteRate  float64
+	WriteBurst int
+	TrustProxy bool
+}
+
+// TLSConfig enables HTTPS. ClientAuth is "none", "request" (verify client
+// certificates when presented) or "require"; the last two need ClientCAFile.
+type TLSConfig struct {
+	Enabled        bool
+	CertFile       string
+	KeyFile        string
+	ClientCAFile   string
+	ClientAuth     string
+	ReloadInterval int
+}
+
+// TracingConfig selects the span exporter: "none", "stdout" or "file".
+type TracingConfig struct {
+	Exporter    string
+	FilePath    string
+	ServiceName string
+	SampleRatio float64
+}
+
+// HealthConfig tunes the /livez and /readyz probes. ShutdownDelay is how long
+// the server keeps serving, with readiness failing, before it starts to stop.
+type HealthConfig struct {
+	CheckTimeout    int
+	QueueSaturation float64
+	ShutdownDelay   int
+}
+
+// AutoscaleConfig resizes the worker pool between MinWorkers and MaxWorkers
+// based on queue depth per worker and event latency. Intervals and cooldowns
+// are in seconds.
+type AutoscaleConfig struct {
+	Enabled           bool
+	MinWorkers        int
+	MaxWorkers        int
+	Interval          int
+	TargetQueueDepth  float64
+	TargetLatencyMs   int
+	ScaleUpCooldown   int
+	ScaleDownCooldown int
+}
+
+// ShutdownConfig bounds how long workers may drain the queue on shutdown, in
+// seconds. Events still queued after that are saved to SpillFile, if set,
+// and replayed on the next start.
+type ShutdownConfig struct {
+	DrainTimeout int
+	SpillFile    string
+}
+
+// CoalesceConfig holds each event for WindowMs so that newer events for the
+// same product can replace it before a worker saves it.
+type CoalesceConfig struct {
+	Enabled  bool
+	WindowMs int
+}
+
+type KafkaConfig struct {
+	Brokers    []string
+	Topic      string
+	GroupID    string
+	MaxRetries int
+}
+
 func Load() (*Config, error) {
 	viper.SetConfigName(".env")
 	viper.SetConfigType("env")
 	viper.AddConfigPath(".")
 	viper.AutomaticEnv()
 
+	viper.SetDefault("SERVER_MAX_BODY_BYTES", 1<<20)
+	viper.SetDefault("GRPC_PORT", "9090")
+	viper.SetDefault("WORKER_MAX_COUNT", 64)
+	viper.SetDefault("WORKER_RESTART_BACKOFF_MS", 100)
+	viper.SetDefault("WORKER_RESTART_MAX_BACKOFF", 30)
+	viper.SetDefault("WORKER_EVENT_TIMEOUT_MS", 10000)
+	viper.SetDefault("QUEUE_DRIVER", "memory")
+	viper.SetDefault("RABBITMQ_QUEUE", "product-events")
+	viper.SetDefault("RABBITMQ_PREFETCH", 10)
+	viper.SetDefault("RABBITMQ_MAX_RETRIES", 3)
+	viper.SetDefault("KAFKA_TOPIC", "product-events")
+	viper.SetDefault("KAFKA_GROUP_ID", "vfc")
+	viper.SetDefault("KAFKA_MAX_RETRIES", 3)
+	viper.SetDefault("OUTBOX_SINK", "file")
+	viper.SetDefault("OUTBOX_FILE_PATH", "outbox.jsonl")
+	viper.SetDefault("OUTBOX_TOPIC", "product-changes")
+	viper.SetDefault("OUTBOX_POLL_INTERVAL_MS", 500)
+	viper.SetDefault("OUTBOX_BATCH_SIZE", 100)
+	viper.SetDefault("OUTBOX_MAX_ATTEMPTS", 5)
+	viper.SetDefault("WEBHOOK_WORKERS", 4)
+	viper.SetDefault("WEBHOOK_MAX_ATTEMPTS", 5)
+	viper.SetDefault("WEBHOOK_TIMEOUT", 10)
+	viper.SetDefault("WEBHOOK_RETRY_DELAY_MS", 500)
+	viper.SetDefault("WEBHOOK_LOG_SIZE", 100)
+	viper.SetDefault("STREAM_BUFFER_SIZE", 64)
+	viper.SetDefault("STREAM_HISTORY_SIZE", 1000)
+	viper.SetDefault("GRAPHQL_MAX_DEPTH", 6)
+	viper.SetDefault("GRAPHQL_MAX_COMPLEXITY", 1000)
+	viper.SetDefault("AUTH_API_KEYS_FILE", "api-keys.json")
+	viper.SetDefault("AUTH_RELOAD_INTERVAL", 10)
+	viper.SetDefault("AUTH_JWKS_CACHE_TTL", 300)
+	viper.SetDefault("AUTH_JWT_LEEWAY", 30)
+	viper.SetDefault("RATE_LIMIT_READ_RATE", 20)
+	viper.SetDefault("RATE_LIMIT_READ_BURST", 40)
+	viper.SetDefault("RATE_LIMIT_WRITE_RATE", 5)
+	viper.SetDefault("RATE_LIMIT_WRITE_BURST", 10)
+	viper.SetDefault("TLS_CLIENT_AUTH", "none")
+	viper.SetDefault("TLS_RELOAD_INTERVAL", 10)
+	viper.SetDefault("TRACING_EXPORTER", "none")
+	viper.SetDefault("TRACING_FILE_PATH", "traces.jsonl")
+	viper.SetDefault("TRACING_SERVICE_NAME", "vfc")
+	viper.SetDefault("TRACING_SAMPLE_RATIO", 1.0)
+	viper.SetDefault("HEALTH_CHECK_TIMEOUT_MS", 2000)
+	viper.SetDefault("HEALTH_QUEUE_SATURATION", 0.9)
+	viper.SetDefault("HEALTH_SHUTDOWN_DELAY", 0)
+	viper.SetDefault("SHUTDOWN_DRAIN_TIMEOUT", 20)
+	viper.SetDefault("SHUTDOWN_SPILL_FILE", "unprocessed-events.jsonl")
+	viper.SetDefault("AUTOSCALE_MIN_WORKERS", 1)
+	viper.SetDefault("AUTOSCALE_MAX_WORKERS", 16)
+	viper.SetDefault("AUTOSCALE_INTERVAL", 5)
+	viper.SetDefault("AUTOSCALE_TARGET_QUEUE_DEPTH", 10)
+	viper.SetDefault("AUTOSCALE_TARGET_LATENCY_MS", 500)
+	viper.SetDefault("AUTOSCALE_SCALE_UP_COOLDOWN", 15)
+	viper.SetDefault("AUTOSCALE_SCALE_DOWN_COOLDOWN", 60)
+	viper.SetDefault("COALESCE_ENABLED", false)
+	viper.SetDefault("COALESCE_WINDOW_MS", 200)
+
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
@@ -43,14 +263,210 @@ func Load() (*Config, error) {
 			ReadTimeout:  viper.GetInt("SERVER_READ_TIMEOUT"),
 			WriteTimeout: viper.GetInt("SERVER_WRITE_TIMEOUT"),
 			IdleTimeout:  viper.GetInt("SERVER_IDLE_TIMEOUT"),
+			MaxBodyBytes: viper.GetInt64("SERVER_MAX_BODY_BYTES"),
+		},
+		GRPC: GRPCConfig{
+			Enabled: viper.GetBool("GRPC_ENABLED"),
+			Port:    viper.GetString("GRPC_PORT"),
 		},
 		Worker: WorkerConfig{
-			Count: viper.GetInt("WORKER_COUNT"),
+			Count:             viper.GetInt("WORKER_COUNT"),
+			MaxCount:          viper.GetInt("WORKER_MAX_COUNT"),
+			RestartBackoffMs:  viper.GetInt("WORKER_RESTART_BACKOFF_MS"),
+			RestartMaxBackoff: viper.GetInt("WORKER_RESTART_MAX_BACKOFF"),
+			EventTimeoutMs:    viper.GetInt("WORKER_EVENT_TIMEOUT_MS"),
 		},
 		Queue: QueueConfig{
+			Driver:     viper.GetString("QUEUE_DRIVER"),
 			BufferSize: viper.GetInt("QUEUE_BUFFER_SIZE"),
 		},
+		RabbitMQ: RabbitMQConfig{
+			URL:        viper.GetString("RABBITMQ_URL"),
+			Exchange:   viper.GetString("RABBITMQ_EXCHANGE"),
+			Queue:      viper.GetString("RABBITMQ_QUEUE"),
+			Prefetch:   viper.GetInt("RABBITMQ_PREFETCH"),
+			MaxRetries: viper.GetInt("RABBITMQ_MAX_RETRIES"),
+		},
+		Kafka: KafkaConfig{
+			Brokers:    splitList(viper.GetString("KAFKA_BROKERS")),
+			Topic:      viper.GetString("KAFKA_TOPIC"),
+			GroupID:    viper.GetString("KAFKA_GROUP_ID"),
+			MaxRetries: viper.GetInt("KAFKA_MAX_RETRIES"),
+		},
+		Outbox: OutboxConfig{
+			Enabled:        viper.GetBool("OUTBOX_ENABLED"),
+			Sink:           viper.GetString("OUTBOX_SINK"),
+			WebhookURL:     viper.GetString("OUTBOX_WEBHOOK_URL"),
+			FilePath:       viper.GetString("OUTBOX_FILE_PATH"),
+			Topic:          viper.GetString("OUTBOX_TOPIC"),
+			PollIntervalMs: viper.GetInt("OUTBOX_POLL_INTERVAL_MS"),
+			BatchSize:      viper.GetInt("OUTBOX_BATCH_SIZE"),
+			MaxAttempts:    viper.GetInt("OUTBOX_MAX_ATTEMPTS"),
+		},
+		Webhook: WebhookConfig{
+			Workers:      viper.GetInt("WEBHOOK_WORKERS"),
+			MaxAttempts:  viper.GetInt("WEBHOOK_MAX_ATTEMPTS"),
+			Timeout:      viper.GetInt("WEBHOOK_TIMEOUT"),
+			RetryDelayMs: viper.GetInt("WEBHOOK_RETRY_DELAY_MS"),
+			LogSize:      viper.GetInt("WEBHOOK_LOG_SIZE"),
+		},
+		Stream: StreamConfig{
+			BufferSize:     viper.GetInt("STREAM_BUFFER_SIZE"),
+			HistorySize:    viper.GetInt("STREAM_HISTORY_SIZE"),
+			AllowedOrigins: splitList(viper.GetString("WS_ALLOWED_ORIGINS")),
+		},
+		GraphQL: GraphQLConfig{
+			MaxDepth:      viper.GetInt("GRAPHQL_MAX_DEPTH"),
+			MaxComplexity: viper.GetInt("GRAPHQL_MAX_COMPLEXITY"),
+		},
+		Auth: AuthConfig{
+			Enabled:        viper.GetBool("AUTH_ENABLED"),
+			APIKeysFile:    viper.GetString("AUTH_API_KEYS_FILE"),
+			ReloadInterval: viper.GetInt("AUTH_RELOAD_INTERVAL"),
+			JWKSFile:       viper.GetString("AUTH_JWKS_FILE"),
+			JWKSURL:        viper.GetString("AUTH_JWKS_URL"),
+			JWKSCacheTTL:   viper.GetInt("AUTH_JWKS_CACHE_TTL"),
+			JWTIssuer:      viper.GetString("AUTH_JWT_ISSUER"),
+			JWTAudience:    viper.GetString("AUTH_JWT_AUDIENCE"),
+			JWTLeeway:      viper.GetInt("AUTH_JWT_LEEWAY"),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:    viper.GetBool("RATE_LIMIT_ENABLED"),
+			ReadRate:   viper.GetFloat64("RATE_LIMIT_READ_RATE"),
+			ReadBurst:  viper.GetInt("RATE_LIMIT_READ_BURST"),
+			WriteRate:  viper.GetFloat64("RATE_LIMIT_WRITE_RATE"),
+			WriteBurst: viper.GetInt("RATE_LIMIT_WRITE_BURST"),
+			TrustProxy: viper.GetBool("RATE_LIMIT_TRUST_PROXY"),
+		},
+		TLS: TLSConfig{
+			Enabled:        viper.GetBool("TLS_ENABLED"),
+			CertFile:       viper.GetString("TLS_CERT_FILE"),
+			KeyFile:        viper.GetString("TLS_KEY_FILE"),
+			ClientCAFile:   viper.GetString("TLS_CLIENT_CA_FILE"),
+			ClientAuth:     viper.GetString("TLS_CLIENT_AUTH"),
+			ReloadInterval: viper.GetInt("TLS_RELOAD_INTERVAL"),
+		},
+		Tracing: TracingConfig{
+			Exporter:    viper.GetString("TRACING_EXPORTER"),
+			FilePath:    viper.GetString("TRACING_FILE_PATH"),
+			ServiceName: viper.GetString("TRACING_SERVICE_NAME"),
+			SampleRatio: viper.GetFloat64("TRACING_SAMPLE_RATIO"),
+		},
+		Health: HealthConfig{
+			CheckTimeout:    viper.GetInt("HEALTH_CHECK_TIMEOUT_MS"),
+			QueueSaturation: viper.GetFloat64("HEALTH_QUEUE_SATURATION"),
+			ShutdownDelay:   viper.GetInt("HEALTH_SHUTDOWN_DELAY"),
+		},
+		Shutdown: ShutdownConfig{
+			DrainTimeout: viper.GetInt("SHUTDOWN_DRAIN_TIMEOUT"),
+			SpillFile:    viper.GetString("SHUTDOWN_SPILL_FILE"),
+		},
+		Autoscale: AutoscaleConfig{
+			Enabled:           viper.GetBool("AUTOSCALE_ENABLED"),
+			MinWorkers:        viper.GetInt("AUTOSCALE_MIN_WORKERS"),
+			MaxWorkers:        viper.GetInt("AUTOSCALE_MAX_WORKERS"),
+			Interval:          viper.GetInt("AUTOSCALE_INTERVAL"),
+			TargetQueueDepth:  viper.GetFloat64("AUTOSCALE_TARGET_QUEUE_DEPTH"),
+			TargetLatencyMs:   viper.GetInt("AUTOSCALE_TARGET_LATENCY_MS"),
+			ScaleUpCooldown:   viper.GetInt("AUTOSCALE_SCALE_UP_COOLDOWN"),
+			ScaleDownCooldown: viper.GetInt("AUTOSCALE_SCALE_DOWN_COOLDOWN"),
+		},
+		Coalesce: CoalesceConfig{
+			Enabled:  viper.GetBool("COALESCE_ENABLED"),
+			WindowMs: viper.GetInt("COALESCE_WINDOW_MS"),
+		},
+	}
+
+	if wc := config.Worker; wc.MaxCount < wc.Count {
+		return nil, fmt.Errorf("WORKER_MAX_COUNT must be at least WORKER_COUNT")
+	}
+
+	if oc := config.Outbox; oc.Enabled && oc.MaxAttempts < 1 {
+		return nil, fmt.Errorf("OUTBOX_MAX_ATTEMPTS must be at least 1")
+	}
+
+	if config.Worker.EventTimeoutMs < 0 {
+		return nil, fmt.Errorf("WORKER_EVENT_TIMEOUT_MS must not be negative")
+	}
+
+	if ac := config.Autoscale; ac.Enabled {
+		if ac.MinWorkers < 1 || ac.MaxWorkers < ac.MinWorkers || ac.MaxWorkers > config.Worker.MaxCount {
+			return nil, fmt.Errorf("autoscaling needs 1 <= AUTOSCALE_MIN_WORKERS <= AUTOSCALE_MAX_WORKERS <= WORKER_MAX_COUNT")
+		}
+		if ac.Interval < 1 {
+			return nil, fmt.Errorf("AUTOSCALE_INTERVAL must be at least 1 second")
+		}
+	}
+
+	if cc := config.Coalesce; cc.Enabled {
+		if cc.WindowMs < 1 {
+			return nil, fmt.Errorf("COALESCE_WINDOW_MS must be at least 1")
+		}
+		// Kafka hands out one event per partition at a time, so a held event
+		// would stall its partition for the whole window.
+		if config.Queue.Driver == "kafka" {
+			return nil, fmt.Errorf("COALESCE_ENABLED is not supported with QUEUE_DRIVER=kafka")
+		}
+	}
+
+	if rl := config.RateLimit; rl.Enabled && (rl.ReadRate <= 0 || rl.WriteRate <= 0 || rl.ReadBurst < 1 || rl.WriteBurst < 1) {
+		return nil, fmt.Errorf("rate limit rates must be positive and bursts at least 1")
+	}
+
+	if hc := config.Health; hc.QueueSaturation <= 0 || hc.QueueSaturation > 1 {
+		return nil, fmt.Errorf("HEALTH_QUEUE_SATURATION must be in (0, 1], got %v", hc.QueueSaturation)
+	}
+
+	// An empty AUTH_API_KEYS_FILE turns API keys off, but viper ignores empty
+	// environment variables and would fall back to the default file.
+	if path, ok := os.LookupEnv("AUTH_API_KEYS_FILE"); ok {
+		config.Auth.APIKeysFile = path
+	}
+
+	roles, err := splitPairs(viper.GetString("AUTH_CLIENT_CERT_ROLES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUTH_CLIENT_CERT_ROLES: %w", err)
+	}
+	config.Auth.ClientCertRoles = roles
+
+	if tc := config.TLS; tc.Enabled {
+		switch tc.ClientAuth {
+		case "none":
+		case "request", "require":
+			if tc.ClientCAFile == "" {
+				return nil, fmt.Errorf("TLS_CLIENT_AUTH=%s requires TLS_CLIENT_CA_FILE", tc.ClientAuth)
+			}
+		default:
+			return nil, fmt.Errorf("TLS_CLIENT_AUTH must be none, request or require, got %q", tc.ClientAuth)
+		}
+		if tc.CertFile == "" || tc.KeyFile == "" {
+			return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE are required when TLS is enabled")
+		}
 	}
 
 	return config, nil
 }
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// splitPairs parses "key=value,key=value".
+func splitPairs(value string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, item := range splitList(value) {
+		k, v, ok := strings.Cut(item, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("%q is not of the form key=value", item)
+		}
+		pairs[k] = v
+	}
+	return pairs, nil
+}
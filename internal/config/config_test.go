@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadWith(t *testing.T, env string) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte(env), 0o600))
+	t.Chdir(dir)
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	return cfg
+}
+
+func TestLoad_APIKeysFile(t *testing.T) {
+	t.Run("defaults to api-keys.json", func(t *testing.T) {
+		cfg := loadWith(t, "AUTH_ENABLED=true\n")
+		assert.Equal(t, "api-keys.json", cfg.Auth.APIKeysFile)
+	})
+
+	t.Run("empty in the env file", func(t *testing.T) {
+		cfg := loadWith(t, "AUTH_ENABLED=true\nAUTH_API_KEYS_FILE=\n")
+		assert.Empty(t, cfg.Auth.APIKeysFile)
+	})
+
+	t.Run("empty in the environment", func(t *testing.T) {
+		t.Setenv("AUTH_API_KEYS_FILE", "")
+		cfg := loadWith(t, "AUTH_ENABLED=true\nAUTH_API_KEYS_FILE=keys.json\n")
+		assert.Empty(t, cfg.Auth.APIKeysFile)
+	})
+
+	t.Run("set in the environment", func(t *testing.T) {
+		t.Setenv("AUTH_API_KEYS_FILE", "/etc/vfc/keys.json")
+		cfg := loadWith(t, "AUTH_ENABLED=true\n")
+		assert.Equal(t, "/etc/vfc/keys.json", cfg.Auth.APIKeysFile)
+	})
+}
@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+type ChangeType string
+
+const (
+	ChangeCreated ChangeType = "created"
+	ChangeUpdated ChangeType = "updated"
+)
+
+// ProductChange is the ProductChanged notification recorded whenever a
+// product is saved. The ID is encoded as a JSON string because it can exceed
+// the integers JavaScript represents exactly.
+type ProductChange struct {
+	ID         uint64     `json:"id,string"`
+	Type       ChangeType `json:"type"`
+	Product    *Product   `json:"product"`
+	OccurredAt time.Time  `json:"occurred_at"`
+}
+
+func NewProductChange(changeType ChangeType, product *Product) *ProductChange {
+	return &ProductChange{
+		Type:       changeType,
+		Product:    product,
+		OccurredAt: time.Now(),
+	}
+}
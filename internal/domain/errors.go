@@ -0,0 +1,45 @@
+package domain
+
+import "strings"
+
+// Field error codes are part of the API contract; clients match on them
+// rather than on messages.
+const (
+	CodeRequired = "required"
+	CodeNegative = "negative"
+	CodeInvalid  = "invalid"
+	CodeUnknown  = "unknown"
+)
+
+// FieldError describes one problem with one field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError collects every problem found while validating a value.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Add records a problem with field.
+func (e *ValidationError) Add(field, code, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Err returns e if any problems were recorded, or nil.
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
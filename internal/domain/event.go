@@ -7,6 +7,13 @@ type Event struct {
 	Price     float64   `json:"price"`
 	Stock     int       `json:"stock"`
 	Timestamp time.Time `json:"timestamp"`
+	// Principal identifies who submitted the event, for auditing.
+	Principal string `json:"principal,omitempty"`
+	// RequestID is the X-Request-ID of the request that submitted the event.
+	RequestID string `json:"request_id,omitempty"`
+	// TraceContext carries the W3C trace context of the request that
+	// submitted the event, so processing joins the same trace.
+	TraceContext map[string]string `json:"trace_context,omitempty"`
 }
 
 func NewEvent(productID string, price float64, stock int) *Event {
@@ -18,6 +25,25 @@ func NewEvent(productID string, price float64, stock int) *Event {
 	}
 }
 
+// Validate reports every problem with the event as a *ValidationError.
+func (e *Event) Validate() error {
+	var verr ValidationError
+
+	if e.ProductID == "" {
+		verr.Add("product_id", CodeRequired, "product_id is required")
+	}
+
+	if e.Price < 0 {
+		verr.Add("price", CodeNegative, "price must be non-negative")
+	}
+
+	if e.Stock < 0 {
+		verr.Add("stock", CodeNegative, "stock must be non-negative")
+	}
+
+	return verr.Err()
+}
+
 func (e *Event) ToProduct() *Product {
 	return &Product{
 		ProductID: e.ProductID,
@@ -0,0 +1,181 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/raufhm/vfc/internal/service"
+)
+
+// Limits bounds how expensive a single query may be. Depth counts nested
+// selection sets; complexity counts fields, with fields under a list
+// multiplied by the list's "first" argument (or the default page size).
+type Limits struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+type Request struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+
+	// ReadOnly rejects mutations, e.g. for requests sent with GET.
+	ReadOnly bool `json:"-"`
+}
+
+type Executor struct {
+	schema graphql.Schema
+	limits Limits
+}
+
+func NewExecutor(svc *service.ProductService, limits Limits) (*Executor, error) {
+	schema, err := newSchema(svc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build graphql schema: %w", err)
+	}
+
+	return &Executor{
+		schema: schema,
+		limits: limits,
+	}, nil
+}
+
+// Execute checks the query against the limits and runs it. rejected is true
+// when the query was not executed because it could not be parsed or was over
+// a limit.
+func (e *Executor) Execute(ctx context.Context, req Request) (result *graphql.Result, rejected bool) {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query)})})
+	if err != nil {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)}}, true
+	}
+
+	if err := e.check(doc, req); err != nil {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(err.Error())}}, true
+	}
+
+	return graphql.Do(graphql.Params{
+		Schema:         e.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        ctx,
+	}), false
+}
+
+func (e *Executor) check(doc *ast.Document, req Request) error {
+	a := analyzer{
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: req.Variables,
+	}
+	for _, def := range doc.Definitions {
+		if frag, ok := def.(*ast.FragmentDefinition); ok {
+			a.fragments[frag.Name.Value] = frag
+		}
+	}
+
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+
+		if req.ReadOnly && op.Operation == ast.OperationTypeMutation {
+			return fmt.Errorf("mutations are not allowed with GET")
+		}
+
+		depth, complexity := a.measure(op.SelectionSet, 1, nil)
+		if e.limits.MaxDepth > 0 && depth > e.limits.MaxDepth {
+			return fmt.Errorf("query depth %d exceeds the limit of %d", depth, e.limits.MaxDepth)
+		}
+		if e.limits.MaxComplexity > 0 && complexity > e.limits.MaxComplexity {
+			return fmt.Errorf("query complexity %d exceeds the limit of %d", complexity, e.limits.MaxComplexity)
+		}
+	}
+
+	return nil
+}
+
+type analyzer struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// measure returns the depth and complexity of set. visiting guards against
+// fragment cycles, which validation rejects anyway.
+func (a *analyzer) measure(set *ast.SelectionSet, depth int, visiting map[string]bool) (int, int) {
+	if set == nil {
+		return depth - 1, 0
+	}
+
+	maxDepth, complexity := depth, 0
+	for _, sel := range set.Selections {
+		var d, c int
+
+		switch s := sel.(type) {
+		case *ast.Field:
+			d, c = a.measure(s.SelectionSet, depth+1, visiting)
+			c = 1 + a.multiplier(s)*c
+
+		case *ast.InlineFragment:
+			d, c = a.measure(s.SelectionSet, depth, visiting)
+
+		case *ast.FragmentSpread:
+			frag, ok := a.fragments[s.Name.Value]
+			if !ok || visiting[s.Name.Value] {
+				continue
+			}
+			next := map[string]bool{s.Name.Value: true}
+			for k := range visiting {
+				next[k] = true
+			}
+			d, c = a.measure(frag.SelectionSet, depth, next)
+		}
+
+		if d > maxDepth {
+			maxDepth = d
+		}
+		complexity += c
+	}
+
+	return maxDepth, complexity
+}
+
+// multiplier estimates how many items a list field returns from its "first"
+// argument.
+func (a *analyzer) multiplier(field *ast.Field) int {
+	if field.Name.Value != "products" {
+		return 1
+	}
+
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil && n > 0 {
+				return n
+			}
+		case *ast.Variable:
+			switch n := a.variables[v.Name.Value].(type) {
+			case float64:
+				if n > 0 {
+					return int(n)
+				}
+			case int:
+				if n > 0 {
+					return n
+				}
+			}
+		}
+	}
+
+	return defaultPageSize
+}
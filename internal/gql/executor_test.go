@@ -0,0 +1,130 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/queue"
+	"github.com/raufhm/vfc/internal/repository"
+	"github.com/raufhm/vfc/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func setupTest(t *testing.T, limits Limits) (*Executor, *repository.InMemoryRepository, *queue.InMemoryQueue) {
+	t.Helper()
+
+	logger, _ := zap.NewDevelopment()
+	repo := repository.NewInMemoryRepository()
+	q := queue.NewInMemoryQueue(10, logger)
+	executor, err := NewExecutor(service.NewProductService(repo, q), limits)
+	require.NoError(t, err)
+	return executor, repo, q
+}
+
+func resultJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestExecute_ProductLookup(t *testing.T) {
+	executor, repo, _ := setupTest(t, Limits{})
+	require.NoError(t, repo.Save(context.Background(), domain.NewProduct("abc123", 49.99, 100)))
+
+	result, rejected := executor.Execute(context.Background(), Request{
+		Query: `{ product(id: "abc123") { productId price } missing: product(id: "nope") { price } }`,
+	})
+	require.False(t, rejected)
+	require.Empty(t, result.Errors)
+	assert.JSONEq(t, `{"product":{"productId":"abc123","price":49.99},"missing":null}`, resultJSON(t, result.Data))
+}
+
+func TestExecute_ProductsWithFilters(t *testing.T) {
+	executor, repo, _ := setupTest(t, Limits{})
+	require.NoError(t, repo.Save(context.Background(), domain.NewProduct("a", 5, 0)))
+	require.NoError(t, repo.Save(context.Background(), domain.NewProduct("b", 15, 10)))
+	require.NoError(t, repo.Save(context.Background(), domain.NewProduct("c", 25, 10)))
+
+	result, rejected := executor.Execute(context.Background(), Request{
+		Query:     `query($min: Float) { products(minPrice: $min, inStockOnly: true, first: 1) { productId } }`,
+		Variables: map[string]interface{}{"min": 10.0},
+	})
+	require.False(t, rejected)
+	require.Empty(t, result.Errors)
+	assert.JSONEq(t, `{"products":[{"productId":"b"}]}`, resultJSON(t, result.Data))
+}
+
+func TestExecute_SubmitProductUpdate(t *testing.T) {
+	executor, _, q := setupTest(t, Limits{})
+
+	result, rejected := executor.Execute(context.Background(), Request{
+		Query: `mutation { submitProductUpdate(productId: "abc123", price: 9.5, stock: 3) { accepted productId } }`,
+	})
+	require.False(t, rejected)
+	require.Empty(t, result.Errors)
+	assert.JSONEq(t, `{"submitProductUpdate":{"accepted":true,"productId":"abc123"}}`, resultJSON(t, result.Data))
+
+	event, err := q.Dequeue()
+	require.NoError(t, err)
+	assert.Equal(t, 9.5, event.Price)
+
+	result, _ = executor.Execute(context.Background(), Request{
+		Query: `mutation { submitProductUpdate(productId: "abc123", price: -1, stock: 3) { accepted } }`,
+	})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "price must be non-negative", result.Errors[0].Message)
+}
+
+func TestExecute_Limits(t *testing.T) {
+	executor, _, _ := setupTest(t, Limits{MaxDepth: 2, MaxComplexity: 50})
+
+	tests := []struct {
+		name    string
+		query   string
+		message string
+	}{
+		{
+			name:    "depth",
+			query:   `{ product(id: "a") { ... on Product { productId } } __schema { types { fields { name } } } }`,
+			message: "query depth 4 exceeds the limit of 2",
+		},
+		{
+			name:    "complexity from default page size",
+			query:   `{ products { productId } }`,
+			message: "query complexity 101 exceeds the limit of 50",
+		},
+		{
+			name:    "complexity through fragments",
+			query:   `{ products(first: 20) { ...f } } fragment f on Product { productId price stock }`,
+			message: "query complexity 61 exceeds the limit of 50",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, rejected := executor.Execute(context.Background(), Request{Query: tt.query})
+			assert.True(t, rejected)
+			require.Len(t, result.Errors, 1)
+			assert.Equal(t, tt.message, result.Errors[0].Message)
+		})
+	}
+
+	result, rejected := executor.Execute(context.Background(), Request{Query: `{ products(first: 10) { productId price } }`})
+	assert.False(t, rejected)
+	assert.Empty(t, result.Errors)
+}
+
+func TestExecute_ReadOnlyRejectsMutations(t *testing.T) {
+	executor, _, _ := setupTest(t, Limits{})
+
+	_, rejected := executor.Execute(context.Background(), Request{
+		Query:    `mutation { submitProductUpdate(productId: "a", price: 1, stock: 1) { accepted } }`,
+		ReadOnly: true,
+	})
+	assert.True(t, rejected)
+}
@@ -0,0 +1,157 @@
+package gql
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/graphql-go/graphql"
+	"github.com/raufhm/vfc/internal/auth"
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/ratelimit"
+	"github.com/raufhm/vfc/internal/repository"
+	"github.com/raufhm/vfc/internal/service"
+)
+
+const defaultPageSize = 100
+
+var productType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Product",
+	Fields: graphql.Fields{
+		"productId": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*domain.Product).ProductID, nil
+			},
+		},
+		"price": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Float),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*domain.Product).Price, nil
+			},
+		},
+		"stock": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Int),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*domain.Product).Stock, nil
+			},
+		},
+		"updatedAt": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.DateTime),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*domain.Product).UpdatedAt, nil
+			},
+		},
+	},
+})
+
+var submitResultType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "SubmitResult",
+	Description: "Events are applied asynchronously; accepted means the event was queued.",
+	Fields: graphql.Fields{
+		"accepted":  &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"productId": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+// newSchema builds the schema over ProductService:
+//
+//	product(id): single lookup
+//	products(ids, minPrice, maxPrice, inStockOnly, first, after): filtered list ordered by ID
+//	submitProductUpdate(productId, price, stock): enqueue an update event
+func newSchema(svc *service.ProductService) (graphql.Schema, error) {
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"product": &graphql.Field{
+				Type: productType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					product, err := svc.GetProduct(p.Context, p.Args["id"].(string))
+					if errors.Is(err, repository.ErrProductNotFound) {
+						return nil, nil
+					}
+					return product, err
+				},
+			},
+			"products": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(productType))),
+				Args: graphql.FieldConfigArgument{
+					"ids":         &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+					"minPrice":    &graphql.ArgumentConfig{Type: graphql.Float},
+					"maxPrice":    &graphql.ArgumentConfig{Type: graphql.Float},
+					"inStockOnly": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+					"first":       &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageSize},
+					"after":       &graphql.ArgumentConfig{Type: graphql.String, Description: "Return products with IDs after this one."},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					first := p.Args["first"].(int)
+					if first < 0 || first > defaultPageSize {
+						return nil, errors.New("first must be between 0 and 100")
+					}
+
+					filter := repository.ListFilter{
+						InStockOnly: p.Args["inStockOnly"].(bool),
+						Limit:       first,
+					}
+					if ids, ok := p.Args["ids"].([]interface{}); ok {
+						for _, id := range ids {
+							filter.ProductIDs = append(filter.ProductIDs, id.(string))
+						}
+					}
+					if v, ok := p.Args["minPrice"].(float64); ok {
+						filter.MinPrice = &v
+					}
+					if v, ok := p.Args["maxPrice"].(float64); ok {
+						filter.MaxPrice = &v
+					}
+					if v, ok := p.Args["after"].(string); ok {
+						filter.After = v
+					}
+
+					return svc.ListProducts(p.Context, filter)
+				},
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"submitProductUpdate": &graphql.Field{
+				Type: graphql.NewNonNull(submitResultType),
+				Args: graphql.FieldConfigArgument{
+					"productId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"price":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Float)},
+					"stock":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if !auth.Allowed(p.Context, auth.RoleWriter) {
+						return nil, errors.New("writer role required to submit product updates")
+					}
+					if limit := ratelimit.AllowWrite(p.Context); !limit.Allowed {
+						return nil, fmt.Errorf("rate limit exceeded, retry after %ds", limit.RetryAfterSeconds())
+					}
+					event := domain.NewEvent(p.Args["productId"].(string), p.Args["price"].(float64), p.Args["stock"].(int))
+					event.Principal = auth.PrincipalID(p.Context)
+					if err := event.Validate(); err != nil {
+						return nil, err
+					}
+					if err := svc.EnqueueProductUpdate(p.Context, event); err != nil {
+						return nil, errors.New("failed to enqueue event")
+					}
+					return map[string]interface{}{
+						"accepted":  true,
+						"productId": event.ProductID,
+					}, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    query,
+		Mutation: mutation,
+	})
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/raufhm/vfc/internal/auth"
+	"go.uber.org/zap"
+)
+
+const (
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+)
+
+// Authorizer authenticates requests and enforces the role each route
+// requires. Register it ahead of handlers that read the request body.
+type Authorizer struct {
+	authenticator auth.Authenticator
+	logger        *zap.Logger
+}
+
+func NewAuthorizer(authenticator auth.Authenticator, logger *zap.Logger) *Authorizer {
+	return &Authorizer{
+		authenticator: authenticator,
+		logger:        logger,
+	}
+}
+
+func (a *Authorizer) RegisterRoutes(router *mux.Router) {
+	router.Use(a.authorize)
+}
+
+func (a *Authorizer) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		required := auth.RoleAdmin
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				required = RequiredRole(r.Method, template)
+			}
+		}
+
+		principal, err := a.authenticator.Authenticate(r)
+		if err != nil {
+			if required == auth.RoleNone {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !errors.Is(err, auth.ErrNoCredentials) {
+				a.logger.Warn("Authentication failed",
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.Error(err))
+			}
+			if c, ok := a.authenticator.(auth.Challenger); ok {
+				w.Header().Set("WWW-Authenticate", c.Challenge())
+			}
+			writeError(w, r, a.logger, err)
+			return
+		}
+
+		if !principal.Role.Allows(required) {
+			a.logger.Warn("Access denied",
+				zap.String("principal", principal.ID),
+				zap.String("role", string(principal.Role)),
+				zap.String("required_role", string(required)),
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path))
+			writeError(w, r, a.logger, auth.ErrForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
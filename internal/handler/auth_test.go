@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/raufhm/vfc/internal/auth"
+	"github.com/raufhm/vfc/internal/gql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// staticKeys authenticates the keys it was built with.
+type staticKeys map[string]*auth.Principal
+
+func (k staticKeys) Authenticate(r *http.Request) (*auth.Principal, error) {
+	key := r.Header.Get(auth.APIKeyHeader)
+	if key == "" {
+		return nil, auth.ErrNoCredentials
+	}
+	p, ok := k[key]
+	if !ok {
+		return nil, auth.ErrInvalidCredentials
+	}
+	return p, nil
+}
+
+func (k staticKeys) Challenge() string {
+	return `ApiKey header="` + auth.APIKeyHeader + `"`
+}
+
+var testKeys = staticKeys{
+	"reader-key": {ID: "dashboard", Role: auth.RoleReader},
+	"writer-key": {ID: "importer", Role: auth.RoleWriter},
+	"admin-key":  {ID: "ops", Role: auth.RoleAdmin},
+}
+
+func TestRouteRoles_CoverEveryRoute(t *testing.T) {
+	router, _, _, _ := setupOpenAPITest(t)
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, _ := route.GetPathTemplate()
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			_, ok := routeRoles[method+" "+template]
+			assert.True(t, ok, "%s %s has no entry in routeRoles", method, template)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestAuthorizer(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	handler, _, _ := setupTest()
+	router := SetupRouter(handler, logger, NewAuthorizer(testKeys, logger))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		status int
+		code   string
+	}{
+		{"public without key", "GET", "/health", "", http.StatusOK, ""},
+		{"public with bad key", "GET", "/health", "wrong", http.StatusOK, ""},
+		{"missing key", "GET", "/products/abc", "", http.StatusUnauthorized, CodeUnauthorized},
+		{"unknown key", "GET", "/products/abc", "wrong", http.StatusUnauthorized, CodeUnauthorized},
+		{"reader reads", "GET", "/products/abc", "reader-key", http.StatusNotFound, CodeProductNotFound},
+		{"reader writes", "POST", "/events", "reader-key", http.StatusForbidden, CodeForbidden},
+		{"writer writes", "POST", "/events", "writer-key", http.StatusAccepted, ""},
+		{"admin writes", "POST", "/events", "admin-key", http.StatusAccepted, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := jsonRequest(tt.method, tt.path, `{"product_id":"abc","price":1,"stock":1}`)
+			if tt.key != "" {
+				req.Header.Set(auth.APIKeyHeader, tt.key)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.status, rr.Code)
+			if tt.status == http.StatusUnauthorized {
+				assert.Equal(t, `ApiKey header="X-API-Key"`, rr.Header().Get("WWW-Authenticate"))
+			}
+			if tt.code != "" {
+				var problem Problem
+				require.NoError(t, json.NewDecoder(rr.Body).Decode(&problem))
+				assert.Equal(t, tt.code, problem.Code)
+			}
+		})
+	}
+}
+
+func TestAuthorizer_WebhooksRequireAdmin(t *testing.T) {
+	_, router := setupWebhookTest()
+	logger, _ := zap.NewDevelopment()
+	NewAuthorizer(testKeys, logger).RegisterRoutes(router.(*mux.Router))
+
+	for key, status := range map[string]int{"writer-key": http.StatusForbidden, "admin-key": http.StatusOK} {
+		req := httptest.NewRequest("GET", "/webhooks", nil)
+		req.Header.Set(auth.APIKeyHeader, key)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, status, rr.Code, key)
+	}
+}
+
+func TestCreateEvent_RecordsPrincipal(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	handler, _, q := setupTest()
+	router := SetupRouter(handler, logger, NewAuthorizer(testKeys, logger))
+
+	req := jsonRequest("POST", "/events", `{"product_id":"abc","price":1,"stock":1}`)
+	req.Header.Set(auth.APIKeyHeader, "writer-key")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusAccepted, rr.Code)
+
+	event := <-q.GetChannel()
+	assert.Equal(t, "importer", event.Principal)
+}
+
+func TestGraphQL_MutationRequiresWriter(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	handler, _, q := setupTest()
+	executor, err := gql.NewExecutor(handler.service, gql.Limits{MaxDepth: 5})
+	require.NoError(t, err)
+	router := SetupRouter(handler, logger, NewAuthorizer(testKeys, logger), NewGraphQLHandler(executor, logger))
+
+	mutation := `{"query":"mutation { submitProductUpdate(productId: \"abc\", price: 1, stock: 1) { accepted } }"}`
+
+	req := jsonRequest("POST", "/graphql", mutation)
+	req.Header.Set(auth.APIKeyHeader, "reader-key")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Contains(t, rr.Body.String(), "writer role required")
+	assert.Empty(t, q.GetChannel())
+
+	req = jsonRequest("POST", "/graphql", mutation)
+	req.Header.Set(auth.APIKeyHeader, "writer-key")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.JSONEq(t, `{"data":{"submitProductUpdate":{"accepted":true}}}`, rr.Body.String())
+
+	event := <-q.GetChannel()
+	assert.Equal(t, "importer", event.Principal)
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/raufhm/vfc/internal/domain"
+	"go.uber.org/zap"
+)
+
+const (
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeBodyTooLarge         = "body_too_large"
+)
+
+// DefaultMaxBodyBytes bounds request bodies when no limit is configured.
+const DefaultMaxBodyBytes = 1 << 20
+
+// RequestDecoder rejects request bodies that are not JSON or exceed the size
+// limit before any other handler reads them. Register it ahead of
+// OpenAPIHandler, whose validation reads the whole body.
+type RequestDecoder struct {
+	maxBytes int64
+	logger   *zap.Logger
+}
+
+func NewRequestDecoder(maxBytes int64, logger *zap.Logger) *RequestDecoder {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBodyBytes
+	}
+	return &RequestDecoder{
+		maxBytes: maxBytes,
+		logger:   logger,
+	}
+}
+
+func (d *RequestDecoder) RegisterRoutes(router *mux.Router) {
+	router.Use(d.limit)
+}
+
+func (d *RequestDecoder) limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hasBody(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if p := checkContentType(r); p != nil {
+			writeProblem(w, r, d.logger, p)
+			return
+		}
+
+		if r.ContentLength > d.maxBytes {
+			writeProblem(w, r, d.logger, bodyTooLarge(d.maxBytes))
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, d.maxBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decodeJSON decodes exactly one JSON document from the request body into v,
+// rejecting unknown fields. It returns the problem to send if the body is
+// not acceptable.
+func decodeJSON(r *http.Request, v interface{}) *Problem {
+	if p := checkContentType(r); p != nil {
+		return p
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return decodeProblem(err)
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return NewProblem(http.StatusBadRequest, CodeInvalidBody,
+			"Request body must contain a single JSON document")
+	}
+
+	return nil
+}
+
+func decodeProblem(err error) *Problem {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return NewProblem(http.StatusBadRequest, CodeInvalidBody, "Request body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return NewProblem(http.StatusBadRequest, CodeInvalidBody, "Request body contains truncated JSON")
+	case errors.As(err, &syntaxErr):
+		return NewProblem(http.StatusBadRequest, CodeInvalidBody,
+			fmt.Sprintf("Request body contains malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.As(err, &maxBytesErr):
+		return bodyTooLarge(maxBytesErr.Limit)
+	case errors.As(err, &typeErr):
+		var verr domain.ValidationError
+		verr.Add(typeErr.Field, domain.CodeInvalid,
+			fmt.Sprintf("%s must be of type %s", typeErr.Field, jsonTypeName(typeErr)))
+		return ProblemFor(&verr)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for unknown fields.
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		var verr domain.ValidationError
+		verr.Add(field, domain.CodeUnknown, fmt.Sprintf("%s is not a known field", field))
+		return ProblemFor(&verr)
+	default:
+		return NewProblem(http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+	}
+}
+
+func checkContentType(r *http.Request) *Problem {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return NewProblem(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType,
+			"Content-Type must be application/json")
+	}
+	return nil
+}
+
+func hasBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
+
+func bodyTooLarge(limit int64) *Problem {
+	return NewProblem(http.StatusRequestEntityTooLarge, CodeBodyTooLarge,
+		fmt.Sprintf("Request body must not exceed %d bytes", limit))
+}
+
+func jsonTypeName(err *json.UnmarshalTypeError) string {
+	switch err.Type.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	default:
+		return err.Type.String()
+	}
+}
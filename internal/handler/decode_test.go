@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCreateEvent_StrictDecoding(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+		detail      string
+		field       string
+	}{
+		{"missing content type", "", `{"product_id":"a","price":1,"stock":1}`, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "", ""},
+		{"wrong content type", "text/plain", `{"product_id":"a","price":1,"stock":1}`, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "", ""},
+		{"content type with charset", "application/json; charset=utf-8", `{"product_id":"a","price":1,"stock":1}`, http.StatusAccepted, "", "", ""},
+		{"empty body", "application/json", ``, http.StatusBadRequest, CodeInvalidBody, "Request body is empty", ""},
+		{"malformed", "application/json", `{"product_id":"a",}`, http.StatusBadRequest, CodeInvalidBody, "Request body contains malformed JSON at offset 19", ""},
+		{"truncated", "application/json", `{"product_id":"a"`, http.StatusBadRequest, CodeInvalidBody, "Request body contains truncated JSON", ""},
+		{"trailing document", "application/json", `{"product_id":"a","price":1,"stock":1}{}`, http.StatusBadRequest, CodeInvalidBody, "Request body must contain a single JSON document", ""},
+		{"trailing garbage", "application/json", `{"product_id":"a","price":1,"stock":1} x`, http.StatusBadRequest, CodeInvalidBody, "Request body must contain a single JSON document", ""},
+		{"unknown field", "application/json", `{"product_id":"a","price":1,"stock":1,"colour":"red"}`, http.StatusBadRequest, CodeValidationFailed, "", "colour"},
+		{"wrong type", "application/json", `{"product_id":"a","price":"free","stock":1}`, http.StatusBadRequest, CodeValidationFailed, "", "price"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _, _ := setupTest()
+
+			req := httptest.NewRequest("POST", "/events", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.CreateEvent(rr, req)
+
+			assert.Equal(t, tt.status, rr.Code)
+			if tt.code == "" {
+				return
+			}
+
+			var problem Problem
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&problem))
+			assert.Equal(t, tt.code, problem.Code)
+			if tt.detail != "" {
+				assert.Equal(t, tt.detail, problem.Detail)
+			}
+			if tt.field != "" {
+				require.Len(t, problem.Errors, 1)
+				assert.Equal(t, tt.field, problem.Errors[0].Field)
+			}
+		})
+	}
+}
+
+func TestCreateEvent_WrongTypeMessage(t *testing.T) {
+	handler, _, _ := setupTest()
+
+	req := jsonRequest("POST", "/events", `{"product_id":"a","price":1,"stock":1.5}`)
+	rr := httptest.NewRecorder()
+
+	handler.CreateEvent(rr, req)
+
+	var problem Problem
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&problem))
+	assert.Equal(t, []domain.FieldError{
+		{Field: "stock", Code: domain.CodeInvalid, Message: "stock must be of type integer"},
+	}, problem.Errors)
+}
+
+func TestRequestDecoder_LimitsBodySize(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	handler, _, q := setupTest()
+	router := SetupRouter(handler, logger, NewRequestDecoder(32, logger))
+
+	body := `{"product_id":"` + strings.Repeat("a", 64) + `","price":1,"stock":1}`
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, jsonRequest("POST", "/events", body))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+
+	// Without a Content-Length the limit is enforced while reading.
+	req := jsonRequest("POST", "/events", body)
+	req.ContentLength = -1
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+
+	var problem Problem
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&problem))
+	assert.Equal(t, CodeBodyTooLarge, problem.Code)
+	assert.Equal(t, "Request body must not exceed 32 bytes", problem.Detail)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, jsonRequest("POST", "/events", `{"product_id":"a","price":1,"stock":1}`))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code, "39 bytes is over the limit")
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, jsonRequest("POST", "/events", `{"product_id":"a","stock":1}`))
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Len(t, q.GetChannel(), 1)
+}
+
+func TestRequestDecoder_RejectsNonJSONBeforeHandlers(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	handler, _, _ := setupTest()
+	router := SetupRouter(handler, logger, NewRequestDecoder(0, logger))
+
+	req := httptest.NewRequest("POST", "/events", strings.NewReader(`product_id=a`))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/gql"
+	"go.uber.org/zap"
+)
+
+type GraphQLHandler struct {
+	executor *gql.Executor
+	logger   *zap.Logger
+}
+
+func NewGraphQLHandler(executor *gql.Executor, logger *zap.Logger) *GraphQLHandler {
+	return &GraphQLHandler{
+		executor: executor,
+		logger:   logger,
+	}
+}
+
+func (h *GraphQLHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/graphql", h.Query).Methods("GET", "POST")
+}
+
+// Query runs a GraphQL request sent as a JSON body, or as query parameters
+// on GET. Queries over the depth or complexity limit are rejected with 400.
+func (h *GraphQLHandler) Query(w http.ResponseWriter, r *http.Request) {
+	var req gql.Request
+
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		req.ReadOnly = true
+		if vars := r.URL.Query().Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				var verr domain.ValidationError
+				verr.Add("variables", domain.CodeInvalid, "variables must be a JSON object")
+				writeError(w, r, h.logger, &verr)
+				return
+			}
+		}
+	} else if p := decodeJSON(r, &req); p != nil {
+		writeProblem(w, r, h.logger, p)
+		return
+	}
+
+	if req.Query == "" {
+		var verr domain.ValidationError
+		verr.Add("query", domain.CodeRequired, "query is required")
+		writeError(w, r, h.logger, &verr)
+		return
+	}
+
+	result, rejected := h.executor.Execute(r.Context(), req)
+	if rejected {
+		writeJSON(w, h.logger, result, http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, h.logger, result, http.StatusOK)
+}
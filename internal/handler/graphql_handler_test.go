@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/gql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestGraphQL(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	productHandler, repo, _ := setupTest()
+	executor, err := gql.NewExecutor(productHandler.service, gql.Limits{MaxDepth: 3})
+	require.NoError(t, err)
+	router := SetupRouter(productHandler, logger, NewGraphQLHandler(executor, logger))
+
+	require.NoError(t, repo.Save(context.Background(), domain.NewProduct("abc123", 49.99, 100)))
+
+	body, _ := json.Marshal(gql.Request{Query: `{ product(id: "abc123") { stock } }`})
+	req := httptest.NewRequest("POST", "/graphql", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"data":{"product":{"stock":100}}}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape(`{ __schema { types { fields { name } } } }`), nil))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "query depth 4 exceeds the limit of 3")
+}
@@ -2,12 +2,12 @@ package handler
 
 import (
 	"encoding/json"
-	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/raufhm/vfc/internal/auth"
 	"github.com/raufhm/vfc/internal/domain"
-	"github.com/raufhm/vfc/internal/repository"
+	"github.com/raufhm/vfc/internal/requestid"
 	"github.com/raufhm/vfc/internal/service"
 	"go.uber.org/zap"
 )
@@ -30,42 +30,38 @@ type EventRequest struct {
 	Stock     int     `json:"stock"`
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
+// Validate reports every problem with the request as a *domain.ValidationError.
+func (r EventRequest) Validate() error {
+	return r.ToEvent().Validate()
+}
+
+func (r EventRequest) ToEvent() *domain.Event {
+	return domain.NewEvent(r.ProductID, r.Price, r.Stock)
 }
 
 func (h *ProductHandler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 	var req EventRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendError(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if req.ProductID == "" {
-		h.sendError(w, "product_id is required", http.StatusBadRequest)
-		return
-	}
-
-	if req.Price < 0 {
-		h.sendError(w, "price must be non-negative", http.StatusBadRequest)
+	if p := decodeJSON(r, &req); p != nil {
+		writeProblem(w, r, h.logger, p)
 		return
 	}
 
-	if req.Stock < 0 {
-		h.sendError(w, "stock must be non-negative", http.StatusBadRequest)
+	if err := req.Validate(); err != nil {
+		h.sendError(w, r, err)
 		return
 	}
 
-	event := domain.NewEvent(req.ProductID, req.Price, req.Stock)
+	event := req.ToEvent()
+	event.Principal = auth.PrincipalID(r.Context())
 
-	if err := h.service.EnqueueProductUpdate(event); err != nil {
-		h.logger.Error("Failed to enqueue event", zap.Error(err))
-		h.sendError(w, "Failed to enqueue event", http.StatusInternalServerError)
+	if err := h.service.EnqueueProductUpdate(r.Context(), event); err != nil {
+		requestid.Logger(r.Context(), h.logger).Error("Failed to enqueue event", zap.Error(err))
+		h.sendError(w, r, err)
 		return
 	}
 
-	h.logger.Info("Event enqueued", zap.String("product_id", req.ProductID))
+	requestid.Logger(r.Context(), h.logger).Info("Event enqueued", zap.String("product_id", req.ProductID))
 
 	w.WriteHeader(http.StatusAccepted)
 }
@@ -75,18 +71,15 @@ func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 	productID := vars["id"]
 
 	if productID == "" {
-		h.sendError(w, "product_id is required", http.StatusBadRequest)
+		var verr domain.ValidationError
+		verr.Add("id", domain.CodeRequired, "product_id is required")
+		h.sendError(w, r, &verr)
 		return
 	}
 
-	product, err := h.service.GetProduct(productID)
+	product, err := h.service.GetProduct(r.Context(), productID)
 	if err != nil {
-		if errors.Is(err, repository.ErrProductNotFound) {
-			h.sendError(w, "Product not found", http.StatusNotFound)
-			return
-		}
-		h.logger.Error("Failed to get product", zap.Error(err))
-		h.sendError(w, "Failed to get product", http.StatusInternalServerError)
+		h.sendError(w, r, err)
 		return
 	}
 
@@ -98,13 +91,17 @@ func (h *ProductHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ProductHandler) sendJSON(w http.ResponseWriter, data interface{}, status int) {
+	writeJSON(w, h.logger, data, status)
+}
+
+func (h *ProductHandler) sendError(w http.ResponseWriter, r *http.Request, err error) {
+	writeError(w, r, h.logger, err)
+}
+
+func writeJSON(w http.ResponseWriter, logger *zap.Logger, data interface{}, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		h.logger.Error("Failed to encode JSON", zap.Error(err))
+		logger.Error("Failed to encode JSON", zap.Error(err))
 	}
 }
-
-func (h *ProductHandler) sendError(w http.ResponseWriter, message string, status int) {
-	h.sendJSON(w, ErrorResponse{Error: message}, status)
-}
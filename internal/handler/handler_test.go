@@ -2,6 +2,7 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -57,10 +58,12 @@ func TestCreateEvent_InvalidJSON(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, rr.Code)
 
-	var errResp ErrorResponse
-	err := json.NewDecoder(rr.Body).Decode(&errResp)
+	var problem Problem
+	err := json.NewDecoder(rr.Body).Decode(&problem)
 	require.NoError(t, err)
-	assert.Equal(t, "Invalid request body", errResp.Error)
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+	assert.Equal(t, CodeInvalidBody, problem.Code)
+	assert.Equal(t, http.StatusBadRequest, problem.Status)
 }
 
 func TestCreateEvent_MissingProductID(t *testing.T) {
@@ -80,10 +83,13 @@ func TestCreateEvent_MissingProductID(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, rr.Code)
 
-	var errResp ErrorResponse
-	err := json.NewDecoder(rr.Body).Decode(&errResp)
+	var problem Problem
+	err := json.NewDecoder(rr.Body).Decode(&problem)
 	require.NoError(t, err)
-	assert.Equal(t, "product_id is required", errResp.Error)
+	assert.Equal(t, CodeValidationFailed, problem.Code)
+	assert.Equal(t, []domain.FieldError{
+		{Field: "product_id", Code: domain.CodeRequired, Message: "product_id is required"},
+	}, problem.Errors)
 }
 
 func TestCreateEvent_NegativePrice(t *testing.T) {
@@ -104,17 +110,42 @@ func TestCreateEvent_NegativePrice(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, rr.Code)
 
-	var errResp ErrorResponse
-	err := json.NewDecoder(rr.Body).Decode(&errResp)
+	var problem Problem
+	err := json.NewDecoder(rr.Body).Decode(&problem)
 	require.NoError(t, err)
-	assert.Equal(t, "price must be non-negative", errResp.Error)
+	assert.Equal(t, CodeValidationFailed, problem.Code)
+	assert.Equal(t, []domain.FieldError{
+		{Field: "price", Code: domain.CodeNegative, Message: "price must be non-negative"},
+	}, problem.Errors)
+}
+
+func TestCreateEvent_ReportsEveryInvalidField(t *testing.T) {
+	handler, _, _ := setupTest()
+
+	body, _ := json.Marshal(EventRequest{Price: -1, Stock: -1})
+	req := httptest.NewRequest("POST", "/events", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+
+	handler.CreateEvent(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	var problem Problem
+	err := json.NewDecoder(rr.Body).Decode(&problem)
+	require.NoError(t, err)
+	require.Len(t, problem.Errors, 3)
+	assert.Equal(t, "product_id", problem.Errors[0].Field)
+	assert.Equal(t, "price", problem.Errors[1].Field)
+	assert.Equal(t, "stock", problem.Errors[2].Field)
 }
 
 func TestGetProduct_Success(t *testing.T) {
 	handler, repo, _ := setupTest()
 
 	product := domain.NewProduct("test123", 49.99, 100)
-	err := repo.Save(product)
+	err := repo.Save(context.Background(), product)
 	require.NoError(t, err)
 
 	req := httptest.NewRequest("GET", "/products/test123", nil)
@@ -146,10 +177,30 @@ func TestGetProduct_NotFound(t *testing.T) {
 
 	assert.Equal(t, http.StatusNotFound, rr.Code)
 
-	var errResp ErrorResponse
-	err := json.NewDecoder(rr.Body).Decode(&errResp)
+	var problem Problem
+	err := json.NewDecoder(rr.Body).Decode(&problem)
 	require.NoError(t, err)
-	assert.Equal(t, "Product not found", errResp.Error)
+	assert.Equal(t, CodeProductNotFound, problem.Code)
+	assert.Equal(t, "urn:vfc:problem:product_not_found", problem.Type)
+	assert.Equal(t, "/products/nonexistent", problem.Instance)
+}
+
+func TestGetProduct_ClientGone(t *testing.T) {
+	handler, repo, _ := setupTest()
+	require.NoError(t, repo.Save(context.Background(), domain.NewProduct("test123", 49.99, 100)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/products/test123", nil).WithContext(ctx)
+	req = mux.SetURLVars(req, map[string]string{"id": "test123"})
+
+	rr := httptest.NewRecorder()
+
+	handler.GetProduct(rr, req)
+
+	// The lookup is abandoned and nothing is sent to the departed client.
+	assert.Empty(t, rr.Header().Get("Content-Type"))
+	assert.Zero(t, rr.Body.Len())
 }
 
 func TestHealthCheck(t *testing.T) {
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/raufhm/vfc/internal/health"
+	"github.com/raufhm/vfc/internal/requestid"
+	"go.uber.org/zap"
+)
+
+// HealthHandler serves the liveness and readiness probes. /health stays as a
+// plain "is the process up" check for existing clients.
+type HealthHandler struct {
+	registry *health.Registry
+	logger   *zap.Logger
+}
+
+func NewHealthHandler(registry *health.Registry, logger *zap.Logger) *HealthHandler {
+	return &HealthHandler{
+		registry: registry,
+		logger:   logger,
+	}
+}
+
+func (h *HealthHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/livez", h.Live).Methods("GET")
+	router.HandleFunc("/readyz", h.Ready).Methods("GET")
+}
+
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	h.write(w, r, h.registry.Live(r.Context()))
+}
+
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	h.write(w, r, h.registry.Ready(r.Context()))
+}
+
+func (h *HealthHandler) write(w http.ResponseWriter, r *http.Request, report health.Report) {
+	status := http.StatusOK
+	if !report.Healthy() {
+		status = http.StatusServiceUnavailable
+		requestid.Logger(r.Context(), h.logger).Warn("Health check failed", zap.String("path", r.URL.Path), zap.Any("checks", report.Checks))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
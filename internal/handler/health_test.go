@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func probe(t *testing.T, router http.Handler, path string) (int, health.Report) {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+
+	var report health.Report
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	return rr.Code, report
+}
+
+func TestHealth_ReadinessReportsEachCheck(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	handler, repo, q := setupTest()
+	registry := health.NewRegistry(time.Second)
+	registry.AddReadiness("repository", health.PingCheck(repo))
+	registry.AddReadiness("queue_saturation", health.SaturationCheck(q.Len, q.Cap, 0.5))
+	router := SetupRouter(handler, logger, NewHealthHandler(registry, logger))
+
+	status, report := probe(t, router, "/readyz")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, health.StatusOK, report.Status)
+	assert.Equal(t, []string{"queue_saturation", "repository", "shutdown"}, sortedKeys(report.Checks))
+
+	for i := 0; i < q.Cap()/2; i++ {
+		require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("abc", 1, 1)))
+	}
+
+	status, report = probe(t, router, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, health.StatusFail, report.Status)
+	assert.Equal(t, health.StatusFail, report.Checks["queue_saturation"].Status)
+	assert.Equal(t, "queue 5 of 10 full", report.Checks["queue_saturation"].Error)
+	assert.Equal(t, health.StatusOK, report.Checks["repository"].Status)
+}
+
+func TestHealth_ShutdownFailsReadinessOnly(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	handler, _, _ := setupTest()
+	registry := health.NewRegistry(time.Second)
+	registry.AddLiveness("workers", health.WorkersCheck(func() int { return 2 }, func() int { return 2 }))
+	router := SetupRouter(handler, logger, NewHealthHandler(registry, logger))
+
+	registry.SetShuttingDown()
+
+	status, report := probe(t, router, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, health.ErrShuttingDown.Error(), report.Checks["shutdown"].Error)
+
+	status, _ = probe(t, router, "/livez")
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func sortedKeys(checks map[string]health.Result) []string {
+	keys := make([]string, 0, len(checks))
+	for k := range checks {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/raufhm/vfc/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// MetricsHandler serves /metrics and records every routed request. Register
+// it first so requests rejected by other middleware are counted too.
+type MetricsHandler struct {
+	metrics *metrics.Metrics
+	logger  *zap.Logger
+}
+
+func NewMetricsHandler(m *metrics.Metrics, logger *zap.Logger) *MetricsHandler {
+	return &MetricsHandler{
+		metrics: m,
+		logger:  logger,
+	}
+}
+
+func (h *MetricsHandler) RegisterRoutes(router *mux.Router) {
+	router.Use(h.instrument)
+	router.Handle("/metrics", promhttp.HandlerFor(h.metrics.Registry(), promhttp.HandlerOpts{
+		ErrorLog: zap.NewStdLog(h.logger),
+	})).Methods("GET")
+}
+
+func (h *MetricsHandler) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Label by template so /products/{id} is one series, not one per ID.
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		h.metrics.ObserveRequest(route, r.Method, rec.Status(), time.Since(start))
+	})
+}
+
+// statusRecorder captures the response status while still letting handlers
+// flush (SSE) and hijack (WebSocket) the connection.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Status returns the response status, or 200 if nothing was written.
+func (r *statusRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) Flush() {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil && r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/metrics"
+	"github.com/raufhm/vfc/internal/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func scrape(t *testing.T, router http.Handler) string {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	return rr.Body.String()
+}
+
+func TestMetrics_RecordsRequestsByRoute(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	handler, repo, q := setupTest()
+	m := metrics.New()
+	m.WatchQueue(q.Len, q.Cap)
+	m.WatchRepository(repo.Count)
+	router := SetupRouter(handler, logger, NewMetricsHandler(m, logger))
+
+	require.NoError(t, repo.Save(context.Background(), domain.NewProduct("abc", 1, 1)))
+	for _, path := range []string{"/products/abc", "/products/abc", "/products/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	router.ServeHTTP(httptest.NewRecorder(), jsonRequest("POST", "/events", `{"product_id":"abc","price":1,"stock":1}`))
+
+	body := scrape(t, router)
+	assert.Contains(t, body, `vfc_http_requests_total{method="GET",route="/products/{id}",status="200"} 2`)
+	assert.Contains(t, body, `vfc_http_requests_total{method="GET",route="/products/{id}",status="404"} 1`)
+	assert.Contains(t, body, `vfc_http_requests_total{method="POST",route="/events",status="202"} 1`)
+	assert.Contains(t, body, `vfc_http_request_duration_seconds_count{method="GET",route="/products/{id}"} 3`)
+	assert.Contains(t, body, "vfc_queue_depth 1")
+	assert.Contains(t, body, "vfc_queue_capacity 10")
+	assert.Contains(t, body, "vfc_repository_products 1")
+}
+
+func TestMetrics_KeepsStreamingWorking(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	handler, _, _ := setupTest()
+	broker := stream.NewBroker(10, 10, logger)
+	defer broker.Close()
+	router := SetupRouter(handler, logger,
+		NewMetricsHandler(metrics.New(), logger),
+		NewStreamHandler(broker, logger),
+		NewWebSocketHandler(handler.service, broker, logger))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// SSE needs the recorder to flush.
+	resp, err := http.Get(server.URL + "/products/stream")
+	require.NoError(t, err)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	resp.Body.Close()
+
+	// WebSocket needs it to hijack.
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	conn.Close()
+
+	// The stream is counted once the server notices the client left.
+	require.Eventually(t, func() bool {
+		body := scrape(t, router)
+		return strings.Contains(body, `vfc_http_requests_total{method="GET",route="/products/stream",status="200"} 1`) &&
+			strings.Contains(body, `vfc_http_requests_total{method="GET",route="/ws",status="101"} 1`)
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gorilla/mux"
+	"github.com/raufhm/vfc/internal/domain"
+	"go.uber.org/zap"
+)
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// LoadOpenAPI parses and validates the embedded OpenAPI document.
+func LoadOpenAPI() (*openapi3.T, error) {
+	spec, err := openapi3.NewLoader().LoadFromData(openAPIDocument)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OpenAPI document: %w", err)
+	}
+	if err := spec.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	return spec, nil
+}
+
+// OpenAPIHandler serves the API description and rejects requests that do
+// not match it, with a problem listing every mismatch, before they reach the
+// other handlers.
+type OpenAPIHandler struct {
+	spec   *openapi3.T
+	logger *zap.Logger
+}
+
+func NewOpenAPIHandler(spec *openapi3.T, logger *zap.Logger) *OpenAPIHandler {
+	return &OpenAPIHandler{
+		spec:   spec,
+		logger: logger,
+	}
+}
+
+func (h *OpenAPIHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/openapi.json", h.Document).Methods("GET")
+	router.Use(h.validate)
+}
+
+func (h *OpenAPIHandler) Document(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPIDocument)
+}
+
+// route returns the documented operation for a mux path template, if any.
+func (h *OpenAPIHandler) route(template, method string) *routers.Route {
+	item := h.spec.Paths.Value(template)
+	if item == nil {
+		return nil
+	}
+	op := item.GetOperation(method)
+	if op == nil {
+		return nil
+	}
+
+	return &routers.Route{
+		Spec:      h.spec,
+		Server:    h.spec.Servers[0],
+		Path:      template,
+		PathItem:  item,
+		Method:    method,
+		Operation: op,
+	}
+}
+
+func (h *OpenAPIHandler) validate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var route *routers.Route
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = h.route(template, r.Method)
+			}
+		}
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		err := openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: mux.Vars(r),
+			Route:      route,
+			Options: &openapi3filter.Options{
+				MultiError:         true,
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			},
+		})
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeProblem(w, r, h.logger, bodyTooLarge(maxBytesErr.Limit))
+			return
+		}
+		if err != nil {
+			h.logger.Debug("Request does not match OpenAPI document",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Error(err))
+			writeProblem(w, r, h.logger, validationProblem(err))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validationProblem turns a request validation error into a problem that
+// lists every offending field or parameter.
+func validationProblem(err error) *Problem {
+	var verr domain.ValidationError
+	collectFieldErrors(&verr, "", err)
+
+	p := NewProblem(http.StatusBadRequest, CodeValidationFailed, "The request does not match the API description")
+	p.Errors = verr.Fields
+	return p
+}
+
+func collectFieldErrors(verr *domain.ValidationError, field string, err error) {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		for _, inner := range e {
+			collectFieldErrors(verr, field, inner)
+		}
+	case *openapi3filter.RequestError:
+		if e.Parameter != nil {
+			field = e.Parameter.Name
+		}
+		switch e.Err.(type) {
+		case openapi3.MultiError, *openapi3.SchemaError:
+			collectFieldErrors(verr, field, e.Err)
+			return
+		}
+		if field == "" {
+			field = "body"
+		}
+		code := domain.CodeInvalid
+		if errors.Is(e.Err, openapi3filter.ErrInvalidRequired) {
+			code = domain.CodeRequired
+		}
+		reason := e.Reason
+		if reason == "" && e.Err != nil {
+			reason = e.Err.Error()
+		}
+		verr.Add(field, code, reason)
+	case *openapi3.SchemaError:
+		if pointer := e.JSONPointer(); len(pointer) > 0 {
+			field = strings.Join(pointer, ".")
+		}
+		code := domain.CodeInvalid
+		switch {
+		case e.SchemaField == "required":
+			code = domain.CodeRequired
+		case e.SchemaField == "minimum" && e.Schema != nil && e.Schema.Min != nil && *e.Schema.Min == 0:
+			code = domain.CodeNegative
+		case e.SchemaField == "properties" && strings.HasSuffix(e.Reason, " is unsupported"):
+			// Unknown properties are reported against the object, so the
+			// property name only appears in the reason.
+			name := strings.TrimSuffix(strings.TrimPrefix(e.Reason, "property "), " is unsupported")
+			if unquoted, err := strconv.Unquote(name); err == nil {
+				field = strings.TrimPrefix(field+"."+unquoted, ".")
+			}
+			code = domain.CodeUnknown
+		}
+		verr.Add(field, code, e.Reason)
+	default:
+		verr.Add(field, domain.CodeInvalid, err.Error())
+	}
+}
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/gorilla/mux"
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/gql"
+	"github.com/raufhm/vfc/internal/health"
+	"github.com/raufhm/vfc/internal/metrics"
+	"github.com/raufhm/vfc/internal/queue"
+	"github.com/raufhm/vfc/internal/repository"
+	"github.com/raufhm/vfc/internal/service"
+	"github.com/raufhm/vfc/internal/stream"
+	"github.com/raufhm/vfc/internal/webhook"
+	"github.com/raufhm/vfc/internal/worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// setupOpenAPITest builds the router with every handler main registers.
+func setupOpenAPITest(t *testing.T) (*mux.Router, *OpenAPIHandler, *repository.InMemoryRepository, *queue.InMemoryQueue) {
+	t.Helper()
+
+	logger, _ := zap.NewDevelopment()
+	repo := repository.NewInMemoryRepository()
+	q := queue.NewInMemoryQueue(10, logger)
+	svc := service.NewProductService(repo, q)
+	broker := stream.NewBroker(16, 16, logger)
+	t.Cleanup(broker.Close)
+
+	executor, err := gql.NewExecutor(svc, gql.Limits{MaxDepth: 6, MaxComplexity: 1000})
+	require.NoError(t, err)
+
+	spec, err := LoadOpenAPI()
+	require.NoError(t, err)
+	openAPIHandler := NewOpenAPIHandler(spec, logger)
+
+	router := SetupRouter(NewProductHandler(svc, logger), logger,
+		NewMetricsHandler(metrics.New(), logger),
+		NewHealthHandler(health.NewRegistry(time.Second), logger),
+		NewRequestDecoder(DefaultMaxBodyBytes, logger),
+		openAPIHandler,
+		NewWebhookHandler(webhook.NewStore(10), logger),
+		NewStreamHandler(broker, logger),
+		NewWebSocketHandler(svc, broker, logger),
+		NewGraphQLHandler(executor, logger),
+		NewWorkerHandler(worker.NewPool(2, q, repo, logger), 8, logger),
+	)
+	return router, openAPIHandler, repo, q
+}
+
+func TestOpenAPI_DescribesEveryRoute(t *testing.T) {
+	router, h, _, _ := setupOpenAPITest(t)
+
+	routed := make(map[string]bool)
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		require.NoError(t, err)
+		methods, err := route.GetMethods()
+		require.NoError(t, err)
+
+		item := h.spec.Paths.Value(template)
+		require.NotNil(t, item, "path %s is not documented", template)
+		for _, method := range methods {
+			assert.NotNil(t, item.GetOperation(method), "%s %s is not documented", method, template)
+			routed[method+" "+template] = true
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	for path, item := range h.spec.Paths.Map() {
+		for method := range item.Operations() {
+			assert.True(t, routed[method+" "+path], "%s %s is documented but not routed", method, path)
+		}
+	}
+}
+
+func TestOpenAPI_ResponsesConform(t *testing.T) {
+	router, h, repo, _ := setupOpenAPITest(t)
+	require.NoError(t, repo.Save(context.Background(), &domain.Product{ProductID: "abc123", Price: 49.99, Stock: 100}))
+
+	create := httptest.NewRecorder()
+	router.ServeHTTP(create, jsonRequest("POST", "/webhooks", `{"url":"https://example.com/hook","change_types":["updated"]}`))
+	require.Equal(t, http.StatusCreated, create.Code)
+	var sub webhook.Subscription
+	require.NoError(t, json.Unmarshal(create.Body.Bytes(), &sub))
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+	}{
+		{"health", httptest.NewRequest("GET", "/health", nil), http.StatusOK},
+		{"liveness", httptest.NewRequest("GET", "/livez", nil), http.StatusOK},
+		{"readiness", httptest.NewRequest("GET", "/readyz", nil), http.StatusOK},
+		{"create event", jsonRequest("POST", "/events", `{"product_id":"abc123","price":10,"stock":5}`), http.StatusAccepted},
+		{"get product", httptest.NewRequest("GET", "/products/abc123", nil), http.StatusOK},
+		{"product not found", httptest.NewRequest("GET", "/products/missing", nil), http.StatusNotFound},
+		{"bad last event id", httptest.NewRequest("GET", "/products/stream?last_event_id=-1", nil), http.StatusBadRequest},
+		{"list webhooks", httptest.NewRequest("GET", "/webhooks", nil), http.StatusOK},
+		{"get webhook", httptest.NewRequest("GET", "/webhooks/"+sub.ID, nil), http.StatusOK},
+		{"webhook deliveries", httptest.NewRequest("GET", "/webhooks/"+sub.ID+"/deliveries", nil), http.StatusOK},
+		{"webhook not found", httptest.NewRequest("GET", "/webhooks/missing", nil), http.StatusNotFound},
+		{"graphql get", httptest.NewRequest("GET", `/graphql?query={product(id:"abc123"){productId}}`, nil), http.StatusOK},
+		{"graphql post", jsonRequest("POST", "/graphql", `{"query":"{ products { productId price } }"}`), http.StatusOK},
+		{"graphql mutation over get", httptest.NewRequest("GET", `/graphql?query=mutation{submitProductUpdate(productId:"a",price:1,stock:1){accepted}}`, nil), http.StatusBadRequest},
+		{"openapi document", httptest.NewRequest("GET", "/openapi.json", nil), http.StatusOK},
+		{"metrics", httptest.NewRequest("GET", "/metrics", nil), http.StatusOK},
+		{"worker pool status", httptest.NewRequest("GET", "/admin/workers", nil), http.StatusOK},
+		{"pause workers", httptest.NewRequest("POST", "/admin/workers/pause", nil), http.StatusOK},
+		{"resume workers", httptest.NewRequest("POST", "/admin/workers/resume", nil), http.StatusOK},
+		{"resize workers", jsonRequest("PUT", "/admin/workers/size", `{"size":4}`), http.StatusOK},
+		{"resize beyond max", jsonRequest("PUT", "/admin/workers/size", `{"size":9}`), http.StatusBadRequest},
+		{"delete webhook", httptest.NewRequest("DELETE", "/webhooks/"+sub.ID, nil), http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tt.req)
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+
+			assertConforms(t, router, h, tt.req, w)
+		})
+	}
+}
+
+func TestOpenAPI_RejectsInvalidRequests(t *testing.T) {
+	router, _, _, q := setupOpenAPITest(t)
+
+	tests := []struct {
+		name  string
+		req   *http.Request
+		field string
+	}{
+		{"negative price", jsonRequest("POST", "/events", `{"product_id":"abc123","price":-1,"stock":5}`), "price"},
+		{"missing stock", jsonRequest("POST", "/events", `{"product_id":"abc123","price":1}`), "stock"},
+		{"wrong type", jsonRequest("POST", "/events", `{"product_id":"abc123","price":"free","stock":5}`), "price"},
+		{"invalid change type", jsonRequest("POST", "/webhooks", `{"url":"https://example.com","change_types":["deleted"]}`), "change_types.0"},
+		{"missing graphql query", httptest.NewRequest("GET", "/graphql", nil), "query"},
+		{"unknown field", jsonRequest("POST", "/events", `{"product_id":"abc123","price":1,"stock":5,"colour":"red"}`), "colour"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tt.req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var problem Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, CodeValidationFailed, problem.Code)
+			require.NotEmpty(t, problem.Errors)
+			assert.Equal(t, tt.field, problem.Errors[0].Field)
+		})
+	}
+
+	assert.Empty(t, q.GetChannel())
+}
+
+func TestOpenAPI_CollectsEveryMismatch(t *testing.T) {
+	router, _, _, _ := setupOpenAPITest(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, jsonRequest("POST", "/events", `{"product_id":"","price":-1}`))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var problem Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+
+	fields := make(map[string]string)
+	for _, e := range problem.Errors {
+		fields[e.Field] = e.Code
+	}
+	assert.Equal(t, map[string]string{
+		"product_id": domain.CodeInvalid,
+		"price":      domain.CodeNegative,
+		"stock":      domain.CodeRequired,
+	}, fields)
+}
+
+func jsonRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func assertConforms(t *testing.T, router *mux.Router, h *OpenAPIHandler, req *http.Request, w *httptest.ResponseRecorder) {
+	t.Helper()
+
+	var match mux.RouteMatch
+	require.True(t, router.Match(req, &match))
+	template, err := match.Route.GetPathTemplate()
+	require.NoError(t, err)
+	route := h.route(template, req.Method)
+	require.NotNil(t, route)
+
+	err = openapi3filter.ValidateResponse(req.Context(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: match.Vars,
+			Route:      route,
+		},
+		Status: w.Code,
+		Header: w.Header(),
+		Body:   io.NopCloser(bytes.NewReader(w.Body.Bytes())),
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+		},
+	})
+	assert.NoError(t, err)
+}
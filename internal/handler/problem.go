@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/raufhm/vfc/internal/auth"
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/queue"
+	"github.com/raufhm/vfc/internal/repository"
+	"github.com/raufhm/vfc/internal/requestid"
+	"github.com/raufhm/vfc/internal/webhook"
+	"github.com/raufhm/vfc/internal/worker"
+	"go.uber.org/zap"
+)
+
+// Problem codes are stable identifiers clients can branch on. Each maps to a
+// problem type URI of the form urn:vfc:problem:<code>.
+const (
+	CodeInvalidBody      = "invalid_body"
+	CodeValidationFailed = "validation_failed"
+	CodeProductNotFound  = "product_not_found"
+	CodeWebhookNotFound  = "webhook_not_found"
+	CodeQueueUnavailable = "queue_unavailable"
+	CodePoolStopped      = "pool_stopped"
+	CodeInternal         = "internal_error"
+)
+
+const problemTypePrefix = "urn:vfc:problem:"
+
+// Problem is an RFC 7807 problem details response.
+type Problem struct {
+	Type     string              `json:"type"`
+	Title    string              `json:"title"`
+	Status   int                 `json:"status"`
+	Detail   string              `json:"detail,omitempty"`
+	Instance string              `json:"instance,omitempty"`
+	Code     string              `json:"code"`
+	Errors   []domain.FieldError `json:"errors,omitempty"`
+}
+
+func NewProblem(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   problemTypePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// errorProblems maps domain and infrastructure errors to responses.
+var errorProblems = []struct {
+	err    error
+	status int
+	code   string
+	detail string
+}{
+	{auth.ErrNoCredentials, http.StatusUnauthorized, CodeUnauthorized, "Authentication is required"},
+	{auth.ErrInvalidCredentials, http.StatusUnauthorized, CodeUnauthorized, "Invalid credentials"},
+	{auth.ErrForbidden, http.StatusForbidden, CodeForbidden, "Your role does not allow this operation"},
+	{repository.ErrProductNotFound, http.StatusNotFound, CodeProductNotFound, "Product not found"},
+	{webhook.ErrSubscriptionNotFound, http.StatusNotFound, CodeWebhookNotFound, "Webhook not found"},
+	{worker.ErrPoolStopped, http.StatusServiceUnavailable, CodePoolStopped, "The worker pool is stopped"},
+}
+
+// ProblemFor maps err to a problem. Validation errors keep their field
+// errors; unknown errors become a 500 without exposing their message.
+func ProblemFor(err error) *Problem {
+	var verr *domain.ValidationError
+	if errors.As(err, &verr) {
+		p := NewProblem(http.StatusBadRequest, CodeValidationFailed, "The request has invalid fields")
+		p.Errors = verr.Fields
+		return p
+	}
+
+	if queue.IsUnavailable(err) {
+		return NewProblem(http.StatusServiceUnavailable, CodeQueueUnavailable, "Event queue is unavailable")
+	}
+
+	for _, m := range errorProblems {
+		if errors.Is(err, m.err) {
+			return NewProblem(m.status, m.code, m.detail)
+		}
+	}
+
+	return NewProblem(http.StatusInternalServerError, CodeInternal, "The request could not be completed")
+}
+
+// writeProblem sends p as application/problem+json, naming the request path
+// as the problem instance.
+func writeProblem(w http.ResponseWriter, r *http.Request, logger *zap.Logger, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		requestid.Logger(r.Context(), logger).Error("Failed to encode JSON", zap.Error(err))
+	}
+}
+
+// writeError sends the problem for err, logging errors that map to 5xx. Work
+// abandoned because the client went away is not an error; nothing is sent.
+func writeError(w http.ResponseWriter, r *http.Request, logger *zap.Logger, err error) {
+	if errors.Is(err, context.Canceled) && r.Context().Err() != nil {
+		requestid.Logger(r.Context(), logger).Info("Request canceled by client",
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path))
+		return
+	}
+
+	p := ProblemFor(err)
+	if p.Status >= http.StatusInternalServerError {
+		requestid.Logger(r.Context(), logger).Error("Request failed",
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Error(err))
+	}
+	writeProblem(w, r, logger, p)
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/raufhm/vfc/internal/auth"
+	"github.com/raufhm/vfc/internal/ratelimit"
+	"go.uber.org/zap"
+)
+
+const CodeRateLimited = "rate_limited"
+
+// RateLimiter applies per-client token buckets. Routes that need writer or
+// admin use the write limit and the rest use the read limit; public routes
+// such as /health are not limited. Clients are identified by their principal
+// when the request is authenticated and by IP otherwise, so register it after
+// the Authorizer. Requests carry the write bucket in their context, so
+// handlers that accept writes on read routes can charge it with
+// ratelimit.AllowWrite.
+type RateLimiter struct {
+	read       *ratelimit.Limiter
+	write      *ratelimit.Limiter
+	trustProxy bool
+	logger     *zap.Logger
+}
+
+// NewRateLimiter creates the middleware. With trustProxy the client IP is
+// taken from the last X-Forwarded-For entry, which is the address our proxy
+// saw; only enable it behind a proxy that sets the header. The limiters may
+// be shared with the gRPC server so clients have one budget across both.
+func NewRateLimiter(read, write *ratelimit.Limiter, trustProxy bool, logger *zap.Logger) *RateLimiter {
+	return &RateLimiter{
+		read:       read,
+		write:      write,
+		trustProxy: trustProxy,
+		logger:     logger,
+	}
+}
+
+func (l *RateLimiter) RegisterRoutes(router *mux.Router) {
+	router.Use(l.limit)
+}
+
+func (l *RateLimiter) limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		required := auth.RoleAdmin
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				required = RequiredRole(r.Method, template)
+			}
+		}
+		if required == auth.RoleNone {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limiter := l.read
+		if required.Allows(auth.RoleWriter) {
+			limiter = l.write
+		}
+
+		client := l.clientKey(r)
+		result := limiter.Allow(client)
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		h.Set("RateLimit-Reset", seconds(result.Reset))
+
+		if !result.Allowed {
+			h.Set("Retry-After", seconds(result.RetryAfter))
+			l.logger.Warn("Rate limit exceeded",
+				zap.String("client", client),
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path))
+			writeProblem(w, r, l.logger, NewProblem(http.StatusTooManyRequests, CodeRateLimited,
+				"rate limit exceeded, retry after "+seconds(result.RetryAfter)+"s"))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ratelimit.WithWriteLimit(r.Context(), l.write, client)))
+	})
+}
+
+func (l *RateLimiter) clientKey(r *http.Request) string {
+	if id := auth.PrincipalID(r.Context()); id != "" {
+		return "principal:" + id
+	}
+	return "ip:" + l.clientIP(r)
+}
+
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	if l.trustProxy {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			entries := strings.Split(fwd, ",")
+			if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// seconds rounds d up to whole seconds, as the RateLimit headers expect.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
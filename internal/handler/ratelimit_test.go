@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/raufhm/vfc/internal/auth"
+	"github.com/raufhm/vfc/internal/gql"
+	"github.com/raufhm/vfc/internal/ratelimit"
+	"github.com/raufhm/vfc/internal/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func setupRateLimitTest(trustProxy bool, routes ...Routes) *mux.Router {
+	handler, _, _ := setupTest()
+	return setupRateLimitRouter(handler, trustProxy, routes...)
+}
+
+func setupRateLimitRouter(handler *ProductHandler, trustProxy bool, routes ...Routes) *mux.Router {
+	logger, _ := zap.NewDevelopment()
+	limiter := NewRateLimiter(ratelimit.NewLimiter(ratelimit.Limit{Rate: 0.001, Burst: 3}), ratelimit.NewLimiter(ratelimit.Limit{Rate: 0.001, Burst: 1}), trustProxy, logger)
+	return SetupRouter(handler, logger, append(routes, limiter)...)
+}
+
+func send(router http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRateLimiter_LimitsReadsAndWritesSeparately(t *testing.T) {
+	router := setupRateLimitTest(false)
+
+	for i := 2; i >= 0; i-- {
+		rr := send(router, httptest.NewRequest("GET", "/products/abc", nil))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Equal(t, "3", rr.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, strconv.Itoa(i), rr.Header().Get("RateLimit-Remaining"))
+	}
+
+	rr := send(router, httptest.NewRequest("GET", "/products/abc", nil))
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "1000", rr.Header().Get("Retry-After"))
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+	var problem Problem
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&problem))
+	assert.Equal(t, CodeRateLimited, problem.Code)
+
+	// Writes have their own, stricter bucket.
+	body := `{"product_id":"abc","price":1,"stock":1}`
+	assert.Equal(t, http.StatusAccepted, send(router, jsonRequest("POST", "/events", body)).Code)
+	rr = send(router, jsonRequest("POST", "/events", body))
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimiter_SkipsPublicRoutes(t *testing.T) {
+	router := setupRateLimitTest(false)
+
+	for i := 0; i < 5; i++ {
+		rr := send(router, httptest.NewRequest("GET", "/health", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Empty(t, rr.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestRateLimiter_KeysByClient(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	router := setupRateLimitTest(false, NewAuthorizer(testKeys, logger))
+
+	request := func(key, addr string) *http.Request {
+		req := jsonRequest("POST", "/events", `{"product_id":"abc","price":1,"stock":1}`)
+		req.Header.Set(auth.APIKeyHeader, key)
+		req.RemoteAddr = addr
+		return req
+	}
+
+	// The bucket follows the key, not the address it is used from.
+	assert.Equal(t, http.StatusAccepted, send(router, request("writer-key", "10.0.0.1:1000")).Code)
+	assert.Equal(t, http.StatusTooManyRequests, send(router, request("writer-key", "10.0.0.2:1000")).Code)
+	assert.Equal(t, http.StatusAccepted, send(router, request("admin-key", "10.0.0.1:1000")).Code)
+}
+
+func TestRateLimiter_ClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		trustProxy bool
+		forwarded  string
+		want       string
+	}{
+		{"remote addr", false, "", "192.0.2.1"},
+		{"forwarded ignored", false, "203.0.113.9", "192.0.2.1"},
+		{"forwarded trusted", true, "198.51.100.7, 203.0.113.9", "203.0.113.9"},
+		{"trusted without header", true, "", "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter(ratelimit.NewLimiter(ratelimit.Limit{}), ratelimit.NewLimiter(ratelimit.Limit{}), tt.trustProxy, zap.NewNop())
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = "192.0.2.1:5555"
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			assert.Equal(t, "ip:"+tt.want, l.clientKey(req))
+		})
+	}
+}
+
+func TestRateLimiter_ResponseConforms(t *testing.T) {
+	router, h, _, _ := setupOpenAPITest(t)
+	logger, _ := zap.NewDevelopment()
+	NewRateLimiter(ratelimit.NewLimiter(ratelimit.Limit{Rate: 0.001, Burst: 1}), ratelimit.NewLimiter(ratelimit.Limit{Rate: 0.001, Burst: 1}), false, logger).RegisterRoutes(router)
+
+	send(router, httptest.NewRequest("GET", "/products/abc", nil))
+	req := httptest.NewRequest("GET", "/products/abc", nil)
+	rr := send(router, req)
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assertConforms(t, router, h, req, rr)
+}
+
+func TestRateLimiter_LimitsGraphQLMutationsAsWrites(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	handler, _, _ := setupTest()
+	executor, err := gql.NewExecutor(handler.service, gql.Limits{MaxDepth: 5})
+	require.NoError(t, err)
+	router := setupRateLimitRouter(handler, false, NewGraphQLHandler(executor, logger))
+
+	mutation := `{"query":"mutation { submitProductUpdate(productId: \"abc\", price: 1, stock: 1) { accepted } }"}`
+
+	rr := send(router, jsonRequest("POST", "/graphql", mutation))
+	assert.JSONEq(t, `{"data":{"submitProductUpdate":{"accepted":true}}}`, rr.Body.String())
+
+	// The read bucket still has tokens, but the write bucket is empty.
+	rr = send(router, jsonRequest("POST", "/graphql", mutation))
+	assert.Contains(t, rr.Body.String(), "rate limit exceeded")
+}
+
+func TestRateLimiter_LimitsWebSocketEventsAsWrites(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	handler, _, _ := setupTest()
+	ws := NewWebSocketHandler(handler.service, stream.NewBroker(10, 10, logger), logger)
+	server := httptest.NewServer(setupRateLimitRouter(handler, false, ws))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	submit := func(id string) WSServerMessage {
+		require.NoError(t, conn.WriteJSON(WSClientMessage{
+			Type:  WSEvent,
+			ID:    id,
+			Event: &EventRequest{ProductID: "abc", Price: 1, Stock: 1},
+		}))
+		var reply WSServerMessage
+		require.NoError(t, conn.ReadJSON(&reply))
+		return reply
+	}
+
+	assert.Equal(t, WSServerMessage{Type: WSAck, ID: "1"}, submit("1"))
+	reply := submit("2")
+	assert.Equal(t, WSError, reply.Type)
+	assert.Contains(t, reply.Error, "rate limit exceeded")
+}
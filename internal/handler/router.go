@@ -5,14 +5,74 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/raufhm/vfc/internal/auth"
+	"github.com/raufhm/vfc/internal/requestid"
 	"go.uber.org/zap"
 )
 
-func SetupRouter(handler *ProductHandler, logger *zap.Logger) *mux.Router {
+// routeRoles is the role each route requires, keyed by method and path
+// template. Routes missing from the table require admin. Roles are only
+// enforced when an Authorizer is registered.
+var routeRoles = map[string]auth.Role{
+	"GET /health":       auth.RoleNone,
+	"GET /openapi.json": auth.RoleNone,
+	"GET /metrics":      auth.RoleNone,
+	"GET /livez":        auth.RoleNone,
+	"GET /readyz":       auth.RoleNone,
+
+	"GET /products/{id}":   auth.RoleReader,
+	"GET /products/stream": auth.RoleReader,
+	// Submitting events over /ws and mutations over /graphql additionally
+	// require writer; the handlers check that per message.
+	"GET /ws":       auth.RoleReader,
+	"GET /graphql":  auth.RoleReader,
+	"POST /graphql": auth.RoleReader,
+
+	"POST /events": auth.RoleWriter,
+
+	"GET /webhooks":                 auth.RoleAdmin,
+	"POST /webhooks":                auth.RoleAdmin,
+	"GET /webhooks/{id}":            auth.RoleAdmin,
+	"DELETE /webhooks/{id}":         auth.RoleAdmin,
+	"GET /webhooks/{id}/deliveries": auth.RoleAdmin,
+
+	"GET /admin/workers":         auth.RoleAdmin,
+	"POST /admin/workers/pause":  auth.RoleAdmin,
+	"POST /admin/workers/resume": auth.RoleAdmin,
+	"PUT /admin/workers/size":    auth.RoleAdmin,
+}
+
+// RequiredRole returns the role needed to call method on the route with the
+// given path template.
+func RequiredRole(method, template string) auth.Role {
+	role, ok := routeRoles[method+" "+template]
+	if !ok {
+		return auth.RoleAdmin
+	}
+	return role
+}
+
+// Routes is implemented by handlers that register their own endpoints.
+type Routes interface {
+	RegisterRoutes(router *mux.Router)
+}
+
+func SetupRouter(handler *ProductHandler, logger *zap.Logger, routes ...Routes) *mux.Router {
 	router := mux.NewRouter()
 
 	router.Use(loggingMiddleware(logger))
 	router.Use(corsMiddleware)
+	// Routes only match their own methods, so preflight requests end up
+	// here, where middleware does not run.
+	router.MethodNotAllowedHandler = corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+
+	// Registered first so fixed paths such as /products/stream take
+	// precedence over /products/{id}.
+	for _, r := range routes {
+		r.RegisterRoutes(router)
+	}
 
 	router.HandleFunc("/health", handler.HealthCheck).Methods("GET")
 	router.HandleFunc("/events", handler.CreateEvent).Methods("POST")
@@ -21,19 +81,34 @@ func SetupRouter(handler *ProductHandler, logger *zap.Logger) *mux.Router {
 	return router
 }
 
+// loggingMiddleware assigns each request an ID, taken from X-Request-ID when
+// the client sent a usable one, and logs with a logger carrying that ID.
+// Handlers get the logger from requestid.Logger.
 func loggingMiddleware(logger *zap.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
-			logger.Info("Request started",
+
+			id := r.Header.Get(requestid.Header)
+			if !requestid.Valid(id) {
+				id = requestid.New()
+			}
+			w.Header().Set(requestid.Header, id)
+
+			reqLogger := logger.With(zap.String("request_id", id))
+			ctx := requestid.WithLogger(requestid.WithID(r.Context(), id), reqLogger)
+
+			reqLogger.Info("Request started",
 				zap.String("method", r.Method),
 				zap.String("path", r.URL.Path))
 
-			next.ServeHTTP(w, r)
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r.WithContext(ctx))
 
-			logger.Info("Request completed",
+			reqLogger.Info("Request completed",
 				zap.String("method", r.Method),
 				zap.String("path", r.URL.Path),
+				zap.Int("status", rec.Status()),
 				zap.Duration("duration", time.Since(start)))
 		})
 	}
@@ -42,8 +117,9 @@ func loggingMiddleware(logger *zap.Logger) func(http.Handler) http.Handler {
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, traceparent, tracestate, "+auth.APIKeyHeader+", "+requestid.Header)
+		w.Header().Set("Access-Control-Expose-Headers", "RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After, "+requestid.Header)
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/raufhm/vfc/internal/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLoggingMiddleware_RequestID(t *testing.T) {
+	handler, _, q := setupTest()
+
+	tests := []struct {
+		name     string
+		header   string
+		accepted bool
+	}{
+		{"generated", "", false},
+		{"accepted", "client-req-42", true},
+		{"replaced when unusable", "bad id\r\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.InfoLevel)
+			router := SetupRouter(handler, zap.New(core))
+
+			req := jsonRequest("POST", "/events", `{"product_id":"abc","price":1,"stock":1}`)
+			if tt.header != "" {
+				req.Header.Set(requestid.Header, tt.header)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			require.Equal(t, http.StatusAccepted, rr.Code)
+
+			id := rr.Header().Get(requestid.Header)
+			assert.True(t, requestid.Valid(id))
+			if tt.accepted {
+				assert.Equal(t, tt.header, id)
+			} else {
+				assert.NotEqual(t, tt.header, id)
+			}
+
+			// Every line logged for the request carries its ID.
+			entries := logs.All()
+			require.NotEmpty(t, entries)
+			for _, e := range entries {
+				assert.Equal(t, id, e.ContextMap()["request_id"], e.Message)
+			}
+			assert.Equal(t, int64(http.StatusAccepted), logs.FilterMessage("Request completed").All()[0].ContextMap()["status"])
+
+			event := <-q.GetChannel()
+			assert.Equal(t, id, event.RequestID)
+		})
+	}
+}
+
+func TestCORSMiddleware_AllowsEveryRouteMethod(t *testing.T) {
+	_, router := setupWorkerTest(t)
+
+	req := httptest.NewRequest("OPTIONS", "/admin/workers/size", nil)
+	req.Header.Set("Origin", "https://dashboard.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	allowed := strings.Split(rr.Header().Get("Access-Control-Allow-Methods"), ", ")
+	for route := range routeRoles {
+		method, _, _ := strings.Cut(route, " ")
+		assert.Contains(t, allowed, method, "preflight for %s", route)
+	}
+
+	// Other methods a route does not serve are still refused.
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("DELETE", "/admin/workers/size", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
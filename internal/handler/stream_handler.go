@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/stream"
+	"go.uber.org/zap"
+)
+
+const streamHeartbeat = 15 * time.Second
+
+type StreamHandler struct {
+	broker *stream.Broker
+	logger *zap.Logger
+}
+
+func NewStreamHandler(broker *stream.Broker, logger *zap.Logger) *StreamHandler {
+	return &StreamHandler{
+		broker: broker,
+		logger: logger,
+	}
+}
+
+func (h *StreamHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/products/stream", h.StreamProducts).Methods("GET")
+}
+
+// StreamProducts sends product changes as Server-Sent Events. Clients can
+// limit the stream with ?ids=a,b,c and resume after a disconnect with the
+// Last-Event-ID header (or ?last_event_id=).
+func (h *StreamHandler) StreamProducts(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	var since uint64
+	if lastEventID != "" {
+		var err error
+		if since, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			var verr domain.ValidationError
+			verr.Add("Last-Event-ID", domain.CodeInvalid, "Last-Event-ID must be a number")
+			writeError(w, r, h.logger, &verr)
+			return
+		}
+	}
+
+	var productIDs []string
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			productIDs = append(productIDs, id)
+		}
+	}
+
+	// Streams outlive the server's write timeout.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Debug("Could not clear write deadline", zap.Error(err))
+	}
+
+	sub := h.broker.Subscribe(productIDs, since)
+	defer h.broker.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		h.logger.Error("Streaming not supported", zap.Error(err))
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case msg, ok := <-sub.C:
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(msg.Change)
+			if err != nil {
+				h.logger.Error("Failed to encode product change", zap.Error(err))
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: product\ndata: %s\n\n", msg.ID, data); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
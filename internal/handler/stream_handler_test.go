@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func readEvent(t *testing.T, reader *bufio.Reader) map[string]string {
+	t.Helper()
+
+	fields := make(map[string]string)
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			return fields
+		}
+		if key, value, ok := strings.Cut(line, ": "); ok {
+			fields[key] = value
+		}
+	}
+}
+
+func TestStreamProducts(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	broker := stream.NewBroker(10, 10, logger)
+	productHandler, _, _ := setupTest()
+	server := httptest.NewServer(SetupRouter(productHandler, logger, NewStreamHandler(broker, logger)))
+	defer server.Close()
+
+	broker.Publish(domain.NewProductChange(domain.ChangeCreated, domain.NewProduct("a", 1, 1)))
+
+	req, _ := http.NewRequest("GET", server.URL+"/products/stream?ids=a", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	require.Eventually(t, func() bool { return broker.SubscriberCount() == 1 }, time.Second, 5*time.Millisecond)
+	broker.Publish(domain.NewProductChange(domain.ChangeUpdated, domain.NewProduct("b", 2, 2)))
+	broker.Publish(domain.NewProductChange(domain.ChangeUpdated, domain.NewProduct("a", 3, 3)))
+
+	event := readEvent(t, bufio.NewReader(resp.Body))
+	assert.Equal(t, "3", event["id"])
+	assert.Equal(t, "product", event["event"])
+	assert.Contains(t, event["data"], `"price":3`)
+}
+
+func TestStreamProducts_ResumeFromLastEventID(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	broker := stream.NewBroker(10, 10, logger)
+	productHandler, _, _ := setupTest()
+	server := httptest.NewServer(SetupRouter(productHandler, logger, NewStreamHandler(broker, logger)))
+	defer server.Close()
+
+	broker.Publish(domain.NewProductChange(domain.ChangeCreated, domain.NewProduct("a", 1, 1)))
+	broker.Publish(domain.NewProductChange(domain.ChangeUpdated, domain.NewProduct("a", 2, 2)))
+
+	req, _ := http.NewRequest("GET", server.URL+"/products/stream", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	event := readEvent(t, bufio.NewReader(resp.Body))
+	assert.Equal(t, "2", event["id"])
+	assert.Contains(t, event["data"], `"price":2`)
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/gorilla/mux"
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/webhook"
+	"go.uber.org/zap"
+)
+
+type WebhookHandler struct {
+	store  *webhook.Store
+	logger *zap.Logger
+}
+
+func NewWebhookHandler(store *webhook.Store, logger *zap.Logger) *WebhookHandler {
+	return &WebhookHandler{
+		store:  store,
+		logger: logger,
+	}
+}
+
+type WebhookRequest struct {
+	URL            string              `json:"url"`
+	ProductPattern string              `json:"product_pattern"`
+	ChangeTypes    []domain.ChangeType `json:"change_types"`
+	Secret         string              `json:"secret"`
+}
+
+// Validate reports every problem with the request as a *domain.ValidationError.
+func (r WebhookRequest) Validate() error {
+	var verr domain.ValidationError
+
+	target, err := url.Parse(r.URL)
+	if r.URL == "" {
+		verr.Add("url", domain.CodeRequired, "url is required")
+	} else if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		verr.Add("url", domain.CodeInvalid, "url must be an absolute http or https URL")
+	}
+
+	if _, err := path.Match(r.ProductPattern, ""); err != nil {
+		verr.Add("product_pattern", domain.CodeInvalid, "product_pattern is not a valid pattern")
+	}
+
+	for _, t := range r.ChangeTypes {
+		if t != domain.ChangeCreated && t != domain.ChangeUpdated {
+			verr.Add("change_types", domain.CodeInvalid, "change_types may only contain created or updated")
+			break
+		}
+	}
+
+	return verr.Err()
+}
+
+// WebhookCreatedResponse is the only response that includes the secret.
+type WebhookCreatedResponse struct {
+	*webhook.Subscription
+	Secret string `json:"secret"`
+}
+
+func (h *WebhookHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/webhooks", h.CreateWebhook).Methods("POST")
+	router.HandleFunc("/webhooks", h.ListWebhooks).Methods("GET")
+	router.HandleFunc("/webhooks/{id}", h.GetWebhook).Methods("GET")
+	router.HandleFunc("/webhooks/{id}", h.DeleteWebhook).Methods("DELETE")
+	router.HandleFunc("/webhooks/{id}/deliveries", h.ListDeliveries).Methods("GET")
+}
+
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req WebhookRequest
+
+	if p := decodeJSON(r, &req); p != nil {
+		writeProblem(w, r, h.logger, p)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	sub, err := h.store.Create(&webhook.Subscription{
+		URL:            req.URL,
+		ProductPattern: req.ProductPattern,
+		ChangeTypes:    req.ChangeTypes,
+		Secret:         req.Secret,
+	})
+	if err != nil {
+		h.logger.Error("Failed to create webhook", zap.Error(err))
+		h.sendError(w, r, err)
+		return
+	}
+
+	h.logger.Info("Webhook registered",
+		zap.String("subscription_id", sub.ID),
+		zap.String("url", sub.URL))
+
+	h.sendJSON(w, WebhookCreatedResponse{Subscription: sub, Secret: sub.Secret}, http.StatusCreated)
+}
+
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	h.sendJSON(w, h.store.List(), http.StatusOK)
+}
+
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	sub, err := h.store.Get(mux.Vars(r)["id"])
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	h.sendJSON(w, sub, http.StatusOK)
+}
+
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if err := h.store.Delete(id); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	h.logger.Info("Webhook deleted", zap.String("subscription_id", id))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := h.store.Deliveries(mux.Vars(r)["id"])
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	h.sendJSON(w, deliveries, http.StatusOK)
+}
+
+func (h *WebhookHandler) sendJSON(w http.ResponseWriter, data interface{}, status int) {
+	writeJSON(w, h.logger, data, status)
+}
+
+func (h *WebhookHandler) sendError(w http.ResponseWriter, r *http.Request, err error) {
+	writeError(w, r, h.logger, err)
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/raufhm/vfc/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func setupWebhookTest() (*WebhookHandler, http.Handler) {
+	logger, _ := zap.NewDevelopment()
+	h := NewWebhookHandler(webhook.NewStore(10), logger)
+	productHandler, _, _ := setupTest()
+	return h, SetupRouter(productHandler, logger, h)
+}
+
+func TestCreateWebhook_Success(t *testing.T) {
+	_, router := setupWebhookTest()
+
+	body, _ := json.Marshal(WebhookRequest{
+		URL:            "https://partner.example.com/hooks",
+		ProductPattern: "sku-*",
+	})
+	req := httptest.NewRequest("POST", "/webhooks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	var resp map[string]interface{}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.NotEmpty(t, resp["id"])
+	assert.NotEmpty(t, resp["secret"])
+	assert.Equal(t, "sku-*", resp["product_pattern"])
+
+	req = httptest.NewRequest("GET", "/webhooks/"+resp["id"].(string), nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "secret")
+}
+
+func TestCreateWebhook_InvalidURL(t *testing.T) {
+	_, router := setupWebhookTest()
+
+	body, _ := json.Marshal(WebhookRequest{URL: "ftp://example.com"})
+	req := httptest.NewRequest("POST", "/webhooks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	var problem Problem
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&problem))
+	assert.Equal(t, CodeValidationFailed, problem.Code)
+	require.Len(t, problem.Errors, 1)
+	assert.Equal(t, "url", problem.Errors[0].Field)
+	assert.Equal(t, "url must be an absolute http or https URL", problem.Errors[0].Message)
+}
+
+func TestDeleteWebhook_NotFound(t *testing.T) {
+	_, router := setupWebhookTest()
+
+	req := httptest.NewRequest("DELETE", "/webhooks/missing", nil)
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
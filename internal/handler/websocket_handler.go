@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/raufhm/vfc/internal/auth"
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/ratelimit"
+	"github.com/raufhm/vfc/internal/requestid"
+	"github.com/raufhm/vfc/internal/service"
+	"github.com/raufhm/vfc/internal/stream"
+	"go.uber.org/zap"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+	wsMaxMessage = 64 * 1024
+)
+
+// Client message types.
+const (
+	WSSubscribe   = "subscribe"
+	WSUnsubscribe = "unsubscribe"
+	WSEvent       = "event"
+)
+
+// Server message types.
+const (
+	WSAck           = "ack"
+	WSError         = "error"
+	WSProductUpdate = "product_update"
+)
+
+// WSClientMessage is sent by clients. ID is echoed back in the ack or error
+// so clients can correlate replies with requests.
+type WSClientMessage struct {
+	Type       string        `json:"type"`
+	ID         string        `json:"id,omitempty"`
+	ProductIDs []string      `json:"product_ids,omitempty"`
+	Event      *EventRequest `json:"event,omitempty"`
+}
+
+type WSServerMessage struct {
+	Type    string                `json:"type"`
+	ID      string                `json:"id,omitempty"`
+	Error   string                `json:"error,omitempty"`
+	EventID uint64                `json:"event_id,omitempty"`
+	Change  *domain.ProductChange `json:"change,omitempty"`
+}
+
+type WebSocketHandler struct {
+	service        *service.ProductService
+	broker         *stream.Broker
+	logger         *zap.Logger
+	upgrader       websocket.Upgrader
+	allowedOrigins []string
+}
+
+func NewWebSocketHandler(service *service.ProductService, broker *stream.Broker, logger *zap.Logger) *WebSocketHandler {
+	h := &WebSocketHandler{
+		service: service,
+		broker:  broker,
+		logger:  logger,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+		},
+	}
+	h.upgrader.CheckOrigin = h.checkOrigin
+	return h
+}
+
+// AllowOrigins accepts upgrades from browser pages on these origins, such as
+// "https://pos.example.com", in addition to pages served from the API's own
+// host.
+func (h *WebSocketHandler) AllowOrigins(origins []string) {
+	h.allowedOrigins = origins
+}
+
+// checkOrigin refuses upgrades from foreign browser pages. CORS does not
+// apply to WebSockets, and browsers attach ambient credentials such as client
+// certificates to cross-site upgrades, so accepting any origin would let any
+// page act on the user's behalf. Clients that send no Origin are not browsers
+// and are let through.
+func (h *WebSocketHandler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || slices.Contains(h.allowedOrigins, origin) {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	requestid.Logger(r.Context(), h.logger).Warn("WebSocket origin rejected", zap.String("origin", origin))
+	return false
+}
+
+func (h *WebSocketHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/ws", h.Connect).Methods("GET")
+}
+
+// Connect upgrades the request to a WebSocket. Clients subscribe and
+// unsubscribe to product IDs at runtime and may submit update events on the
+// same connection; the server replies with acks and pushes product updates
+// for subscribed products.
+func (h *WebSocketHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		h.logger.Warn("WebSocket upgrade failed", zap.Error(err))
+		return
+	}
+	defer conn.Close()
+
+	sub := h.broker.SubscribeNone()
+	defer h.broker.Unsubscribe(sub)
+
+	replies := make(chan WSServerMessage, 16)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go h.readLoop(r.Context(), conn, sub, replies, done, stopped)
+
+	h.writeLoop(conn, sub, replies, done)
+}
+
+// readLoop handles client messages until the connection fails. stopped is
+// closed once writeLoop has exited, so replies can no longer be delivered.
+func (h *WebSocketHandler) readLoop(ctx context.Context, conn *websocket.Conn, sub *stream.Subscriber, replies chan<- WSServerMessage, done chan<- struct{}, stopped <-chan struct{}) {
+	defer close(done)
+
+	send := func(reply WSServerMessage) bool {
+		select {
+		case replies <- reply:
+			return true
+		case <-stopped:
+			return false
+		}
+	}
+
+	conn.SetReadLimit(wsMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var msg WSClientMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				if !send(WSServerMessage{Type: WSError, Error: "Invalid message"}) {
+					return
+				}
+				continue
+			}
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				h.logger.Warn("WebSocket read failed", zap.Error(err))
+			}
+			return
+		}
+
+		if !send(h.handleMessage(ctx, sub, msg)) {
+			return
+		}
+	}
+}
+
+func (h *WebSocketHandler) handleMessage(ctx context.Context, sub *stream.Subscriber, msg WSClientMessage) WSServerMessage {
+	reply := func(errMsg string) WSServerMessage {
+		if errMsg != "" {
+			return WSServerMessage{Type: WSError, ID: msg.ID, Error: errMsg}
+		}
+		return WSServerMessage{Type: WSAck, ID: msg.ID}
+	}
+
+	switch msg.Type {
+	case WSSubscribe:
+		if len(msg.ProductIDs) == 0 {
+			return reply("product_ids is required")
+		}
+		h.broker.Watch(sub, msg.ProductIDs...)
+		return reply("")
+
+	case WSUnsubscribe:
+		if len(msg.ProductIDs) == 0 {
+			return reply("product_ids is required")
+		}
+		h.broker.Unwatch(sub, msg.ProductIDs...)
+		return reply("")
+
+	case WSEvent:
+		if !auth.Allowed(ctx, auth.RoleWriter) {
+			return reply("writer role required to submit events")
+		}
+		if limit := ratelimit.AllowWrite(ctx); !limit.Allowed {
+			h.logger.Warn("Rate limit exceeded", zap.String("principal", auth.PrincipalID(ctx)))
+			return reply("rate limit exceeded, retry after " + strconv.Itoa(limit.RetryAfterSeconds()) + "s")
+		}
+		if msg.Event == nil {
+			return reply("event is required")
+		}
+		if err := msg.Event.Validate(); err != nil {
+			return reply(err.Error())
+		}
+		event := msg.Event.ToEvent()
+		event.Principal = auth.PrincipalID(ctx)
+		if err := h.service.EnqueueProductUpdate(ctx, event); err != nil {
+			h.logger.Error("Failed to enqueue event", zap.Error(err))
+			return reply("Failed to enqueue event")
+		}
+		h.logger.Info("Event enqueued", zap.String("product_id", msg.Event.ProductID))
+		return reply("")
+
+	default:
+		return reply("unknown message type")
+	}
+}
+
+func (h *WebSocketHandler) writeLoop(conn *websocket.Conn, sub *stream.Subscriber, replies <-chan WSServerMessage, done <-chan struct{}) {
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	write := func(msg interface{}) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		return conn.WriteJSON(msg)
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+
+		case reply := <-replies:
+			if err := write(reply); err != nil {
+				return
+			}
+
+		case msg, ok := <-sub.C:
+			if !ok {
+				closeMsg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+				if sub.Evicted() {
+					closeMsg = websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "subscriber fell behind")
+				}
+				conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(wsWriteWait))
+				return
+			}
+			if err := write(WSServerMessage{Type: WSProductUpdate, EventID: msg.ID, Change: msg.Change}); err != nil {
+				return
+			}
+
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/queue"
+	"github.com/raufhm/vfc/internal/repository"
+	"github.com/raufhm/vfc/internal/service"
+	"github.com/raufhm/vfc/internal/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func setupWebSocketServer(t *testing.T, allowedOrigins ...string) (*httptest.Server, *stream.Broker, *queue.InMemoryQueue) {
+	t.Helper()
+
+	logger, _ := zap.NewDevelopment()
+	repo := repository.NewInMemoryRepository()
+	q := queue.NewInMemoryQueue(10, logger)
+	svc := service.NewProductService(repo, q)
+	broker := stream.NewBroker(10, 10, logger)
+
+	ws := NewWebSocketHandler(svc, broker, logger)
+	ws.AllowOrigins(allowedOrigins)
+	server := httptest.NewServer(SetupRouter(NewProductHandler(svc, logger), logger, ws))
+	t.Cleanup(server.Close)
+
+	return server, broker, q
+}
+
+func setupWebSocketTest(t *testing.T) (*websocket.Conn, *stream.Broker, *queue.InMemoryQueue) {
+	t.Helper()
+
+	server, broker, q := setupWebSocketServer(t)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	return conn, broker, q
+}
+
+func TestWebSocket_SubscribeReceivesUpdates(t *testing.T) {
+	conn, broker, _ := setupWebSocketTest(t)
+
+	require.NoError(t, conn.WriteJSON(WSClientMessage{Type: WSSubscribe, ID: "1", ProductIDs: []string{"a"}}))
+
+	var reply WSServerMessage
+	require.NoError(t, conn.ReadJSON(&reply))
+	assert.Equal(t, WSServerMessage{Type: WSAck, ID: "1"}, reply)
+
+	broker.Publish(domain.NewProductChange(domain.ChangeUpdated, domain.NewProduct("b", 1, 1)))
+	broker.Publish(domain.NewProductChange(domain.ChangeUpdated, domain.NewProduct("a", 2, 2)))
+
+	var update WSServerMessage
+	require.NoError(t, conn.ReadJSON(&update))
+	assert.Equal(t, WSProductUpdate, update.Type)
+	assert.Equal(t, "a", update.Change.Product.ProductID)
+
+	require.NoError(t, conn.WriteJSON(WSClientMessage{Type: WSUnsubscribe, ID: "2", ProductIDs: []string{"a"}}))
+	require.NoError(t, conn.ReadJSON(&reply))
+	assert.Equal(t, WSAck, reply.Type)
+
+	broker.Publish(domain.NewProductChange(domain.ChangeUpdated, domain.NewProduct("a", 3, 3)))
+	require.NoError(t, conn.WriteJSON(WSClientMessage{Type: "ping", ID: "3"}))
+	require.NoError(t, conn.ReadJSON(&reply))
+	assert.Equal(t, WSServerMessage{Type: WSError, ID: "3", Error: "unknown message type"}, reply)
+}
+
+func TestWebSocket_SubmitEvent(t *testing.T) {
+	conn, _, q := setupWebSocketTest(t)
+
+	require.NoError(t, conn.WriteJSON(WSClientMessage{
+		Type:  WSEvent,
+		ID:    "evt-1",
+		Event: &EventRequest{ProductID: "abc123", Price: 49.99, Stock: 100},
+	}))
+
+	var reply WSServerMessage
+	require.NoError(t, conn.ReadJSON(&reply))
+	assert.Equal(t, WSServerMessage{Type: WSAck, ID: "evt-1"}, reply)
+
+	event, err := q.Dequeue()
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", event.ProductID)
+}
+
+func TestWebSocket_SubmitInvalidEvent(t *testing.T) {
+	conn, _, _ := setupWebSocketTest(t)
+
+	require.NoError(t, conn.WriteJSON(WSClientMessage{
+		Type:  WSEvent,
+		ID:    "evt-1",
+		Event: &EventRequest{ProductID: "abc123", Price: -1},
+	}))
+
+	var reply WSServerMessage
+	require.NoError(t, conn.ReadJSON(&reply))
+	assert.Equal(t, WSServerMessage{Type: WSError, ID: "evt-1", Error: "price must be non-negative"}, reply)
+}
+
+func TestWebSocket_ShutdownClosesWithGoingAway(t *testing.T) {
+	conn, broker, _ := setupWebSocketTest(t)
+
+	require.NoError(t, conn.WriteJSON(WSClientMessage{Type: WSSubscribe, ID: "1", ProductIDs: []string{"a"}}))
+	var reply WSServerMessage
+	require.NoError(t, conn.ReadJSON(&reply))
+
+	broker.Close()
+
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "got %v", err)
+}
+
+func TestWebSocket_ChecksOrigin(t *testing.T) {
+	server, _, _ := setupWebSocketServer(t, "https://pos.example.com")
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	tests := []struct {
+		name   string
+		origin string
+		ok     bool
+	}{
+		{"no origin", "", true},
+		{"same host", server.URL, true},
+		{"allowed", "https://pos.example.com", true},
+		{"foreign", "https://evil.example", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+
+			conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+			if tt.ok {
+				require.NoError(t, err)
+				conn.Close()
+				return
+			}
+			require.ErrorIs(t, err, websocket.ErrBadHandshake)
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		})
+	}
+}
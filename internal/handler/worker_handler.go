@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/raufhm/vfc/internal/auth"
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/requestid"
+	"github.com/raufhm/vfc/internal/worker"
+	"go.uber.org/zap"
+)
+
+// WorkerHandler is the admin API for the worker pool.
+type WorkerHandler struct {
+	pool    *worker.Pool
+	maxSize int
+	logger  *zap.Logger
+}
+
+func NewWorkerHandler(pool *worker.Pool, maxSize int, logger *zap.Logger) *WorkerHandler {
+	return &WorkerHandler{
+		pool:    pool,
+		maxSize: maxSize,
+		logger:  logger,
+	}
+}
+
+type ResizeRequest struct {
+	Size int `json:"size"`
+}
+
+// Validate reports every problem with the request as a *domain.ValidationError.
+func (r ResizeRequest) Validate(maxSize int) error {
+	var verr domain.ValidationError
+
+	if r.Size < 1 || r.Size > maxSize {
+		verr.Add("size", domain.CodeInvalid, fmt.Sprintf("size must be between 1 and %d", maxSize))
+	}
+
+	return verr.Err()
+}
+
+func (h *WorkerHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/workers", h.GetStatus).Methods("GET")
+	router.HandleFunc("/admin/workers/pause", h.Pause).Methods("POST")
+	router.HandleFunc("/admin/workers/resume", h.Resume).Methods("POST")
+	router.HandleFunc("/admin/workers/size", h.Resize).Methods("PUT")
+}
+
+func (h *WorkerHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	h.sendJSON(w, h.pool.Status(), http.StatusOK)
+}
+
+func (h *WorkerHandler) Pause(w http.ResponseWriter, r *http.Request) {
+	h.pool.Pause()
+	requestid.Logger(r.Context(), h.logger).Info("Worker pool paused by admin",
+		zap.String("principal", auth.PrincipalID(r.Context())))
+	h.sendJSON(w, h.pool.Status(), http.StatusOK)
+}
+
+func (h *WorkerHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	h.pool.Resume()
+	requestid.Logger(r.Context(), h.logger).Info("Worker pool resumed by admin",
+		zap.String("principal", auth.PrincipalID(r.Context())))
+	h.sendJSON(w, h.pool.Status(), http.StatusOK)
+}
+
+func (h *WorkerHandler) Resize(w http.ResponseWriter, r *http.Request) {
+	var req ResizeRequest
+
+	if p := decodeJSON(r, &req); p != nil {
+		writeProblem(w, r, h.logger, p)
+		return
+	}
+
+	if err := req.Validate(h.maxSize); err != nil {
+		writeError(w, r, h.logger, err)
+		return
+	}
+
+	if err := h.pool.Resize(req.Size); err != nil {
+		writeError(w, r, h.logger, err)
+		return
+	}
+
+	requestid.Logger(r.Context(), h.logger).Info("Worker pool resized by admin",
+		zap.Int("size", req.Size),
+		zap.String("principal", auth.PrincipalID(r.Context())))
+
+	h.sendJSON(w, h.pool.Status(), http.StatusOK)
+}
+
+func (h *WorkerHandler) sendJSON(w http.ResponseWriter, data interface{}, status int) {
+	writeJSON(w, h.logger, data, status)
+}
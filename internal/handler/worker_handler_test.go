@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/raufhm/vfc/internal/worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func setupWorkerTest(t *testing.T) (*worker.Pool, http.Handler) {
+	t.Helper()
+
+	logger, _ := zap.NewDevelopment()
+	productHandler, repo, q := setupTest()
+	pool := worker.NewPool(2, q, repo, logger)
+	pool.Start()
+	t.Cleanup(pool.Stop)
+
+	return pool, SetupRouter(productHandler, logger, NewWorkerHandler(pool, 4, logger))
+}
+
+func poolStatus(t *testing.T, rr *httptest.ResponseRecorder) worker.Status {
+	t.Helper()
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var status worker.Status
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &status))
+	return status
+}
+
+func TestWorkerHandler_PauseAndResume(t *testing.T) {
+	pool, router := setupWorkerTest(t)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/workers/pause", nil))
+	assert.True(t, poolStatus(t, rr).Paused)
+
+	// Ingestion keeps working while the pool is paused.
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, jsonRequest("POST", "/events", `{"product_id":"abc","price":1,"stock":1}`))
+	require.Equal(t, http.StatusAccepted, rr.Code)
+
+	require.Eventually(t, func() bool {
+		for _, w := range pool.Status().Workers {
+			if w.State != worker.StatePaused {
+				return false
+			}
+		}
+		return true
+	}, time.Second, 10*time.Millisecond)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/workers/resume", nil))
+	assert.False(t, poolStatus(t, rr).Paused)
+
+	require.Eventually(t, func() bool {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/products/abc", nil))
+		return rr.Code == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWorkerHandler_Resize(t *testing.T) {
+	_, router := setupWorkerTest(t)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, jsonRequest("PUT", "/admin/workers/size", `{"size":4}`))
+	status := poolStatus(t, rr)
+	assert.Equal(t, 4, status.Size)
+	assert.Len(t, status.Workers, 4)
+
+	for _, body := range []string{`{"size":0}`, `{"size":5}`} {
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, jsonRequest("PUT", "/admin/workers/size", body))
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+		var p Problem
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &p))
+		assert.Equal(t, CodeValidationFailed, p.Code)
+		assert.Equal(t, "size", p.Errors[0].Field)
+	}
+}
+
+func TestWorkerHandler_StatusReportsLastEvent(t *testing.T) {
+	pool, router := setupWorkerTest(t)
+	require.NoError(t, pool.Resize(1))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, jsonRequest("POST", "/events", `{"product_id":"abc","price":1,"stock":1}`))
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	requestID := rr.Header().Get("X-Request-ID")
+
+	var last *worker.LastEvent
+	require.Eventually(t, func() bool {
+		for _, w := range pool.Status().Workers {
+			if w.LastEvent != nil {
+				last = w.LastEvent
+				return true
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/workers", nil))
+	status := poolStatus(t, rr)
+	require.Len(t, status.Workers, 1)
+	assert.Equal(t, int64(1), status.Workers[0].Processed)
+	assert.Equal(t, "abc", last.ProductID)
+	assert.Equal(t, requestID, last.RequestID)
+}
+
+func TestWorkerHandler_ResizeAfterStop(t *testing.T) {
+	pool, router := setupWorkerTest(t)
+	pool.Stop()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, jsonRequest("PUT", "/admin/workers/size", `{"size":3}`))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(t, rr.Body.String(), CodePoolStopped)
+}
@@ -0,0 +1,38 @@
+package health
+
+import (
+	"context"
+	"fmt"
+)
+
+// Pinger is implemented by dependencies that can report whether they are
+// reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// PingCheck checks a dependency with its Ping method.
+func PingCheck(p Pinger) Check {
+	return p.Ping
+}
+
+// WorkersCheck fails unless every worker of the pool is running.
+func WorkersCheck(running, size func() int) Check {
+	return func(context.Context) error {
+		if r, s := running(), size(); r < s {
+			return fmt.Errorf("%d of %d workers running", r, s)
+		}
+		return nil
+	}
+}
+
+// SaturationCheck fails when a buffer is at least threshold full (0-1).
+func SaturationCheck(length, capacity func() int, threshold float64) Check {
+	return func(context.Context) error {
+		l, c := length(), capacity()
+		if c > 0 && float64(l) >= threshold*float64(c) {
+			return fmt.Errorf("queue %d of %d full", l, c)
+		}
+		return nil
+	}
+}
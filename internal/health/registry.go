@@ -0,0 +1,160 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrShuttingDown is reported by readiness once shutdown has begun.
+var ErrShuttingDown = errors.New("shutting down")
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check reports a problem with a component, or nil if it is healthy.
+type Check func(ctx context.Context) error
+
+// Result is the outcome of one check.
+type Result struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// Report is the outcome of every check of one kind.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Healthy reports whether every check passed.
+func (r Report) Healthy() bool {
+	return r.Status == StatusOK
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Registry holds the checks behind /livez and /readyz. Liveness checks
+// should only fail when restarting the process would help; readiness checks
+// fail whenever the instance should not receive traffic.
+type Registry struct {
+	timeout time.Duration
+
+	mu        sync.RWMutex
+	liveness  []namedCheck
+	readiness []namedCheck
+
+	shuttingDown atomic.Bool
+}
+
+// NewRegistry creates a registry that gives each check at most timeout.
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout}
+}
+
+// AddLiveness registers a check that decides whether the process is alive.
+func (r *Registry) AddLiveness(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.liveness = append(r.liveness, namedCheck{name, check})
+}
+
+// AddReadiness registers a check that decides whether the process can serve.
+func (r *Registry) AddReadiness(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.readiness = append(r.readiness, namedCheck{name, check})
+}
+
+// SetShuttingDown makes readiness fail from now on, so load balancers stop
+// routing new requests while in-flight ones finish.
+func (r *Registry) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// Live runs the liveness checks.
+func (r *Registry) Live(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := r.liveness
+	r.mu.RUnlock()
+
+	return r.run(ctx, checks)
+}
+
+// Ready runs the readiness checks.
+func (r *Registry) Ready(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := r.readiness
+	r.mu.RUnlock()
+
+	checks = append([]namedCheck{{"shutdown", r.checkShutdown}}, checks...)
+	return r.run(ctx, checks)
+}
+
+func (r *Registry) checkShutdown(context.Context) error {
+	if r.shuttingDown.Load() {
+		return ErrShuttingDown
+	}
+	return nil
+}
+
+// run executes checks concurrently so one slow dependency cannot hold up the
+// others past the timeout.
+func (r *Registry) run(ctx context.Context, checks []namedCheck) Report {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c namedCheck) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := runCheck(ctx, c.check)
+			result := Result{Status: StatusOK, DurationMs: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				result.Status = StatusFail
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			report.Checks[c.name] = result
+			if err != nil {
+				report.Status = StatusFail
+			}
+			mu.Unlock()
+		}(c)
+	}
+
+	wg.Wait()
+	return report
+}
+
+// runCheck returns when the check does or the context expires, whichever is
+// first. A check that ignores its context keeps running in the background.
+func runCheck(ctx context.Context, check Check) error {
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,58 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_ReportsEveryCheck(t *testing.T) {
+	r := NewRegistry(time.Second)
+	r.AddLiveness("ok", func(context.Context) error { return nil })
+	r.AddLiveness("broken", func(context.Context) error { return errors.New("boom") })
+
+	report := r.Live(context.Background())
+	assert.False(t, report.Healthy())
+	assert.Equal(t, StatusOK, report.Checks["ok"].Status)
+	assert.Equal(t, StatusFail, report.Checks["broken"].Status)
+	assert.Equal(t, "boom", report.Checks["broken"].Error)
+}
+
+func TestRegistry_TimesOutSlowChecks(t *testing.T) {
+	r := NewRegistry(20 * time.Millisecond)
+	block := make(chan struct{})
+	defer close(block)
+	r.AddReadiness("stuck", func(context.Context) error { <-block; return nil })
+	r.AddReadiness("fast", func(context.Context) error { return nil })
+
+	start := time.Now()
+	report := r.Ready(context.Background())
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["stuck"].Error)
+	assert.Equal(t, StatusOK, report.Checks["fast"].Status)
+}
+
+func TestRegistry_ReadinessFailsAfterShutdown(t *testing.T) {
+	r := NewRegistry(time.Second)
+	assert.True(t, r.Ready(context.Background()).Healthy())
+
+	r.SetShuttingDown()
+
+	report := r.Ready(context.Background())
+	assert.False(t, report.Healthy())
+	assert.Equal(t, ErrShuttingDown.Error(), report.Checks["shutdown"].Error)
+	assert.True(t, r.Live(context.Background()).Healthy())
+}
+
+func TestWorkersCheck(t *testing.T) {
+	running := 3
+	check := WorkersCheck(func() int { return running }, func() int { return 3 })
+	assert.NoError(t, check(context.Background()))
+
+	running = 2
+	assert.EqualError(t, check(context.Background()), "2 of 3 workers running")
+}
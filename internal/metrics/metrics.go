@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/raufhm/vfc/internal/domain"
+)
+
+const namespace = "vfc"
+
+// Metrics holds the collectors for the HTTP API and the event pipeline.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	eventsProcessed *prometheus.CounterVec
+	eventsFailed    *prometheus.CounterVec
+	eventLatency    prometheus.Histogram
+	scaleDecisions  *prometheus.CounterVec
+	workerPanics    *prometheus.CounterVec
+	eventsCoalesced prometheus.Counter
+}
+
+// New creates the collectors on a registry of their own, together with the
+// Go runtime and process collectors.
+func New() *Metrics {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	m := &Metrics{
+		registry: registry,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route template, method and status code.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route template and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		eventsProcessed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_processed_total",
+			Help:      "Events saved successfully, by worker.",
+		}, []string{"worker"}),
+		eventsFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_failed_total",
+			Help:      "Events that failed to save, by worker.",
+		}, []string{"worker"}),
+		eventLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "event_processing_latency_seconds",
+			Help:      "Time from an event's timestamp until its product was saved.",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+		}),
+		scaleDecisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "autoscaler_decisions_total",
+			Help:      "Worker pool resizes made by the autoscaler, by direction and the signal that caused them.",
+		}, []string{"direction", "reason"}),
+		workerPanics: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "worker_panics_total",
+			Help:      "Panics recovered while processing events, by worker.",
+		}, []string{"worker"}),
+		eventsCoalesced: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_coalesced_total",
+			Help:      "Events dropped because a newer event for the same product replaced them.",
+		}),
+	}
+	registry.MustRegister(m.requests, m.requestDuration, m.eventsProcessed, m.eventsFailed, m.eventLatency,
+		m.scaleDecisions, m.workerPanics, m.eventsCoalesced)
+	return m
+}
+
+// Registry returns the registry to serve the metrics from.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// ObserveRequest records a completed HTTP request.
+func (m *Metrics) ObserveRequest(route, method string, status int, duration time.Duration) {
+	m.requests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.requestDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+// ObserveEvent records the outcome of a worker processing an event.
+func (m *Metrics) ObserveEvent(workerID int, event *domain.Event, latency time.Duration, err error) {
+	worker := strconv.Itoa(workerID)
+	if err != nil {
+		m.eventsFailed.WithLabelValues(worker).Inc()
+		return
+	}
+	m.eventsProcessed.WithLabelValues(worker).Inc()
+	if latency > 0 {
+		m.eventLatency.Observe(latency.Seconds())
+	}
+}
+
+// ObservePanic records a worker recovering from a panic. The event is also
+// counted as failed.
+func (m *Metrics) ObservePanic(workerID int) {
+	m.workerPanics.WithLabelValues(strconv.Itoa(workerID)).Inc()
+}
+
+// ObserveCoalesced records an event replaced by a newer one before a worker
+// saw it.
+func (m *Metrics) ObserveCoalesced(event *domain.Event) {
+	m.eventsCoalesced.Inc()
+}
+
+// ObserveScale records an autoscaler resizing the worker pool.
+func (m *Metrics) ObserveScale(from, to int, reason string) {
+	direction := "up"
+	if to < from {
+		direction = "down"
+	}
+	m.scaleDecisions.WithLabelValues(direction, reason).Inc()
+}
+
+// WatchPool exports the configured and running number of workers.
+func (m *Metrics) WatchPool(size, running func() int) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "workers",
+			Help:      "Workers the pool is meant to run.",
+		}, func() float64 { return float64(size()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "workers_running",
+			Help:      "Worker goroutines alive, including retiring ones.",
+		}, func() float64 { return float64(running()) }),
+	)
+}
+
+// WatchQueue exports the depth and capacity of a buffered queue.
+func (m *Metrics) WatchQueue(length, capacity func() int) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "queue_depth",
+			Help:      "Events waiting in the queue.",
+		}, func() float64 { return float64(length()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "queue_capacity",
+			Help:      "Events the queue can buffer.",
+		}, func() float64 { return float64(capacity()) }),
+	)
+}
+
+// WatchCoalescer exports the number of events held for coalescing.
+func (m *Metrics) WatchCoalescer(held func() int) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "coalescer_held_events",
+		Help:      "Events waiting out the coalescing window.",
+	}, func() float64 { return float64(held()) }))
+}
+
+// WatchRepository exports the number of stored products.
+func (m *Metrics) WatchRepository(count func() int) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "repository_products",
+		Help:      "Products in the repository.",
+	}, func() float64 { return float64(count()) }))
+}
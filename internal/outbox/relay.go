@@ -0,0 +1,182 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/repository"
+	"go.uber.org/zap"
+)
+
+// Sink receives published ProductChanged records.
+type Sink interface {
+	Publish(ctx context.Context, change *domain.ProductChange) error
+}
+
+// DefaultMaxAttempts is how often a change is offered to the sink before the
+// relay gives up on it.
+const DefaultMaxAttempts = 5
+
+// Relay polls the outbox and publishes pending changes to a sink. A change is
+// only marked published after the sink accepts it, so delivery is
+// at-least-once. When a change fails, later changes for the same product are
+// held back until it succeeds, which keeps delivery ordered per product. A
+// change that keeps failing is logged and marked failed after maxAttempts, so
+// it cannot hold back its product, or fill every batch, forever.
+type Relay struct {
+	store       repository.OutboxRepository
+	sink        Sink
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	logger      *zap.Logger
+	wg          sync.WaitGroup
+	ctx         context.Context
+	cancel      context.CancelFunc
+
+	// attempts counts failed publishes per change ID.
+	mu       sync.Mutex
+	attempts map[uint64]int
+}
+
+func NewRelay(store repository.OutboxRepository, sink Sink, interval time.Duration, batchSize int, logger *zap.Logger) *Relay {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Relay{
+		store:       store,
+		sink:        sink,
+		interval:    interval,
+		batchSize:   batchSize,
+		maxAttempts: DefaultMaxAttempts,
+		logger:      logger,
+		ctx:         ctx,
+		cancel:      cancel,
+		attempts:    make(map[uint64]int),
+	}
+}
+
+// MaxAttempts sets how often a change is offered to the sink before it is
+// marked failed. It must be called before Start.
+func (r *Relay) MaxAttempts(attempts int) {
+	r.maxAttempts = attempts
+}
+
+func (r *Relay) Start() {
+	r.logger.Info("Starting outbox relay", zap.Duration("interval", r.interval))
+
+	r.wg.Add(1)
+	go r.run()
+}
+
+// Stop publishes batches until nothing more can be published, so changes
+// saved just before shutdown are not left pending. It gives up when ctx ends.
+// Whatever is left stays in the outbox: a durable repository publishes it
+// after the next start, but the in-memory one loses it.
+func (r *Relay) Stop(ctx context.Context) {
+	r.logger.Info("Stopping outbox relay")
+	r.cancel()
+	r.wg.Wait()
+	for ctx.Err() == nil {
+		if r.RelayOnce(ctx) == 0 {
+			break
+		}
+	}
+	if ctx.Err() != nil {
+		r.logger.Warn("Outbox relay stopped with changes pending", zap.Error(ctx.Err()))
+		return
+	}
+	r.logger.Info("Outbox relay stopped")
+}
+
+func (r *Relay) run() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			r.RelayOnce(r.ctx)
+		}
+	}
+}
+
+// RelayOnce publishes one batch of pending changes and returns how many were
+// published or marked failed.
+func (r *Relay) RelayOnce(ctx context.Context) int {
+	changes, err := r.store.PendingOutbox(ctx, r.batchSize)
+	if err != nil {
+		r.logger.Error("Failed to read outbox", zap.Error(err))
+		return 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	blocked := make(map[string]bool)
+	var published, failed []uint64
+
+	for _, change := range changes {
+		productID := change.Product.ProductID
+		if blocked[productID] {
+			continue
+		}
+
+		if err := r.sink.Publish(ctx, change); err != nil {
+			r.attempts[change.ID]++
+			if r.attempts[change.ID] >= r.maxAttempts {
+				r.logger.Error("Failed to publish product change, giving up",
+					zap.Uint64("change_id", change.ID),
+					zap.String("product_id", productID),
+					zap.Int("attempts", r.attempts[change.ID]),
+					zap.Any("change", change),
+					zap.Error(err))
+				failed = append(failed, change.ID)
+			} else {
+				r.logger.Error("Failed to publish product change",
+					zap.Uint64("change_id", change.ID),
+					zap.String("product_id", productID),
+					zap.Int("attempt", r.attempts[change.ID]),
+					zap.Error(err))
+			}
+			blocked[productID] = true
+			continue
+		}
+
+		published = append(published, change.ID)
+	}
+
+	settled := 0
+	// The sink has accepted these changes, so record that even if ctx was
+	// canceled meanwhile rather than publish them again.
+	if len(published) > 0 {
+		if err := r.store.MarkPublished(context.WithoutCancel(ctx), published...); err != nil {
+			// The changes stay pending and will be published again.
+			r.logger.Error("Failed to mark outbox entries published", zap.Error(err))
+		} else {
+			settled += len(published)
+			r.forget(published)
+		}
+	}
+	if len(failed) > 0 {
+		if err := r.store.MarkFailed(context.WithoutCancel(ctx), failed...); err != nil {
+			r.logger.Error("Failed to mark outbox entries failed", zap.Error(err))
+		} else {
+			settled += len(failed)
+			r.forget(failed)
+		}
+	}
+
+	return settled
+}
+
+// forget drops the attempt counts of settled changes. r.mu must be held.
+func (r *Relay) forget(ids []uint64) {
+	for _, id := range ids {
+		delete(r.attempts, id)
+	}
+}
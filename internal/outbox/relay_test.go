@@ -0,0 +1,189 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type recordingSink struct {
+	mu        sync.Mutex
+	published []*domain.ProductChange
+	failFor   map[string]bool
+}
+
+func (s *recordingSink) Publish(_ context.Context, change *domain.ProductChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failFor[change.Product.ProductID] {
+		return errors.New("sink unavailable")
+	}
+	s.published = append(s.published, change)
+	return nil
+}
+
+func saveAll(t *testing.T, repo *repository.InMemoryRepository, ids ...string) {
+	t.Helper()
+	for i, id := range ids {
+		_, err := repo.SaveWithOutbox(context.Background(), domain.NewProduct(id, float64(i), i))
+		require.NoError(t, err)
+	}
+}
+
+func TestRelay_PublishesInOrderAndMarksPublished(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := repository.NewInMemoryRepository()
+	sink := &recordingSink{}
+	relay := NewRelay(repo, sink, time.Second, 100, logger)
+
+	saveAll(t, repo, "a", "b", "a")
+
+	assert.Equal(t, 3, relay.RelayOnce(context.Background()))
+
+	require.Len(t, sink.published, 3)
+	assert.Equal(t, domain.ChangeCreated, sink.published[0].Type)
+	assert.Equal(t, domain.ChangeCreated, sink.published[1].Type)
+	assert.Equal(t, domain.ChangeUpdated, sink.published[2].Type)
+
+	pending, err := repo.PendingOutbox(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestRelay_FailureHoldsBackLaterChangesForSameProduct(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := repository.NewInMemoryRepository()
+	sink := &recordingSink{failFor: map[string]bool{"a": true}}
+	relay := NewRelay(repo, sink, time.Second, 100, logger)
+
+	saveAll(t, repo, "a", "b", "a")
+
+	assert.Equal(t, 1, relay.RelayOnce(context.Background()))
+	require.Len(t, sink.published, 1)
+	assert.Equal(t, "b", sink.published[0].Product.ProductID)
+
+	pending, err := repo.PendingOutbox(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+
+	sink.failFor = nil
+	assert.Equal(t, 2, relay.RelayOnce(context.Background()))
+	assert.Less(t, sink.published[1].ID, sink.published[2].ID)
+}
+
+func TestWebhookSink_Publish(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, EventProductChanged, r.Header.Get("X-Event-Type"))
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL, time.Second)
+	change := domain.NewProductChange(domain.ChangeCreated, domain.NewProduct("abc123", 49.99, 100))
+	change.ID = 1<<53 + 1
+
+	require.NoError(t, sink.Publish(context.Background(), change))
+	assert.Equal(t, EventProductChanged, received["event"])
+	assert.Equal(t, "9007199254740993", received["id"], "IDs past 2^53 must survive JSON number parsing")
+	assert.Equal(t, "abc123", received["product"].(map[string]interface{})["product_id"])
+}
+
+func TestWebhookSink_PublishRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL, time.Second)
+	change := domain.NewProductChange(domain.ChangeCreated, domain.NewProduct("abc123", 49.99, 100))
+
+	assert.Error(t, sink.Publish(context.Background(), change))
+}
+
+func TestFileSink_Publish(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	sink, err := NewFileSink(path)
+	require.NoError(t, err)
+
+	for _, id := range []string{"a", "b"} {
+		change := domain.NewProductChange(domain.ChangeCreated, domain.NewProduct(id, 1, 1))
+		require.NoError(t, sink.Publish(context.Background(), change))
+	}
+	require.NoError(t, sink.Close())
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var ids []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var msg message
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &msg))
+		ids = append(ids, msg.Product.ProductID)
+	}
+	assert.Equal(t, []string{"a", "b"}, ids)
+}
+
+func TestRelay_StopPublishesEveryPendingBatch(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := repository.NewInMemoryRepository()
+	sink := &recordingSink{}
+	relay := NewRelay(repo, sink, time.Hour, 2, logger)
+	relay.Start()
+
+	saveAll(t, repo, "a", "b", "c", "d", "e")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	relay.Stop(ctx)
+
+	assert.Len(t, sink.published, 5)
+	pending, err := repo.PendingOutbox(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestRelay_GivesUpOnChangesThatKeepFailing(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := repository.NewInMemoryRepository()
+	sink := &recordingSink{failFor: map[string]bool{"a": true}}
+	relay := NewRelay(repo, sink, time.Second, 2, logger)
+	relay.MaxAttempts(2)
+
+	// The failing product fills the whole batch, so "b" is not even read
+	// until the relay gives up on it.
+	saveAll(t, repo, "a", "a", "b")
+
+	assert.Equal(t, 0, relay.RelayOnce(context.Background()))
+	require.Empty(t, sink.published)
+
+	// The first change is marked failed, then "b" gets through while the
+	// second change for "a" fails twice in turn.
+	assert.Equal(t, 1, relay.RelayOnce(context.Background()))
+	assert.Equal(t, 1, relay.RelayOnce(context.Background()))
+	assert.Equal(t, 1, relay.RelayOnce(context.Background()))
+
+	require.Len(t, sink.published, 1)
+	assert.Equal(t, "b", sink.published[0].Product.ProductID)
+	pending, err := repo.PendingOutbox(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
@@ -0,0 +1,142 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/segmentio/kafka-go"
+)
+
+const EventProductChanged = "ProductChanged"
+
+// message is the wire format shared by all sinks.
+type message struct {
+	Event string `json:"event"`
+	*domain.ProductChange
+}
+
+func encode(change *domain.ProductChange) ([]byte, error) {
+	return json.Marshal(message{Event: EventProductChanged, ProductChange: change})
+}
+
+// WebhookSink POSTs each change to a fixed URL and treats any 2xx response as
+// delivered.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, change *domain.ProductChange) error {
+	body, err := encode(change)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Type", EventProductChanged)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// FileSink appends each change as a JSON line and syncs before reporting
+// success.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbox file: %w", err)
+	}
+	return &FileSink{file: file}, nil
+}
+
+func (s *FileSink) Publish(_ context.Context, change *domain.ProductChange) error {
+	body, err := encode(change)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Write(append(body, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// QueueSink produces each change to a Kafka topic keyed by product ID, so
+// consumers see changes for a product in order.
+type QueueSink struct {
+	writer messageWriter
+}
+
+func NewQueueSink(brokers []string, topic string) *QueueSink {
+	return &QueueSink{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			BatchTimeout: 10 * time.Millisecond,
+		},
+	}
+}
+
+func (s *QueueSink) Publish(ctx context.Context, change *domain.ProductChange) error {
+	body, err := encode(change)
+	if err != nil {
+		return err
+	}
+
+	return s.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(change.Product.ProductID),
+		Value: body,
+		Headers: []kafka.Header{
+			{Key: "event", Value: []byte(EventProductChanged)},
+		},
+	})
+}
+
+func (s *QueueSink) Close() error {
+	return s.writer.Close()
+}
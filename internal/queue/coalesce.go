@@ -0,0 +1,324 @@
+package queue
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"go.uber.org/zap"
+)
+
+// CoalesceObserver is told about every event dropped in favour of a newer
+// one for the same product.
+type CoalesceObserver interface {
+	ObserveCoalesced(event *domain.Event)
+}
+
+// Coalescer sits between a queue and the worker pool. It holds each event
+// for a window before passing it on, and an event for a product that is
+// already held replaces it, so the workers only save the newest state. The
+// replaced event is acknowledged at once. The newer of two events is the one
+// with the later timestamp, so a redelivered event cannot overwrite a newer
+// one that arrived before it.
+//
+// While a worker processes an event, the next event for the same product is
+// held until the first is acknowledged or rejected, however long that takes.
+// Two workers therefore never save the same product at once, and a slow save
+// cannot land after, and overwrite, a newer one.
+//
+// Events held by the Coalescer are not acknowledged, so a broker queue's
+// prefetch limit also caps how many events can be held at once.
+type Coalescer struct {
+	queue    QueueProvider
+	window   time.Duration
+	out      chan *domain.Event
+	observer CoalesceObserver
+	logger   *zap.Logger
+
+	// entries and pending are owned by run until it returns.
+	entries []*heldEvent
+	pending map[string]*heldEvent
+	held    atomic.Int32
+
+	// inFlight maps each product to the event a worker is processing for it.
+	// settled is signalled when one of them is acknowledged or rejected.
+	mu       sync.Mutex
+	inFlight map[string]*domain.Event
+	settled  chan struct{}
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	abort    chan struct{}
+	done     chan struct{}
+}
+
+type heldEvent struct {
+	event *domain.Event
+	due   time.Time
+}
+
+// NewCoalescer wraps queue with a coalescing stage. Give the Coalescer to the
+// worker pool in place of queue.
+func NewCoalescer(queue QueueProvider, window time.Duration, logger *zap.Logger) *Coalescer {
+	return &Coalescer{
+		queue:    queue,
+		window:   window,
+		out:      make(chan *domain.Event),
+		logger:   logger,
+		pending:  make(map[string]*heldEvent),
+		inFlight: make(map[string]*domain.Event),
+		settled:  make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		abort:    make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// OnCoalesce registers an observer for dropped events, such as metrics. It
+// must be set before Start.
+func (c *Coalescer) OnCoalesce(observer CoalesceObserver) {
+	c.observer = observer
+}
+
+func (c *Coalescer) Start() {
+	c.logger.Info("Starting update coalescing", zap.Duration("window", c.window))
+	go c.run()
+}
+
+// Stop passes on every held event without waiting out its window, followed by
+// whatever the queue still has ready, and then stops. Call it before draining
+// the worker pool, which must keep consuming meanwhile. If ctx ends first,
+// Stop returns ctx.Err() and the events still held are left for Flush.
+func (c *Coalescer) Stop(ctx context.Context) error {
+	c.logger.Info("Stopping update coalescing", zap.Int("held", c.Held()))
+	c.stopOnce.Do(func() { close(c.stop) })
+
+	select {
+	case <-c.done:
+		c.logger.Info("Update coalescing stopped")
+		return nil
+	case <-ctx.Done():
+		close(c.abort)
+		<-c.done
+		c.logger.Warn("Update coalescing stopped with events held", zap.Int("held", c.Held()), zap.Error(ctx.Err()))
+		return ctx.Err()
+	}
+}
+
+// Flush removes and returns the events still held, oldest first. Call it only
+// after Stop.
+func (c *Coalescer) Flush() []*domain.Event {
+	events := make([]*domain.Event, 0, len(c.entries))
+	for len(c.entries) > 0 {
+		events = append(events, c.take(0).event)
+	}
+	return events
+}
+
+// Held returns the number of events waiting out their window.
+func (c *Coalescer) Held() int {
+	return int(c.held.Load())
+}
+
+// Connect and Close pass through to the underlying queue.
+func (c *Coalescer) Connect() error {
+	return c.queue.Connect()
+}
+
+func (c *Coalescer) Close() error {
+	return c.queue.Close()
+}
+
+func (c *Coalescer) Enqueue(ctx context.Context, event *domain.Event) error {
+	return c.queue.Enqueue(ctx, event)
+}
+
+func (c *Coalescer) Dequeue() (*domain.Event, error) {
+	event := <-c.out
+	return event, nil
+}
+
+func (c *Coalescer) GetChannel() <-chan *domain.Event {
+	return c.out
+}
+
+func (c *Coalescer) Ack(event *domain.Event) error {
+	defer c.settle(event)
+	if acker, ok := c.queue.(Acknowledger); ok {
+		return acker.Ack(event)
+	}
+	return nil
+}
+
+func (c *Coalescer) Nack(event *domain.Event) error {
+	defer c.settle(event)
+	if acker, ok := c.queue.(Acknowledger); ok {
+		return acker.Nack(event)
+	}
+	return nil
+}
+
+// settle releases the hold on event's product if event is the one in flight.
+func (c *Coalescer) settle(event *domain.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.inFlight[event.ProductID] != event {
+		return
+	}
+	delete(c.inFlight, event.ProductID)
+	select {
+	case c.settled <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Coalescer) run() {
+	defer close(c.done)
+
+	in := c.queue.GetChannel()
+	stop := c.stop
+	flushing := false
+	timer := time.NewTimer(c.window)
+	defer timer.Stop()
+
+	for {
+		var out chan<- *domain.Event
+		var next *domain.Event
+		var wake <-chan time.Time
+		ready := -1
+
+		switch {
+		case len(c.entries) > 0:
+			// With nothing ready and nothing due, every held product is in
+			// flight, and only settled can change that.
+			var wait time.Duration
+			if ready, wait = c.ready(flushing); ready >= 0 {
+				out, next = c.out, c.entries[ready].event
+				// Mark it first: the worker that receives it may settle it
+				// before this loop runs again.
+				c.setInFlight(next, true)
+			} else if wait > 0 {
+				timer.Reset(wait)
+				wake = timer.C
+			}
+		case in == nil:
+			c.logger.Info("Queue closed, update coalescing stopped")
+			close(c.out)
+			return
+		case flushing:
+			// Take what the queue has ready without waiting for more.
+			select {
+			case event, ok := <-in:
+				if !ok {
+					in = nil
+				} else if event != nil {
+					c.add(event)
+				}
+				continue
+			default:
+				return
+			}
+		}
+
+		sent := false
+		select {
+		case <-stop:
+			flushing, stop = true, nil
+		case <-c.abort:
+			return
+		case event, ok := <-in:
+			if !ok {
+				in = nil
+			} else if event != nil {
+				c.add(event)
+			}
+		case out <- next:
+			c.take(ready)
+			sent = true
+		case <-wake:
+		case <-c.settled:
+		}
+		if next != nil && !sent {
+			c.setInFlight(next, false)
+		}
+	}
+}
+
+// ready returns the index of the first held event that may be passed on: its
+// window is over, or the Coalescer is flushing, and no event for its product
+// is in flight. Otherwise it returns -1 and how long until the next window
+// ends, or 0 when every held event waits on one in flight.
+func (c *Coalescer) ready(flushing bool) (int, time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for i, held := range c.entries {
+		if _, busy := c.inFlight[held.event.ProductID]; busy {
+			continue
+		}
+		// Entries are in due order, so later ones are not due either.
+		if wait := held.due.Sub(now); !flushing && wait > 0 {
+			return -1, wait
+		}
+		return i, 0
+	}
+	return -1, 0
+}
+
+// setInFlight marks event as being processed, or clears the mark.
+func (c *Coalescer) setInFlight(event *domain.Event, inFlight bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if inFlight {
+		c.inFlight[event.ProductID] = event
+	} else if c.inFlight[event.ProductID] == event {
+		delete(c.inFlight, event.ProductID)
+	}
+}
+
+// add holds event, or merges it with the event already held for its product.
+func (c *Coalescer) add(event *domain.Event) {
+	held, ok := c.pending[event.ProductID]
+	if !ok {
+		held = &heldEvent{event: event, due: time.Now().Add(c.window)}
+		c.pending[event.ProductID] = held
+		c.entries = append(c.entries, held)
+		c.held.Add(1)
+		return
+	}
+
+	dropped := event
+	if !event.Timestamp.Before(held.event.Timestamp) {
+		dropped, held.event = held.event, event
+	}
+
+	c.logger.Debug("Event coalesced",
+		zap.String("product_id", event.ProductID),
+		zap.String("request_id", dropped.RequestID),
+		zap.String("kept_request_id", held.event.RequestID))
+
+	if err := c.Ack(dropped); err != nil {
+		c.logger.Error("Failed to acknowledge coalesced event",
+			zap.String("product_id", dropped.ProductID),
+			zap.String("request_id", dropped.RequestID),
+			zap.Error(err))
+	}
+	if c.observer != nil {
+		c.observer.ObserveCoalesced(dropped)
+	}
+}
+
+// take removes the held event at index i.
+func (c *Coalescer) take(i int) *heldEvent {
+	held := c.entries[i]
+	c.entries = slices.Delete(c.entries, i, i+1)
+	delete(c.pending, held.event.ProductID)
+	c.held.Add(-1)
+	return held
+}
@@ -0,0 +1,186 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// ackingQueue records which events were acknowledged.
+type ackingQueue struct {
+	*InMemoryQueue
+
+	mu    sync.Mutex
+	acked []*domain.Event
+}
+
+func (q *ackingQueue) Ack(event *domain.Event) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.acked = append(q.acked, event)
+	return nil
+}
+
+func (q *ackingQueue) Nack(event *domain.Event) error {
+	return nil
+}
+
+type coalesceCounter struct {
+	mu      sync.Mutex
+	dropped []string
+}
+
+func (c *coalesceCounter) ObserveCoalesced(event *domain.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dropped = append(c.dropped, event.RequestID)
+}
+
+func newEvent(productID, requestID string, price float64, at time.Time) *domain.Event {
+	event := domain.NewEvent(productID, price, 1)
+	event.RequestID = requestID
+	event.Timestamp = at
+	return event
+}
+
+func passedOn(t *testing.T, events <-chan *domain.Event) *domain.Event {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no event passed on")
+		return nil
+	}
+}
+
+func TestCoalescer_KeepsNewestEventPerProduct(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	q := &ackingQueue{InMemoryQueue: NewInMemoryQueue(10, logger)}
+	c := NewCoalescer(q, 50*time.Millisecond, logger)
+	observer := &coalesceCounter{}
+	c.OnCoalesce(observer)
+
+	now := time.Now()
+	require.NoError(t, c.Enqueue(context.Background(), newEvent("a", "req-1", 1, now)))
+	require.NoError(t, c.Enqueue(context.Background(), newEvent("a", "req-2", 2, now.Add(time.Millisecond))))
+	require.NoError(t, c.Enqueue(context.Background(), newEvent("b", "req-3", 5, now.Add(2*time.Millisecond))))
+	require.NoError(t, c.Enqueue(context.Background(), newEvent("a", "req-4", 3, now.Add(3*time.Millisecond))))
+
+	c.Start()
+	defer c.Stop(context.Background())
+
+	first := passedOn(t, c.GetChannel())
+	assert.Equal(t, "req-4", first.RequestID)
+	assert.Equal(t, 3.0, first.Price)
+	assert.Equal(t, "req-3", passedOn(t, c.GetChannel()).RequestID)
+
+	assert.Equal(t, []string{"req-1", "req-2"}, observer.dropped)
+	require.Len(t, q.acked, 2)
+	assert.Equal(t, "req-1", q.acked[0].RequestID)
+	assert.Eventually(t, func() bool { return c.Held() == 0 }, time.Second, time.Millisecond)
+}
+
+func TestCoalescer_KeepsNewerEventOverLateRedelivery(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	q := NewInMemoryQueue(10, logger)
+	c := NewCoalescer(q, 50*time.Millisecond, logger)
+
+	now := time.Now()
+	require.NoError(t, c.Enqueue(context.Background(), newEvent("a", "newer", 2, now)))
+	require.NoError(t, c.Enqueue(context.Background(), newEvent("a", "older", 1, now.Add(-time.Second))))
+
+	c.Start()
+	defer c.Stop(context.Background())
+
+	assert.Equal(t, "newer", passedOn(t, c.GetChannel()).RequestID)
+}
+
+func TestCoalescer_HoldsProductWhileInFlight(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	q := NewInMemoryQueue(10, logger)
+	c := NewCoalescer(q, 10*time.Millisecond, logger)
+	c.Start()
+	defer c.Stop(context.Background())
+
+	now := time.Now()
+	require.NoError(t, c.Enqueue(context.Background(), newEvent("a", "first", 1, now)))
+	first := passedOn(t, c.GetChannel())
+
+	require.NoError(t, c.Enqueue(context.Background(), newEvent("a", "second", 2, now.Add(time.Millisecond))))
+	require.NoError(t, c.Enqueue(context.Background(), newEvent("b", "other", 3, now.Add(2*time.Millisecond))))
+
+	// Other products are not held up.
+	assert.Equal(t, "other", passedOn(t, c.GetChannel()).RequestID)
+	select {
+	case event := <-c.GetChannel():
+		t.Fatalf("%s passed on while an event for its product was in flight", event.RequestID)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, c.Nack(first))
+	assert.Equal(t, "second", passedOn(t, c.GetChannel()).RequestID)
+}
+
+func TestCoalescer_StopHandsOverHeldEvents(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	q := NewInMemoryQueue(10, logger)
+	c := NewCoalescer(q, time.Hour, logger)
+	c.Start()
+
+	require.NoError(t, c.Enqueue(context.Background(), domain.NewEvent("a", 1, 1)))
+	require.NoError(t, c.Enqueue(context.Background(), domain.NewEvent("b", 1, 1)))
+	require.Eventually(t, func() bool { return c.Held() == 2 }, time.Second, time.Millisecond)
+	require.NoError(t, c.Enqueue(context.Background(), domain.NewEvent("c", 1, 1)))
+
+	var received []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			received = append(received, (<-c.GetChannel()).ProductID)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, c.Stop(ctx))
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("held events were not handed over")
+	}
+
+	// Held events go first, then what was still queued, none waiting out
+	// the window.
+	assert.Equal(t, []string{"a", "b", "c"}, received)
+	assert.Empty(t, c.Flush())
+}
+
+func TestCoalescer_StopLeavesUnclaimedEventsForFlush(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	q := NewInMemoryQueue(10, logger)
+	c := NewCoalescer(q, time.Hour, logger)
+	c.Start()
+
+	require.NoError(t, c.Enqueue(context.Background(), domain.NewEvent("a", 1, 1)))
+	require.NoError(t, c.Enqueue(context.Background(), domain.NewEvent("b", 1, 1)))
+	require.Eventually(t, func() bool { return c.Held() == 2 }, time.Second, time.Millisecond)
+
+	// Nothing consumes, so the held events cannot be handed over.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, c.Stop(ctx), context.DeadlineExceeded)
+
+	flushed := c.Flush()
+	require.Len(t, flushed, 2)
+	assert.Equal(t, "a", flushed[0].ProductID)
+	assert.Equal(t, "b", flushed[1].ProductID)
+	assert.Equal(t, 0, c.Held())
+}
@@ -0,0 +1,446 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// maxLaneBacklog bounds how many fetched messages a partition may buffer while
+// it waits for its in-flight event. Only a partition past the bound holds up
+// fetching; the others keep their own backlogs.
+const maxLaneBacklog = 1000
+
+// KafkaConfig describes the topic KafkaQueue consumes from and produces to.
+// An event that fails MaxRetries redeliveries is moved to <Topic>.dead.
+type KafkaConfig struct {
+	Brokers    []string
+	Topic      string
+	GroupID    string
+	RetryDelay time.Duration
+	MaxRetries int
+}
+
+// kafkaReader and kafkaWriter cover the parts of the client library the queue
+// relies on so tests can substitute an in-process broker.
+type kafkaReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// KafkaQueue is a QueueProvider backed by a partitioned Kafka topic. Each
+// partition has at most one event in flight, so workers process events for the
+// same key in order, and a partition's offset is only committed once the
+// worker acks the event. Nacked events are redelivered after RetryDelay, up to
+// MaxRetries times, and then dead-lettered so they cannot block the partition.
+type KafkaQueue struct {
+	config     KafkaConfig
+	logger     *zap.Logger
+	reader     kafkaReader
+	writer     kafkaWriter
+	deadLetter kafkaWriter
+
+	mu         sync.Mutex
+	partitions map[int]*lane
+	pending    map[*domain.Event]chan bool
+
+	events    chan *domain.Event
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+func NewKafkaQueue(config KafkaConfig, logger *zap.Logger) *KafkaQueue {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: config.Brokers,
+		GroupID: config.GroupID,
+		Topic:   config.Topic,
+	})
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(config.Brokers...),
+		Topic:        config.Topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 10 * time.Millisecond,
+	}
+	deadLetter := &kafka.Writer{
+		Addr:         kafka.TCP(config.Brokers...),
+		Topic:        config.Topic + ".dead",
+		RequiredAcks: kafka.RequireAll,
+	}
+	return newKafkaQueue(config, logger, reader, writer, deadLetter)
+}
+
+func newKafkaQueue(config KafkaConfig, logger *zap.Logger, reader kafkaReader, writer, deadLetter kafkaWriter) *KafkaQueue {
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &KafkaQueue{
+		config:     config,
+		logger:     logger,
+		reader:     reader,
+		writer:     writer,
+		deadLetter: deadLetter,
+		partitions: make(map[int]*lane),
+		pending:    make(map[*domain.Event]chan bool),
+		events:     make(chan *domain.Event),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+func (q *KafkaQueue) Connect() error {
+	q.wg.Add(1)
+	go q.fetch()
+
+	q.logger.Info("KafkaQueue connected",
+		zap.Strings("brokers", q.config.Brokers),
+		zap.String("topic", q.config.Topic),
+		zap.String("group_id", q.config.GroupID))
+	return nil
+}
+
+func (q *KafkaQueue) Close() error {
+	var err error
+	q.closeOnce.Do(func() {
+		q.cancel()
+		q.wg.Wait()
+		close(q.events)
+
+		err = errors.Join(q.reader.Close(), q.writer.Close(), q.deadLetter.Close())
+		q.logger.Info("KafkaQueue closed")
+	})
+	return err
+}
+
+// Enqueue produces the event to the topic keyed by product ID, so every
+// update for a product lands on the same partition. It gives up when ctx is
+// done or the queue is closed.
+func (q *KafkaQueue) Enqueue(ctx context.Context, event *domain.Event) error {
+	if q.ctx.Err() != nil {
+		return ErrNotConnected
+	}
+
+	value, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(q.ctx, cancel)
+	defer stop()
+
+	return q.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(event.ProductID),
+		Value: value,
+		Time:  event.Timestamp,
+	})
+}
+
+// Ping dials the brokers and succeeds as soon as one accepts a connection.
+func (q *KafkaQueue) Ping(ctx context.Context) error {
+	if q.ctx.Err() != nil {
+		return ErrNotConnected
+	}
+
+	var dialer kafka.Dialer
+	var errs []error
+	for _, broker := range q.config.Brokers {
+		conn, err := dialer.DialContext(ctx, "tcp", broker)
+		if err == nil {
+			return conn.Close()
+		}
+		errs = append(errs, err)
+	}
+	return fmt.Errorf("%w: %w", ErrNotConnected, errors.Join(errs...))
+}
+
+func (q *KafkaQueue) Dequeue() (*domain.Event, error) {
+	event, ok := <-q.events
+	if !ok {
+		return nil, ErrNotConnected
+	}
+	return event, nil
+}
+
+func (q *KafkaQueue) GetChannel() <-chan *domain.Event {
+	return q.events
+}
+
+// Ack lets the partition commit the event's offset and move on.
+func (q *KafkaQueue) Ack(event *domain.Event) error {
+	return q.settle(event, true)
+}
+
+// Nack makes the partition redeliver the event instead of committing it.
+func (q *KafkaQueue) Nack(event *domain.Event) error {
+	return q.settle(event, false)
+}
+
+func (q *KafkaQueue) settle(event *domain.Event, ok bool) error {
+	q.mu.Lock()
+	result, found := q.pending[event]
+	delete(q.pending, event)
+	q.mu.Unlock()
+
+	if !found {
+		return ErrUnknownDelivery
+	}
+	result <- ok
+	return nil
+}
+
+// fetch reads the topic and hands each message to its partition's lane. A
+// partition waiting on a slow or failing event buffers its messages, so the
+// other partitions keep being consumed.
+func (q *KafkaQueue) fetch() {
+	defer q.wg.Done()
+
+	for {
+		msg, err := q.reader.FetchMessage(q.ctx)
+		if err != nil {
+			if q.ctx.Err() != nil {
+				return
+			}
+			q.logger.Error("Failed to fetch message", zap.Error(err))
+			if !q.sleep(q.config.RetryDelay) {
+				return
+			}
+			continue
+		}
+
+		if !q.partition(msg.Partition).push(q.ctx, msg) {
+			return
+		}
+	}
+}
+
+func (q *KafkaQueue) partition(id int) *lane {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	l, ok := q.partitions[id]
+	if !ok {
+		l = &lane{ready: make(chan struct{}, 1), space: make(chan struct{}, 1)}
+		q.partitions[id] = l
+
+		q.wg.Add(1)
+		go q.drain(id, l)
+	}
+	return l
+}
+
+// drain delivers one partition's messages strictly in offset order.
+func (q *KafkaQueue) drain(partition int, l *lane) {
+	defer q.wg.Done()
+
+	for {
+		msg, ok := l.pop(q.ctx)
+		if !ok || !q.deliver(partition, msg) {
+			return
+		}
+	}
+}
+
+func (q *KafkaQueue) deliver(partition int, msg kafka.Message) bool {
+	event, err := decodeKafkaMessage(msg)
+	if err != nil {
+		// Undecodable messages can never be saved, so set them aside
+		// without retrying.
+		q.logger.Error("Failed to decode message, dead-lettering",
+			zap.Int("partition", partition),
+			zap.Int64("offset", msg.Offset),
+			zap.Error(err))
+		return q.deadLetterMessage(msg, 0) && q.commit(msg)
+	}
+
+	for attempt := 1; ; attempt++ {
+		result := make(chan bool, 1)
+		q.mu.Lock()
+		q.pending[event] = result
+		q.mu.Unlock()
+
+		select {
+		case q.events <- event:
+		case <-q.ctx.Done():
+			return false
+		}
+
+		var ok bool
+		select {
+		case ok = <-result:
+		case <-q.ctx.Done():
+			return false
+		}
+
+		if ok {
+			return q.commit(msg)
+		}
+
+		if attempt > q.config.MaxRetries {
+			break
+		}
+		q.logger.Warn("Event processing failed, redelivering",
+			zap.String("product_id", event.ProductID),
+			zap.Int("partition", partition),
+			zap.Int64("offset", msg.Offset),
+			zap.Int("attempt", attempt))
+		if !q.sleep(q.config.RetryDelay) {
+			return false
+		}
+	}
+
+	q.logger.Warn("Event exhausted retries, dead-lettering",
+		zap.String("product_id", event.ProductID),
+		zap.Int("partition", partition),
+		zap.Int64("offset", msg.Offset),
+		zap.Int("retries", q.config.MaxRetries))
+	return q.deadLetterMessage(msg, q.config.MaxRetries) && q.commit(msg)
+}
+
+// deadLetterMessage copies msg to the dead-letter topic, retrying until the
+// write succeeds so the offset is never committed for a lost event.
+func (q *KafkaQueue) deadLetterMessage(msg kafka.Message, retries int) bool {
+	dead := kafka.Message{
+		Key:   msg.Key,
+		Value: msg.Value,
+		Time:  msg.Time,
+		Headers: append(append([]kafka.Header(nil), msg.Headers...),
+			kafka.Header{Key: retryCountHeader, Value: []byte(strconv.Itoa(retries))}),
+	}
+
+	for {
+		err := q.deadLetter.WriteMessages(q.ctx, dead)
+		if err == nil {
+			return true
+		}
+		if q.ctx.Err() != nil {
+			return false
+		}
+		q.logger.Error("Failed to dead-letter event",
+			zap.Int("partition", msg.Partition),
+			zap.Int64("offset", msg.Offset),
+			zap.Error(err))
+		if !q.sleep(q.config.RetryDelay) {
+			return false
+		}
+	}
+}
+
+func (q *KafkaQueue) commit(msg kafka.Message) bool {
+	if err := q.reader.CommitMessages(q.ctx, msg); err != nil {
+		if q.ctx.Err() != nil {
+			return false
+		}
+		// The offset will be committed with the next message on this partition.
+		q.logger.Error("Failed to commit offset",
+			zap.Int("partition", msg.Partition),
+			zap.Int64("offset", msg.Offset),
+			zap.Error(err))
+	}
+	return true
+}
+
+func (q *KafkaQueue) sleep(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-q.ctx.Done():
+		return false
+	}
+}
+
+// lane buffers one partition's fetched messages until drain delivers them.
+type lane struct {
+	mu       sync.Mutex
+	messages []kafka.Message
+	ready    chan struct{} // signalled after a push
+	space    chan struct{} // signalled after a pop
+}
+
+// push appends msg, waiting only while this partition's backlog is full. It
+// returns false once ctx is done.
+func (l *lane) push(ctx context.Context, msg kafka.Message) bool {
+	for {
+		l.mu.Lock()
+		if len(l.messages) < maxLaneBacklog {
+			l.messages = append(l.messages, msg)
+			l.mu.Unlock()
+			signal(l.ready)
+			return true
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-l.space:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// pop takes the oldest message, waiting for one if the lane is empty. It
+// returns false once ctx is done.
+func (l *lane) pop(ctx context.Context) (kafka.Message, bool) {
+	for {
+		l.mu.Lock()
+		if len(l.messages) > 0 {
+			msg := l.messages[0]
+			l.messages[0] = kafka.Message{}
+			l.messages = l.messages[1:]
+			l.mu.Unlock()
+			signal(l.space)
+			return msg, true
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-l.ready:
+		case <-ctx.Done():
+			return kafka.Message{}, false
+		}
+	}
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+func decodeKafkaMessage(msg kafka.Message) (*domain.Event, error) {
+	var event domain.Event
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return nil, err
+	}
+
+	if event.ProductID == "" {
+		event.ProductID = string(msg.Key)
+	}
+	if event.ProductID == "" {
+		return nil, errors.New("message has no product_id")
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = msg.Time
+	}
+	return &event, nil
+}
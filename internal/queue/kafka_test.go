@@ -0,0 +1,287 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeKafka is an in-process stand-in for a single-topic Kafka broker with one
+// consumer group. Messages are partitioned by key hash and fetched in the order
+// they were produced.
+type fakeKafka struct {
+	mu         sync.Mutex
+	partitions int
+	offsets    map[int]int64
+	log        chan kafka.Message
+	committed  map[int]int64
+}
+
+func newFakeKafka(partitions int) *fakeKafka {
+	return &fakeKafka{
+		partitions: partitions,
+		offsets:    make(map[int]int64),
+		log:        make(chan kafka.Message, 1000),
+		committed:  make(map[int]int64),
+	}
+}
+
+func (k *fakeKafka) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for _, msg := range msgs {
+		h := fnv.New32a()
+		h.Write(msg.Key)
+		msg.Partition = int(h.Sum32()) % k.partitions
+		msg.Offset = k.offsets[msg.Partition]
+		k.offsets[msg.Partition]++
+		k.log <- msg
+	}
+	return nil
+}
+
+func (k *fakeKafka) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case msg := <-k.log:
+		return msg, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (k *fakeKafka) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for _, msg := range msgs {
+		k.committed[msg.Partition] = msg.Offset + 1
+	}
+	return nil
+}
+
+func (k *fakeKafka) committedTotal() int64 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var total int64
+	for _, offset := range k.committed {
+		total += offset
+	}
+	return total
+}
+
+func (k *fakeKafka) Close() error { return nil }
+
+func newTestKafkaQueue(t *testing.T, broker *fakeKafka) *KafkaQueue {
+	t.Helper()
+	return newTestKafkaQueueWithDeadLetter(t, broker, newFakeKafka(1))
+}
+
+func newTestKafkaQueueWithDeadLetter(t *testing.T, broker, deadLetter *fakeKafka) *KafkaQueue {
+	t.Helper()
+
+	logger, _ := zap.NewDevelopment()
+	q := newKafkaQueue(KafkaConfig{
+		Topic:      "product-events",
+		GroupID:    "vfc",
+		RetryDelay: 10 * time.Millisecond,
+		MaxRetries: 2,
+	}, logger, broker, broker, deadLetter)
+	require.NoError(t, q.Connect())
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+func TestKafkaQueue_CommitsOnlyAfterAck(t *testing.T) {
+	broker := newFakeKafka(4)
+	q := newTestKafkaQueue(t, broker)
+
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("abc123", 49.99, 100)))
+
+	event := receive(t, q)
+	assert.Equal(t, "abc123", event.ProductID)
+	assert.Equal(t, int64(0), broker.committedTotal())
+
+	require.NoError(t, q.Ack(event))
+	assert.Eventually(t, func() bool {
+		return broker.committedTotal() == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestKafkaQueue_EnqueueHonorsContext(t *testing.T) {
+	broker := newFakeKafka(1)
+	q := newTestKafkaQueue(t, broker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, q.Enqueue(ctx, domain.NewEvent("abc123", 1, 1)), context.Canceled)
+
+	require.NoError(t, q.Close())
+	assert.ErrorIs(t, q.Enqueue(context.Background(), domain.NewEvent("abc123", 1, 1)), ErrNotConnected)
+}
+
+func TestKafkaQueue_NackRedeliversWithoutCommitting(t *testing.T) {
+	broker := newFakeKafka(1)
+	q := newTestKafkaQueue(t, broker)
+
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("abc123", 1, 1)))
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("abc123", 2, 2)))
+
+	first := receive(t, q)
+	require.NoError(t, q.Nack(first))
+	assert.Equal(t, int64(0), broker.committedTotal())
+
+	// The failed event comes back before anything later on its partition.
+	retried := receive(t, q)
+	assert.Equal(t, 1.0, retried.Price)
+	require.NoError(t, q.Ack(retried))
+
+	second := receive(t, q)
+	assert.Equal(t, 2.0, second.Price)
+	require.NoError(t, q.Ack(second))
+
+	assert.Eventually(t, func() bool {
+		return broker.committedTotal() == 2
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestKafkaQueue_PreservesPerKeyOrderAcrossWorkers(t *testing.T) {
+	broker := newFakeKafka(3)
+	q := newTestKafkaQueue(t, broker)
+
+	products := []string{"a", "b", "c", "d", "e"}
+	for i := 0; i < 20; i++ {
+		for _, id := range products {
+			require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent(id, float64(i), i)))
+		}
+	}
+
+	var mu sync.Mutex
+	seen := make(map[string][]int)
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case event := <-q.GetChannel():
+					mu.Lock()
+					seen[event.ProductID] = append(seen[event.ProductID], event.Stock)
+					mu.Unlock()
+					q.Ack(event)
+				case <-time.After(200 * time.Millisecond):
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, id := range products {
+		require.Len(t, seen[id], 20, id)
+		for i, stock := range seen[id] {
+			assert.Equal(t, i, stock, "product %s out of order", id)
+		}
+	}
+	assert.Equal(t, int64(100), broker.committedTotal())
+}
+
+func TestKafkaQueue_DeadLettersUndecodableMessages(t *testing.T) {
+	broker := newFakeKafka(1)
+	deadLetter := newFakeKafka(1)
+	q := newTestKafkaQueueWithDeadLetter(t, broker, deadLetter)
+
+	require.NoError(t, broker.WriteMessages(context.Background(), kafka.Message{Key: []byte("bad"), Value: []byte("not json")}))
+	body, _ := json.Marshal(domain.NewEvent("abc123", 1, 1))
+	require.NoError(t, broker.WriteMessages(context.Background(), kafka.Message{Key: []byte("abc123"), Value: body}))
+
+	event := receive(t, q)
+	assert.Equal(t, "abc123", event.ProductID)
+	require.NoError(t, q.Ack(event))
+
+	// The raw message is kept, without being retried.
+	select {
+	case dead := <-deadLetter.log:
+		assert.Equal(t, "bad", string(dead.Key))
+		assert.Equal(t, "not json", string(dead.Value))
+		require.Len(t, dead.Headers, 1)
+		assert.Equal(t, "0", string(dead.Headers[0].Value))
+	case <-time.After(time.Second):
+		t.Fatal("undecodable message was not dead-lettered")
+	}
+	assert.Eventually(t, func() bool {
+		return broker.committedTotal() == 2
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestKafkaQueue_DeadLettersAfterMaxRetries(t *testing.T) {
+	broker := newFakeKafka(1)
+	deadLetter := newFakeKafka(1)
+	q := newTestKafkaQueueWithDeadLetter(t, broker, deadLetter)
+
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("poison", 1, 1)))
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("abc123", 2, 2)))
+
+	// The first delivery and two retries fail.
+	for i := 0; i < 3; i++ {
+		event := receive(t, q)
+		assert.Equal(t, "poison", event.ProductID)
+		require.NoError(t, q.Nack(event))
+	}
+
+	// The partition moves on once the event is dead-lettered.
+	next := receive(t, q)
+	assert.Equal(t, "abc123", next.ProductID)
+	require.NoError(t, q.Ack(next))
+
+	select {
+	case dead := <-deadLetter.log:
+		assert.Equal(t, "poison", string(dead.Key))
+		require.Len(t, dead.Headers, 1)
+		assert.Equal(t, "2", string(dead.Headers[0].Value))
+	case <-time.After(time.Second):
+		t.Fatal("event was not dead-lettered")
+	}
+	assert.Eventually(t, func() bool {
+		return broker.committedTotal() == 2
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestKafkaQueue_StalledPartitionDoesNotBlockOthers(t *testing.T) {
+	broker := newFakeKafka(2)
+	q := newTestKafkaQueue(t, broker)
+
+	// "a" and "b" hash to different partitions.
+	stalled := receiveAfter(t, q, domain.NewEvent("a", 0, 0))
+	for i := 1; i <= 200; i++ {
+		require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("a", float64(i), i)))
+	}
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("b", 1, 1)))
+
+	// The backlog behind the unsettled event must not hold up "b".
+	other := receive(t, q)
+	assert.Equal(t, "b", other.ProductID)
+	require.NoError(t, q.Ack(other))
+	require.NoError(t, q.Ack(stalled))
+}
+
+func receiveAfter(t *testing.T, q *KafkaQueue, event *domain.Event) *domain.Event {
+	t.Helper()
+	require.NoError(t, q.Enqueue(context.Background(), event))
+	return receive(t, q)
+}
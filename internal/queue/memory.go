@@ -1,19 +1,33 @@
 package queue
 
 import (
+	"context"
+	"errors"
+	"sync"
+
 	"github.com/raufhm/vfc/internal/domain"
 	"go.uber.org/zap"
 )
 
+// ErrQueueClosed is returned by Enqueue once the queue is closed.
+var ErrQueueClosed = errors.New("queue closed")
+
 type InMemoryQueue struct {
 	queue  chan *domain.Event
 	logger *zap.Logger
+
+	// mu is held for reading by senders, so Close can wait for them to
+	// give up before closing queue.
+	mu        sync.RWMutex
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
 func NewInMemoryQueue(bufferSize int, logger *zap.Logger) *InMemoryQueue {
 	return &InMemoryQueue{
 		queue:  make(chan *domain.Event, bufferSize),
 		logger: logger,
+		closed: make(chan struct{}),
 	}
 }
 
@@ -22,15 +36,39 @@ func (q *InMemoryQueue) Connect() error {
 	return nil
 }
 
+// Close stops accepting events. Enqueue calls waiting for room return
+// ErrQueueClosed, and consumers see the channel close once it is empty.
 func (q *InMemoryQueue) Close() error {
-	close(q.queue)
-	q.logger.Info("InMemoryQueue closed")
+	q.closeOnce.Do(func() {
+		close(q.closed)
+		q.mu.Lock()
+		close(q.queue)
+		q.mu.Unlock()
+		q.logger.Info("InMemoryQueue closed")
+	})
 	return nil
 }
 
-func (q *InMemoryQueue) Enqueue(event *domain.Event) error {
-	q.queue <- event
-	return nil
+// Enqueue blocks while the buffer is full, until there is room, the queue is
+// closed or ctx is done.
+func (q *InMemoryQueue) Enqueue(ctx context.Context, event *domain.Event) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	select {
+	case <-q.closed:
+		return ErrQueueClosed
+	default:
+	}
+
+	select {
+	case q.queue <- event:
+		return nil
+	case <-q.closed:
+		return ErrQueueClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (q *InMemoryQueue) Dequeue() (*domain.Event, error) {
@@ -41,3 +79,30 @@ func (q *InMemoryQueue) Dequeue() (*domain.Event, error) {
 func (q *InMemoryQueue) GetChannel() <-chan *domain.Event {
 	return q.queue
 }
+
+// Flush removes and returns the events still buffered. Call it only once
+// nothing else consumes the queue, such as after the worker pool has stopped.
+func (q *InMemoryQueue) Flush() []*domain.Event {
+	var events []*domain.Event
+	for {
+		select {
+		case event, ok := <-q.queue:
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+// Len returns the number of events waiting in the buffer.
+func (q *InMemoryQueue) Len() int {
+	return len(q.queue)
+}
+
+// Cap returns the size of the buffer.
+func (q *InMemoryQueue) Cap() int {
+	return cap(q.queue)
+}
@@ -0,0 +1,57 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestInMemoryQueue_EnqueueAfterClose(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	q := NewInMemoryQueue(10, logger)
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("a", 1, 1)))
+	require.NoError(t, q.Close())
+	require.NoError(t, q.Close())
+
+	assert.ErrorIs(t, q.Enqueue(context.Background(), domain.NewEvent("b", 1, 1)), ErrQueueClosed)
+
+	// Events queued before Close are still delivered.
+	event, ok := <-q.GetChannel()
+	require.True(t, ok)
+	assert.Equal(t, "a", event.ProductID)
+	_, ok = <-q.GetChannel()
+	assert.False(t, ok)
+}
+
+func TestInMemoryQueue_CloseReleasesBlockedEnqueue(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	q := NewInMemoryQueue(1, logger)
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("a", 1, 1)))
+
+	errs := make(chan error)
+	go func() { errs <- q.Enqueue(context.Background(), domain.NewEvent("b", 1, 1)) }()
+
+	require.NoError(t, q.Close())
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, ErrQueueClosed)
+	case <-time.After(time.Second):
+		t.Fatal("Enqueue stayed blocked after Close")
+	}
+}
+
+func TestInMemoryQueue_EnqueueHonorsContext(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	q := NewInMemoryQueue(1, logger)
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("a", 1, 1)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Enqueue(ctx, domain.NewEvent("b", 1, 1)), context.DeadlineExceeded)
+	assert.Equal(t, 1, q.Len())
+}
@@ -1,11 +1,34 @@
 package queue
 
-import "github.com/raufhm/vfc/internal/domain"
+import (
+	"context"
+	"errors"
+
+	"github.com/raufhm/vfc/internal/domain"
+)
 
 type QueueProvider interface {
 	Connect() error
 	Close() error
-	Enqueue(event *domain.Event) error
+	// Enqueue hands event to the queue. It gives up with ctx.Err() once ctx
+	// is done, but an event already accepted is not withdrawn.
+	Enqueue(ctx context.Context, event *domain.Event) error
 	Dequeue() (*domain.Event, error)
 	GetChannel() <-chan *domain.Event
 }
+
+// Acknowledger is implemented by queues that keep a delivered event
+// outstanding until the consumer reports whether it was processed.
+type Acknowledger interface {
+	Ack(event *domain.Event) error
+	Nack(event *domain.Event) error
+}
+
+// IsUnavailable reports whether err means the queue cannot take events right
+// now, so the caller should retry later rather than treat it as a failure of
+// the event itself.
+func IsUnavailable(err error) bool {
+	return errors.Is(err, ErrNotConnected) ||
+		errors.Is(err, ErrPublishNotAcked) ||
+		errors.Is(err, ErrQueueClosed)
+}
@@ -0,0 +1,478 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/raufhm/vfc/internal/domain"
+	"go.uber.org/zap"
+)
+
+const retryCountHeader = "x-retry-count"
+
+var (
+	ErrNotConnected    = errors.New("queue not connected")
+	ErrPublishNotAcked = errors.New("publish not confirmed by broker")
+	ErrUnknownDelivery = errors.New("event has no pending delivery")
+)
+
+// RabbitMQConfig describes the broker connection and topology used by
+// RabbitMQQueue.
+type RabbitMQConfig struct {
+	URL            string
+	Exchange       string
+	Queue          string
+	Prefetch       int
+	MaxRetries     int
+	PublishTimeout time.Duration
+	ReconnectDelay time.Duration
+}
+
+func (c RabbitMQConfig) deadLetterExchange() string {
+	return c.Queue + ".dlx"
+}
+
+func (c RabbitMQConfig) deadLetterQueue() string {
+	return c.Queue + ".dead"
+}
+
+// amqpConnection and amqpChannel cover the parts of the client library the
+// queue relies on so tests can substitute an in-process broker.
+type amqpConnection interface {
+	Channel() (amqpChannel, error)
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	Close() error
+}
+
+type amqpChannel interface {
+	Confirm(noWait bool) error
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	PublishConfirmed(ctx context.Context, exchange, key string, msg amqp.Publishing) error
+	Close() error
+}
+
+type amqpDialer func(url string) (amqpConnection, error)
+
+// RabbitMQQueue is a QueueProvider backed by a RabbitMQ queue. Publishes wait
+// for broker confirms, deliveries stay unacknowledged until the worker calls
+// Ack or Nack, and events that exhaust their retries are dead-lettered.
+type RabbitMQQueue struct {
+	config RabbitMQConfig
+	logger *zap.Logger
+	dial   amqpDialer
+
+	mu        sync.RWMutex
+	conn      amqpConnection
+	publishCh amqpChannel
+	consumeCh amqpChannel
+
+	pendingMu sync.Mutex
+	pending   map[*domain.Event]amqp.Delivery
+
+	events    chan *domain.Event
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+func NewRabbitMQQueue(config RabbitMQConfig, logger *zap.Logger) *RabbitMQQueue {
+	return newRabbitMQQueue(config, logger, dialAMQP)
+}
+
+func newRabbitMQQueue(config RabbitMQConfig, logger *zap.Logger, dial amqpDialer) *RabbitMQQueue {
+	if config.Prefetch <= 0 {
+		config.Prefetch = 1
+	}
+	if config.PublishTimeout <= 0 {
+		config.PublishTimeout = 5 * time.Second
+	}
+	if config.ReconnectDelay <= 0 {
+		config.ReconnectDelay = time.Second
+	}
+
+	return &RabbitMQQueue{
+		config:  config,
+		logger:  logger,
+		dial:    dial,
+		pending: make(map[*domain.Event]amqp.Delivery),
+		events:  make(chan *domain.Event),
+		done:    make(chan struct{}),
+	}
+}
+
+func (q *RabbitMQQueue) Connect() error {
+	if err := q.connect(); err != nil {
+		return err
+	}
+
+	q.logger.Info("RabbitMQQueue connected",
+		zap.String("exchange", q.config.Exchange),
+		zap.String("queue", q.config.Queue))
+	return nil
+}
+
+func (q *RabbitMQQueue) Close() error {
+	var err error
+	q.closeOnce.Do(func() {
+		close(q.done)
+
+		q.mu.Lock()
+		if q.conn != nil {
+			err = q.conn.Close()
+			q.conn = nil
+		}
+		q.mu.Unlock()
+
+		q.wg.Wait()
+		close(q.events)
+		q.logger.Info("RabbitMQQueue closed")
+	})
+	return err
+}
+
+// Enqueue publishes the event and waits for the broker to confirm it, for at
+// most the publish timeout and no longer than ctx allows.
+func (q *RabbitMQQueue) Enqueue(ctx context.Context, event *domain.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	return q.publish(ctx, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    event.Timestamp,
+		Body:         body,
+	})
+}
+
+func (q *RabbitMQQueue) Dequeue() (*domain.Event, error) {
+	event, ok := <-q.events
+	if !ok {
+		return nil, ErrNotConnected
+	}
+	return event, nil
+}
+
+func (q *RabbitMQQueue) GetChannel() <-chan *domain.Event {
+	return q.events
+}
+
+// Ack confirms the delivery behind event so the broker can discard it.
+func (q *RabbitMQQueue) Ack(event *domain.Event) error {
+	delivery, err := q.takeDelivery(event)
+	if err != nil {
+		return err
+	}
+	return delivery.Ack(false)
+}
+
+// Nack republishes the event with an incremented retry count, or rejects it
+// to the dead-letter exchange once MaxRetries has been reached.
+func (q *RabbitMQQueue) Nack(event *domain.Event) error {
+	delivery, err := q.takeDelivery(event)
+	if err != nil {
+		return err
+	}
+
+	retries := retryCount(delivery.Headers)
+	if retries >= q.config.MaxRetries {
+		q.logger.Warn("Event exhausted retries, dead-lettering",
+			zap.String("product_id", event.ProductID),
+			zap.Int("retries", retries))
+		return delivery.Nack(false, false)
+	}
+
+	headers := amqp.Table{}
+	for k, v := range delivery.Headers {
+		headers[k] = v
+	}
+	headers[retryCountHeader] = int32(retries + 1)
+
+	if err := q.publish(context.Background(), amqp.Publishing{
+		Headers:      headers,
+		ContentType:  delivery.ContentType,
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    delivery.Timestamp,
+		Body:         delivery.Body,
+	}); err != nil {
+		// Leave the original with the broker rather than losing it.
+		q.logger.Error("Failed to republish event for retry", zap.Error(err))
+		return delivery.Nack(false, true)
+	}
+
+	return delivery.Ack(false)
+}
+
+func (q *RabbitMQQueue) takeDelivery(event *domain.Event) (amqp.Delivery, error) {
+	q.pendingMu.Lock()
+	defer q.pendingMu.Unlock()
+
+	delivery, ok := q.pending[event]
+	if !ok {
+		return amqp.Delivery{}, ErrUnknownDelivery
+	}
+	delete(q.pending, event)
+	return delivery, nil
+}
+
+// Ping reports ErrNotConnected while the broker connection is down.
+func (q *RabbitMQQueue) Ping(ctx context.Context) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.conn == nil || q.publishCh == nil {
+		return ErrNotConnected
+	}
+	return nil
+}
+
+func (q *RabbitMQQueue) publish(ctx context.Context, msg amqp.Publishing) error {
+	q.mu.RLock()
+	ch := q.publishCh
+	q.mu.RUnlock()
+
+	if ch == nil {
+		return ErrNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, q.config.PublishTimeout)
+	defer cancel()
+
+	return ch.PublishConfirmed(ctx, q.config.Exchange, q.config.Queue, msg)
+}
+
+func (q *RabbitMQQueue) connect() error {
+	conn, err := q.dial(q.config.URL)
+	if err != nil {
+		return fmt.Errorf("failed to dial broker: %w", err)
+	}
+
+	publishCh, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to open publish channel: %w", err)
+	}
+	if err := publishCh.Confirm(false); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	if err := q.declareTopology(publishCh); err != nil {
+		conn.Close()
+		return err
+	}
+
+	consumeCh, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to open consume channel: %w", err)
+	}
+	if err := consumeCh.Qos(q.config.Prefetch, 0, false); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to set prefetch: %w", err)
+	}
+	deliveries, err := consumeCh.Consume(q.config.Queue, "", false, false, false, false, nil)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start consuming: %w", err)
+	}
+
+	q.mu.Lock()
+	select {
+	case <-q.done:
+		q.mu.Unlock()
+		conn.Close()
+		return ErrNotConnected
+	default:
+	}
+	q.conn = conn
+	q.publishCh = publishCh
+	q.consumeCh = consumeCh
+	q.mu.Unlock()
+
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+	q.wg.Add(2)
+	go q.forward(deliveries)
+	go q.watch(closed)
+
+	return nil
+}
+
+func (q *RabbitMQQueue) declareTopology(ch amqpChannel) error {
+	dlx := q.config.deadLetterExchange()
+	dlq := q.config.deadLetterQueue()
+
+	if err := ch.ExchangeDeclare(dlx, amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead-letter exchange: %w", err)
+	}
+	if _, err := ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead-letter queue: %w", err)
+	}
+	if err := ch.QueueBind(dlq, q.config.Queue, dlx, false, nil); err != nil {
+		return fmt.Errorf("failed to bind dead-letter queue: %w", err)
+	}
+
+	if q.config.Exchange != "" {
+		if err := ch.ExchangeDeclare(q.config.Exchange, amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("failed to declare exchange: %w", err)
+		}
+	}
+
+	args := amqp.Table{
+		"x-dead-letter-exchange":    dlx,
+		"x-dead-letter-routing-key": q.config.Queue,
+	}
+	if _, err := ch.QueueDeclare(q.config.Queue, true, false, false, false, args); err != nil {
+		return fmt.Errorf("failed to declare queue: %w", err)
+	}
+
+	if q.config.Exchange != "" {
+		if err := ch.QueueBind(q.config.Queue, q.config.Queue, q.config.Exchange, false, nil); err != nil {
+			return fmt.Errorf("failed to bind queue: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (q *RabbitMQQueue) forward(deliveries <-chan amqp.Delivery) {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-q.done:
+			return
+		case delivery, ok := <-deliveries:
+			if !ok {
+				return
+			}
+
+			var event domain.Event
+			if err := json.Unmarshal(delivery.Body, &event); err != nil {
+				q.logger.Error("Failed to decode delivery, dead-lettering", zap.Error(err))
+				delivery.Nack(false, false)
+				continue
+			}
+
+			q.pendingMu.Lock()
+			q.pending[&event] = delivery
+			q.pendingMu.Unlock()
+
+			select {
+			case q.events <- &event:
+			case <-q.done:
+				return
+			}
+		}
+	}
+}
+
+// watch reconnects with a growing delay whenever the broker drops the
+// connection, until Close is called.
+func (q *RabbitMQQueue) watch(closed chan *amqp.Error) {
+	defer q.wg.Done()
+
+	select {
+	case <-q.done:
+		return
+	case amqpErr, ok := <-closed:
+		if !ok {
+			return
+		}
+		q.logger.Warn("RabbitMQ connection lost", zap.Error(amqpErr))
+	}
+
+	q.mu.Lock()
+	q.conn = nil
+	q.publishCh = nil
+	q.consumeCh = nil
+	q.mu.Unlock()
+
+	// Delivery tags are scoped to the lost channel; the broker redelivers them.
+	q.pendingMu.Lock()
+	q.pending = make(map[*domain.Event]amqp.Delivery)
+	q.pendingMu.Unlock()
+
+	delay := q.config.ReconnectDelay
+	for {
+		select {
+		case <-q.done:
+			return
+		case <-time.After(delay):
+		}
+
+		if err := q.connect(); err != nil {
+			q.logger.Error("RabbitMQ reconnect failed", zap.Duration("retry_in", delay), zap.Error(err))
+			if delay < 30*time.Second {
+				delay *= 2
+			}
+			continue
+		}
+
+		q.logger.Info("RabbitMQ reconnected")
+		return
+	}
+}
+
+func retryCount(headers amqp.Table) int {
+	switch v := headers[retryCountHeader].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
+
+type amqpConnAdapter struct {
+	*amqp.Connection
+}
+
+func dialAMQP(url string) (amqpConnection, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	return amqpConnAdapter{conn}, nil
+}
+
+func (c amqpConnAdapter) Channel() (amqpChannel, error) {
+	ch, err := c.Connection.Channel()
+	if err != nil {
+		return nil, err
+	}
+	return amqpChannelAdapter{ch}, nil
+}
+
+type amqpChannelAdapter struct {
+	*amqp.Channel
+}
+
+func (c amqpChannelAdapter) PublishConfirmed(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	confirmation, err := c.Channel.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, msg)
+	if err != nil {
+		return err
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return ErrPublishNotAcked
+	}
+	return nil
+}
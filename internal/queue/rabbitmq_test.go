@@ -0,0 +1,381 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeBroker is an in-process stand-in for RabbitMQ. Publishes are routed to
+// the queue named by the routing key, and rejected deliveries move to the
+// queue's dead-letter target.
+type fakeBroker struct {
+	mu           sync.Mutex
+	queues       map[string]chan amqp.Delivery
+	deadLetter   map[string]string
+	nextTag      uint64
+	acked        []uint64
+	deadLettered [][]byte
+	rejectPubs   bool
+	dials        int
+	conns        []*fakeConn
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{
+		queues:     make(map[string]chan amqp.Delivery),
+		deadLetter: make(map[string]string),
+	}
+}
+
+func (b *fakeBroker) dial(string) (amqpConnection, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.dials++
+	conn := &fakeConn{broker: b}
+	b.conns = append(b.conns, conn)
+	return conn, nil
+}
+
+func (b *fakeBroker) queue(name string) chan amqp.Delivery {
+	if _, ok := b.queues[name]; !ok {
+		b.queues[name] = make(chan amqp.Delivery, 100)
+	}
+	return b.queues[name]
+}
+
+func (b *fakeBroker) dropConnection() {
+	b.mu.Lock()
+	conn := b.conns[len(b.conns)-1]
+	b.mu.Unlock()
+	conn.drop()
+}
+
+func (b *fakeBroker) Ack(tag uint64, multiple bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.acked = append(b.acked, tag)
+	return nil
+}
+
+// deliveryAcker records nacks with the delivery body so dead-lettering can be
+// asserted on.
+type deliveryAcker struct {
+	broker *fakeBroker
+	queue  string
+	body   []byte
+}
+
+func (a deliveryAcker) Ack(tag uint64, multiple bool) error {
+	return a.broker.Ack(tag, multiple)
+}
+
+func (a deliveryAcker) Nack(tag uint64, multiple bool, requeue bool) error {
+	b := a.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if requeue {
+		return nil
+	}
+	if dlq, ok := b.deadLetter[a.queue]; ok {
+		b.deadLettered = append(b.deadLettered, a.body)
+		b.queue(dlq) <- amqp.Delivery{Body: a.body}
+	}
+	return nil
+}
+
+func (a deliveryAcker) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+type fakeConn struct {
+	broker   *fakeBroker
+	mu       sync.Mutex
+	notify   []chan *amqp.Error
+	closed   bool
+	channels []*fakeChannel
+}
+
+func (c *fakeConn) Channel() (amqpChannel, error) {
+	ch := &fakeChannel{conn: c, stop: make(chan struct{})}
+	c.mu.Lock()
+	c.channels = append(c.channels, ch)
+	c.mu.Unlock()
+	return ch, nil
+}
+
+func (c *fakeConn) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notify = append(c.notify, receiver)
+	return receiver
+}
+
+func (c *fakeConn) Close() error {
+	c.shutdown(nil)
+	return nil
+}
+
+func (c *fakeConn) drop() {
+	c.shutdown(&amqp.Error{Code: amqp.ConnectionForced, Reason: "broker restarted"})
+}
+
+func (c *fakeConn) shutdown(reason *amqp.Error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+	c.closed = true
+	for _, ch := range c.channels {
+		close(ch.stop)
+	}
+	for _, n := range c.notify {
+		if reason != nil {
+			n <- reason
+		}
+		close(n)
+	}
+}
+
+type fakeChannel struct {
+	conn *fakeConn
+	stop chan struct{}
+}
+
+func (c *fakeChannel) Confirm(bool) error { return nil }
+
+func (c *fakeChannel) Qos(int, int, bool) error { return nil }
+
+func (c *fakeChannel) ExchangeDeclare(string, string, bool, bool, bool, bool, amqp.Table) error {
+	return nil
+}
+
+func (c *fakeChannel) QueueDeclare(name string, _, _, _, _ bool, args amqp.Table) (amqp.Queue, error) {
+	b := c.conn.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.queue(name)
+	if key, ok := args["x-dead-letter-routing-key"].(string); ok {
+		b.deadLetter[name] = key + ".dead"
+	}
+	return amqp.Queue{Name: name}, nil
+}
+
+func (c *fakeChannel) QueueBind(string, string, string, bool, amqp.Table) error { return nil }
+
+func (c *fakeChannel) Consume(queue, _ string, _, _, _, _ bool, _ amqp.Table) (<-chan amqp.Delivery, error) {
+	b := c.conn.broker
+	b.mu.Lock()
+	src := b.queue(queue)
+	b.mu.Unlock()
+
+	out := make(chan amqp.Delivery)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-c.stop:
+				return
+			case d := <-src:
+				select {
+				case out <- d:
+				case <-c.stop:
+					src <- d
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (c *fakeChannel) PublishConfirmed(ctx context.Context, _, key string, msg amqp.Publishing) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.conn.mu.Lock()
+	closed := c.conn.closed
+	c.conn.mu.Unlock()
+	if closed {
+		return amqp.ErrClosed
+	}
+
+	b := c.conn.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.rejectPubs {
+		return ErrPublishNotAcked
+	}
+
+	b.nextTag++
+	b.queue(key) <- amqp.Delivery{
+		Acknowledger: deliveryAcker{broker: b, queue: key, body: msg.Body},
+		DeliveryTag:  b.nextTag,
+		Headers:      msg.Headers,
+		ContentType:  msg.ContentType,
+		DeliveryMode: msg.DeliveryMode,
+		Body:         msg.Body,
+	}
+	return nil
+}
+
+func (c *fakeChannel) Close() error { return nil }
+
+func newTestRabbitMQQueue(t *testing.T, broker *fakeBroker, maxRetries int) *RabbitMQQueue {
+	t.Helper()
+
+	logger, _ := zap.NewDevelopment()
+	q := newRabbitMQQueue(RabbitMQConfig{
+		Queue:          "product-events",
+		MaxRetries:     maxRetries,
+		ReconnectDelay: 10 * time.Millisecond,
+	}, logger, broker.dial)
+	require.NoError(t, q.Connect())
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+func receive(t *testing.T, q QueueProvider) *domain.Event {
+	t.Helper()
+
+	select {
+	case event := <-q.GetChannel():
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event delivered")
+		return nil
+	}
+}
+
+func TestRabbitMQQueue_EnqueuePublishesPersistentJSON(t *testing.T) {
+	broker := newFakeBroker()
+	q := newTestRabbitMQQueue(t, broker, 3)
+
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("abc123", 49.99, 100)))
+
+	event := receive(t, q)
+	assert.Equal(t, "abc123", event.ProductID)
+	assert.Equal(t, 49.99, event.Price)
+	assert.Equal(t, 100, event.Stock)
+}
+
+func TestRabbitMQQueue_EnqueueFailsWithoutConfirm(t *testing.T) {
+	broker := newFakeBroker()
+	q := newTestRabbitMQQueue(t, broker, 3)
+	broker.rejectPubs = true
+
+	err := q.Enqueue(context.Background(), domain.NewEvent("abc123", 49.99, 100))
+	assert.ErrorIs(t, err, ErrPublishNotAcked)
+}
+
+func TestRabbitMQQueue_EnqueueHonorsContext(t *testing.T) {
+	broker := newFakeBroker()
+	q := newTestRabbitMQQueue(t, broker, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, q.Enqueue(ctx, domain.NewEvent("abc123", 49.99, 100)), context.Canceled)
+}
+
+func TestRabbitMQQueue_AckSettlesDelivery(t *testing.T) {
+	broker := newFakeBroker()
+	q := newTestRabbitMQQueue(t, broker, 3)
+
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("abc123", 49.99, 100)))
+	event := receive(t, q)
+
+	require.NoError(t, q.Ack(event))
+	assert.ErrorIs(t, q.Ack(event), ErrUnknownDelivery)
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	assert.Len(t, broker.acked, 1)
+}
+
+func TestRabbitMQQueue_NackRetriesThenDeadLetters(t *testing.T) {
+	broker := newFakeBroker()
+	q := newTestRabbitMQQueue(t, broker, 2)
+
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("abc123", 49.99, 100)))
+
+	// The first delivery plus two retries, then the event is dead-lettered.
+	for i := 0; i < 3; i++ {
+		event := receive(t, q)
+		require.NoError(t, q.Nack(event))
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	require.Len(t, broker.deadLettered, 1)
+
+	var dead domain.Event
+	require.NoError(t, json.Unmarshal(broker.deadLettered[0], &dead))
+	assert.Equal(t, "abc123", dead.ProductID)
+	assert.Len(t, broker.queues["product-events.dead"], 1)
+}
+
+func TestRabbitMQQueue_ReconnectsAfterConnectionLoss(t *testing.T) {
+	broker := newFakeBroker()
+	q := newTestRabbitMQQueue(t, broker, 3)
+
+	broker.dropConnection()
+
+	require.Eventually(t, func() bool {
+		return q.Enqueue(context.Background(), domain.NewEvent("abc123", 1, 1)) == nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	event := receive(t, q)
+	assert.Equal(t, "abc123", event.ProductID)
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	assert.GreaterOrEqual(t, broker.dials, 2)
+}
+
+func TestRabbitMQQueue_PingReportsConnection(t *testing.T) {
+	broker := newFakeBroker()
+	q := newTestRabbitMQQueue(t, broker, 3)
+	assert.NoError(t, q.Ping(context.Background()))
+
+	require.NoError(t, q.Close())
+	assert.ErrorIs(t, q.Ping(context.Background()), ErrNotConnected)
+}
+
+// TestRabbitMQQueue_Broker runs against a real broker when RABBITMQ_URL is set,
+// e.g. one started with `docker run -p 5672:5672 rabbitmq:3`.
+func TestRabbitMQQueue_Broker(t *testing.T) {
+	url := os.Getenv("RABBITMQ_URL")
+	if url == "" {
+		t.Skip("RABBITMQ_URL not set")
+	}
+
+	logger, _ := zap.NewDevelopment()
+	q := NewRabbitMQQueue(RabbitMQConfig{
+		URL:        url,
+		Queue:      "vfc-test-" + time.Now().Format("150405.000"),
+		MaxRetries: 1,
+	}, logger)
+	require.NoError(t, q.Connect())
+	defer q.Close()
+
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("abc123", 49.99, 100)))
+	event := receive(t, q)
+	assert.Equal(t, "abc123", event.ProductID)
+	require.NoError(t, q.Ack(event))
+}
@@ -0,0 +1,116 @@
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/raufhm/vfc/internal/domain"
+)
+
+// SaveEvents appends events to the file at path as JSON lines, so events
+// left in the in-memory queue at shutdown survive a restart.
+func SaveEvents(path string, events []*domain.Event) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	enc := json.NewEncoder(f)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write event: %w", err)
+		}
+	}
+	return f.Close()
+}
+
+// CorruptSuffix names the file, next to the spill file, that LoadEvents moves
+// undecodable lines to.
+const CorruptSuffix = ".corrupt"
+
+// LoadEvents reads the events saved by SaveEvents. A missing file holds no
+// events. A line that cannot be decoded, such as one cut short by a crash,
+// is appended to path+CorruptSuffix and skipped, so it cannot hold up the
+// events around it; LoadEvents returns how many lines it skipped.
+func LoadEvents(path string) ([]*domain.Event, int, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var events []*domain.Event
+	var corrupt [][]byte
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			var event domain.Event
+			if json.Unmarshal(trimmed, &event) == nil {
+				events = append(events, &event)
+			} else {
+				corrupt = append(corrupt, trimmed)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+
+	if len(corrupt) > 0 {
+		if err := quarantine(path+CorruptSuffix, corrupt); err != nil {
+			return nil, 0, err
+		}
+	}
+	return events, len(corrupt), nil
+}
+
+func quarantine(path string, lines [][]byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	for _, line := range lines {
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return f.Close()
+}
+
+// ReplaceEvents overwrites the file at path with events, or removes it when
+// there are none. The new contents are written to a temporary file first, so
+// a failure leaves the old file intact.
+func ReplaceEvents(path string, events []*domain.Event) error {
+	if len(events) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		return nil
+	}
+
+	tmp := path + ".tmp"
+	if err := os.Remove(tmp); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove %s: %w", tmp, err)
+	}
+	if err := SaveEvents(tmp, events); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package queue
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSaveEvents_RoundTripsFlushedEvents(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	q := NewInMemoryQueue(10, logger)
+	first := domain.NewEvent("abc", 1.5, 2)
+	first.RequestID = "req-1"
+	require.NoError(t, q.Enqueue(context.Background(), first))
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("def", 3, 4)))
+
+	events := q.Flush()
+	require.Len(t, events, 2)
+	assert.Equal(t, 0, q.Len())
+
+	path := filepath.Join(t.TempDir(), "unprocessed.jsonl")
+	require.NoError(t, SaveEvents(path, events[:1]))
+	require.NoError(t, SaveEvents(path, events[1:]))
+
+	loaded, corrupt, err := LoadEvents(path)
+	require.NoError(t, err)
+	assert.Zero(t, corrupt)
+	require.Len(t, loaded, 2)
+	assert.Equal(t, "abc", loaded[0].ProductID)
+	assert.Equal(t, "req-1", loaded[0].RequestID)
+	assert.True(t, first.Timestamp.Equal(loaded[0].Timestamp))
+	assert.Equal(t, "def", loaded[1].ProductID)
+}
+
+func TestLoadEvents_MissingFile(t *testing.T) {
+	events, _, err := LoadEvents(filepath.Join(t.TempDir(), "missing.jsonl"))
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestLoadEvents_SkipsCorruptLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "unprocessed.jsonl")
+	require.NoError(t, SaveEvents(path, []*domain.Event{domain.NewEvent("a", 1, 1)}))
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("{\"product_id\":\"trunc\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, SaveEvents(path, []*domain.Event{domain.NewEvent("b", 2, 2)}))
+
+	loaded, corrupt, err := LoadEvents(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, corrupt)
+	require.Len(t, loaded, 2)
+	assert.Equal(t, "a", loaded[0].ProductID)
+	assert.Equal(t, "b", loaded[1].ProductID)
+
+	quarantined, err := os.ReadFile(path + CorruptSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"product_id\":\"trunc\n", string(quarantined))
+}
+
+func TestReplaceEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "unprocessed.jsonl")
+	require.NoError(t, SaveEvents(path, []*domain.Event{
+		domain.NewEvent("a", 1, 1), domain.NewEvent("b", 2, 2), domain.NewEvent("c", 3, 3),
+	}))
+
+	require.NoError(t, ReplaceEvents(path, []*domain.Event{domain.NewEvent("c", 3, 3)}))
+	loaded, _, err := LoadEvents(path)
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+	assert.Equal(t, "c", loaded[0].ProductID)
+
+	require.NoError(t, ReplaceEvents(path, nil))
+	assert.NoFileExists(t, path)
+	require.NoError(t, ReplaceEvents(path, nil))
+}
@@ -0,0 +1,28 @@
+package ratelimit
+
+import "context"
+
+type contextKey struct{}
+
+type writeLimit struct {
+	limiter *Limiter
+	key     string
+}
+
+// WithWriteLimit returns ctx carrying the write limiter and the client's
+// key, so handlers that accept writes on routes limited as reads, such as
+// WebSocket event submissions and GraphQL mutations, can charge the write
+// bucket.
+func WithWriteLimit(ctx context.Context, limiter *Limiter, key string) context.Context {
+	return context.WithValue(ctx, contextKey{}, writeLimit{limiter: limiter, key: key})
+}
+
+// AllowWrite takes a token from the write bucket carried by ctx. Writes are
+// allowed when ctx carries none, as when rate limiting is disabled.
+func AllowWrite(ctx context.Context) Result {
+	w, ok := ctx.Value(contextKey{}).(writeLimit)
+	if !ok {
+		return Result{Allowed: true}
+	}
+	return w.limiter.Allow(w.key)
+}
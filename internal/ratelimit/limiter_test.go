@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLimiter(limit Limit) (*Limiter, *time.Time) {
+	now := time.Unix(1_700_000_000, 0)
+	l := NewLimiter(limit)
+	l.now = func() time.Time { return now }
+	l.lastSweep = now
+	return l, &now
+}
+
+func TestLimiter_AllowsBurstThenRefills(t *testing.T) {
+	l, now := newTestLimiter(Limit{Rate: 2, Burst: 3})
+
+	for i := 2; i >= 0; i-- {
+		r := l.Allow("client")
+		assert.True(t, r.Allowed)
+		assert.Equal(t, 3, r.Limit)
+		assert.Equal(t, i, r.Remaining)
+	}
+
+	r := l.Allow("client")
+	assert.False(t, r.Allowed)
+	assert.Equal(t, 0, r.Remaining)
+	assert.Equal(t, 500*time.Millisecond, r.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, r.Reset)
+
+	*now = now.Add(500 * time.Millisecond)
+	r = l.Allow("client")
+	assert.True(t, r.Allowed)
+	assert.Zero(t, r.RetryAfter)
+	assert.False(t, l.Allow("client").Allowed)
+}
+
+func TestLimiter_KeysAreIndependent(t *testing.T) {
+	l, _ := newTestLimiter(Limit{Rate: 1, Burst: 1})
+
+	assert.True(t, l.Allow("a").Allowed)
+	assert.False(t, l.Allow("a").Allowed)
+	assert.True(t, l.Allow("b").Allowed)
+}
+
+func TestLimiter_RefillIsCappedAtBurst(t *testing.T) {
+	l, now := newTestLimiter(Limit{Rate: 10, Burst: 2})
+
+	l.Allow("client")
+	*now = now.Add(time.Hour)
+
+	assert.True(t, l.Allow("client").Allowed)
+	assert.True(t, l.Allow("client").Allowed)
+	assert.False(t, l.Allow("client").Allowed)
+}
+
+func TestLimiter_SweepsRefilledBuckets(t *testing.T) {
+	l, now := newTestLimiter(Limit{Rate: 1, Burst: 100})
+
+	l.Allow("idle")
+	*now = now.Add(sweepInterval - time.Second)
+	for i := 0; i < 10; i++ {
+		l.Allow("busy")
+	}
+	assert.Equal(t, 2, l.Len())
+
+	// "idle" has refilled by the next sweep; "busy" has not.
+	*now = now.Add(time.Second)
+	l.Allow("other")
+	assert.Equal(t, 2, l.Len())
+}
@@ -1,13 +1,18 @@
 package repository
 
 import (
-	"github.com/raufhm/vfc/internal/domain"
+	"context"
+	"sort"
 	"sync"
+
+	"github.com/raufhm/vfc/internal/domain"
 )
 
 type InMemoryRepository struct {
-	mu       sync.RWMutex
-	products map[string]*domain.Product
+	mu           sync.RWMutex
+	products     map[string]*domain.Product
+	outbox       []*domain.ProductChange
+	nextOutboxID uint64
 }
 
 func NewInMemoryRepository() *InMemoryRepository {
@@ -16,7 +21,11 @@ func NewInMemoryRepository() *InMemoryRepository {
 	}
 }
 
-func (r *InMemoryRepository) Save(product *domain.Product) error {
+func (r *InMemoryRepository) Save(ctx context.Context, product *domain.Product) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -24,7 +33,90 @@ func (r *InMemoryRepository) Save(product *domain.Product) error {
 	return nil
 }
 
-func (r *InMemoryRepository) Get(productID string) (*domain.Product, error) {
+// SaveWithOutbox saves the product and appends its change record under the
+// same lock, so a saved product always has a matching outbox entry.
+func (r *InMemoryRepository) SaveWithOutbox(ctx context.Context, product *domain.Product) (*domain.ProductChange, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changeType := domain.ChangeUpdated
+	if _, exists := r.products[product.ProductID]; !exists {
+		changeType = domain.ChangeCreated
+	}
+
+	r.products[product.ProductID] = product
+
+	r.nextOutboxID++
+	change := domain.NewProductChange(changeType, copyProduct(product))
+	change.ID = r.nextOutboxID
+	r.outbox = append(r.outbox, change)
+
+	return change, nil
+}
+
+// PendingOutbox returns up to limit unpublished changes in the order they
+// were recorded.
+func (r *InMemoryRepository) PendingOutbox(ctx context.Context, limit int) ([]*domain.ProductChange, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if limit <= 0 || limit > len(r.outbox) {
+		limit = len(r.outbox)
+	}
+
+	changes := make([]*domain.ProductChange, limit)
+	copy(changes, r.outbox[:limit])
+	return changes, nil
+}
+
+func (r *InMemoryRepository) MarkPublished(ctx context.Context, ids ...uint64) error {
+	return r.removeOutbox(ctx, ids)
+}
+
+// MarkFailed drops the entries; the relay has already logged them in full.
+func (r *InMemoryRepository) MarkFailed(ctx context.Context, ids ...uint64) error {
+	return r.removeOutbox(ctx, ids)
+}
+
+func (r *InMemoryRepository) removeOutbox(ctx context.Context, ids []uint64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	done := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		done[id] = true
+	}
+
+	remaining := r.outbox[:0]
+	for _, change := range r.outbox {
+		if !done[change.ID] {
+			remaining = append(remaining, change)
+		}
+	}
+	for i := len(remaining); i < len(r.outbox); i++ {
+		r.outbox[i] = nil
+	}
+	r.outbox = remaining
+	return nil
+}
+
+func (r *InMemoryRepository) Get(ctx context.Context, productID string) (*domain.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -33,15 +125,43 @@ func (r *InMemoryRepository) Get(productID string) (*domain.Product, error) {
 		return nil, ErrProductNotFound
 	}
 
-	return &domain.Product{
-		ProductID: product.ProductID,
-		Price:     product.Price,
-		Stock:     product.Stock,
-		UpdatedAt: product.UpdatedAt,
-	}, nil
+	return copyProduct(product), nil
 }
 
-func (r *InMemoryRepository) Delete(productID string) error {
+func (r *InMemoryRepository) List(ctx context.Context, filter ListFilter) ([]*domain.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	products := make([]*domain.Product, 0)
+	for id, product := range r.products {
+		if filter.After != "" && id <= filter.After {
+			continue
+		}
+		if filter.Matches(product) {
+			products = append(products, copyProduct(product))
+		}
+	}
+
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].ProductID < products[j].ProductID
+	})
+
+	if filter.Limit > 0 && len(products) > filter.Limit {
+		products = products[:filter.Limit]
+	}
+
+	return products, nil
+}
+
+func (r *InMemoryRepository) Delete(ctx context.Context, productID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -53,9 +173,23 @@ func (r *InMemoryRepository) Close() error {
 	return nil
 }
 
+// Ping always succeeds; the repository lives in memory.
+func (r *InMemoryRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
 func (r *InMemoryRepository) Count() int {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	return len(r.products)
 }
+
+func copyProduct(product *domain.Product) *domain.Product {
+	return &domain.Product{
+		ProductID: product.ProductID,
+		Price:     product.Price,
+		Stock:     product.Stock,
+		UpdatedAt: product.UpdatedAt,
+	}
+}
@@ -1,7 +1,10 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"slices"
+
 	"github.com/raufhm/vfc/internal/domain"
 )
 
@@ -9,9 +12,53 @@ var (
 	ErrProductNotFound = errors.New("product not found")
 )
 
+// ListFilter narrows the products returned by List. Zero values match
+// everything. Results are ordered by product ID; After and Limit page
+// through them.
+type ListFilter struct {
+	ProductIDs  []string
+	MinPrice    *float64
+	MaxPrice    *float64
+	InStockOnly bool
+	After       string
+	Limit       int
+}
+
+// Matches reports whether product passes the filter, ignoring paging.
+func (f ListFilter) Matches(product *domain.Product) bool {
+	if len(f.ProductIDs) > 0 && !slices.Contains(f.ProductIDs, product.ProductID) {
+		return false
+	}
+	if f.MinPrice != nil && product.Price < *f.MinPrice {
+		return false
+	}
+	if f.MaxPrice != nil && product.Price > *f.MaxPrice {
+		return false
+	}
+	if f.InStockOnly && product.Stock <= 0 {
+		return false
+	}
+	return true
+}
+
+// ProductRepository stores products. Implementations should give up and
+// return ctx.Err() once ctx is done, so a hung backend cannot hold a worker
+// or request forever.
 type ProductRepository interface {
-	Save(product *domain.Product) error
-	Get(productID string) (*domain.Product, error)
-	Delete(productID string) error
+	Save(ctx context.Context, product *domain.Product) error
+	Get(ctx context.Context, productID string) (*domain.Product, error)
+	List(ctx context.Context, filter ListFilter) ([]*domain.Product, error)
+	Delete(ctx context.Context, productID string) error
 	Close() error
 }
+
+// OutboxRepository is implemented by repositories that can record a
+// ProductChanged entry in the same write as the product itself.
+type OutboxRepository interface {
+	ProductRepository
+	SaveWithOutbox(ctx context.Context, product *domain.Product) (*domain.ProductChange, error)
+	PendingOutbox(ctx context.Context, limit int) ([]*domain.ProductChange, error)
+	MarkPublished(ctx context.Context, ids ...uint64) error
+	// MarkFailed takes entries the relay gave up on out of the pending set.
+	MarkFailed(ctx context.Context, ids ...uint64) error
+}
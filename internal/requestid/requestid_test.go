@@ -0,0 +1,47 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestValid(t *testing.T) {
+	tests := map[string]bool{
+		"":                                     false,
+		"abc-123":                              true,
+		"9f1c2d3e-aaaa-4bbb-8ccc-0123456789ab": true,
+		"trace:span.1_2":                       true,
+		"has space":                            false,
+		"line\nbreak":                          false,
+		"quote\"":                              false,
+		strings.Repeat("a", 128):               true,
+		strings.Repeat("a", 129):               false,
+	}
+
+	for id, valid := range tests {
+		assert.Equal(t, valid, Valid(id), "%q", id)
+	}
+}
+
+func TestNew(t *testing.T) {
+	a, b := New(), New()
+	assert.True(t, Valid(a))
+	assert.Len(t, a, 32)
+	assert.NotEqual(t, a, b)
+}
+
+func TestContext(t *testing.T) {
+	fallback := zap.NewNop()
+	ctx := context.Background()
+	assert.Empty(t, FromContext(ctx))
+	assert.Same(t, fallback, Logger(ctx, fallback))
+
+	scoped := zap.NewExample()
+	ctx = WithLogger(WithID(ctx, "req-1"), scoped)
+	assert.Equal(t, "req-1", FromContext(ctx))
+	assert.Same(t, scoped, Logger(ctx, fallback))
+}
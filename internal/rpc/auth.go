@@ -0,0 +1,137 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	productv1 "github.com/raufhm/vfc/api/product/v1"
+	"github.com/raufhm/vfc/internal/auth"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// methodRoles is the role each RPC requires, matching routeRoles for the
+// equivalent HTTP routes. Methods missing from the table require admin.
+var methodRoles = map[string]auth.Role{
+	healthpb.Health_Check_FullMethodName: auth.RoleNone,
+	healthpb.Health_List_FullMethodName:  auth.RoleNone,
+	healthpb.Health_Watch_FullMethodName: auth.RoleNone,
+
+	productv1.ProductService_GetProduct_FullMethodName:    auth.RoleReader,
+	productv1.ProductService_ListProducts_FullMethodName:  auth.RoleReader,
+	productv1.ProductService_WatchProducts_FullMethodName: auth.RoleReader,
+
+	productv1.ProductService_CreateEvent_FullMethodName: auth.RoleWriter,
+}
+
+// RequiredRole returns the role needed to call method.
+func RequiredRole(method string) auth.Role {
+	role, ok := methodRoles[method]
+	if !ok {
+		return auth.RoleAdmin
+	}
+	return role
+}
+
+// Authorizer authenticates calls from the x-api-key and authorization
+// metadata, or the verified client certificate, with the same
+// authenticators as the HTTP API.
+type Authorizer struct {
+	authenticator auth.Authenticator
+	logger        *zap.Logger
+}
+
+func NewAuthorizer(authenticator auth.Authenticator, logger *zap.Logger) *Authorizer {
+	return &Authorizer{
+		authenticator: authenticator,
+		logger:        logger,
+	}
+}
+
+// ServerOptions returns the interceptors to pass to grpc.NewServer.
+func (a *Authorizer) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(a.unary),
+		grpc.ChainStreamInterceptor(a.stream),
+	}
+}
+
+func (a *Authorizer) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *Authorizer) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx})
+}
+
+func (a *Authorizer) authorize(ctx context.Context, method string) (context.Context, error) {
+	required := RequiredRole(method)
+
+	principal, err := a.authenticator.Authenticate(credentialsRequest(ctx))
+	if err != nil {
+		if required == auth.RoleNone {
+			return ctx, nil
+		}
+		if !errors.Is(err, auth.ErrNoCredentials) {
+			a.logger.Warn("Authentication failed", zap.String("method", method), zap.Error(err))
+			return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+		}
+		return nil, status.Error(codes.Unauthenticated, "credentials required")
+	}
+
+	if !principal.Role.Allows(required) {
+		a.logger.Warn("Access denied",
+			zap.String("principal", principal.ID),
+			zap.String("role", string(principal.Role)),
+			zap.String("required_role", string(required)),
+			zap.String("method", method))
+		return nil, status.Error(codes.PermissionDenied, "insufficient role")
+	}
+
+	return auth.WithPrincipal(ctx, principal), nil
+}
+
+// credentialsRequest carries the call's credentials in the form the
+// authenticators read them: metadata as headers and the peer's TLS state.
+func credentialsRequest(ctx context.Context) *http.Request {
+	r := &http.Request{Header: make(http.Header)}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if keys := md.Get(auth.APIKeyHeader); len(keys) > 0 {
+			r.Header.Set(auth.APIKeyHeader, keys[0])
+		}
+		if values := md.Get("authorization"); len(values) > 0 {
+			r.Header.Set("Authorization", values[0])
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			r.TLS = &info.State
+		}
+	}
+	return r
+}
+
+// authorizedStream carries the principal to stream handlers.
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
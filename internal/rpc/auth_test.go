@@ -0,0 +1,133 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	productv1 "github.com/raufhm/vfc/api/product/v1"
+	"github.com/raufhm/vfc/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// staticCredentials authenticates the API keys and bearer tokens it was
+// built with.
+type staticCredentials map[string]*auth.Principal
+
+func (c staticCredentials) Authenticate(r *http.Request) (*auth.Principal, error) {
+	credential := r.Header.Get(auth.APIKeyHeader)
+	if credential == "" {
+		credential = r.Header.Get("Authorization")
+	}
+	if credential == "" {
+		return nil, auth.ErrNoCredentials
+	}
+	p, ok := c[credential]
+	if !ok {
+		return nil, auth.ErrInvalidCredentials
+	}
+	return p, nil
+}
+
+var testCredentials = staticCredentials{
+	"reader-key":          {ID: "dashboard", Role: auth.RoleReader},
+	"writer-key":          {ID: "importer", Role: auth.RoleWriter},
+	"Bearer writer-token": {ID: "gateway", Role: auth.RoleWriter},
+}
+
+func TestMethodRoles_CoverEveryMethod(t *testing.T) {
+	for _, method := range productv1.ProductService_ServiceDesc.Methods {
+		_, ok := methodRoles["/"+productv1.ProductService_ServiceDesc.ServiceName+"/"+method.MethodName]
+		assert.True(t, ok, "%s has no entry in methodRoles", method.MethodName)
+	}
+	for _, stream := range productv1.ProductService_ServiceDesc.Streams {
+		_, ok := methodRoles["/"+productv1.ProductService_ServiceDesc.ServiceName+"/"+stream.StreamName]
+		assert.True(t, ok, "%s has no entry in methodRoles", stream.StreamName)
+	}
+}
+
+func TestAuthorizer(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	env := setupTest(t, NewAuthorizer(testCredentials, logger).ServerOptions()...)
+
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+	}
+	create := &productv1.CreateEventRequest{ProductId: "abc123", Price: 10, Stock: 5}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		call func(ctx context.Context) error
+		code codes.Code
+	}{
+		{"create without credentials", context.Background(), func(ctx context.Context) error {
+			_, err := env.client.CreateEvent(ctx, create)
+			return err
+		}, codes.Unauthenticated},
+		{"create with unknown key", withKey("nope"), func(ctx context.Context) error {
+			_, err := env.client.CreateEvent(ctx, create)
+			return err
+		}, codes.Unauthenticated},
+		{"create as reader", withKey("reader-key"), func(ctx context.Context) error {
+			_, err := env.client.CreateEvent(ctx, create)
+			return err
+		}, codes.PermissionDenied},
+		{"create as writer", withKey("writer-key"), func(ctx context.Context) error {
+			_, err := env.client.CreateEvent(ctx, create)
+			return err
+		}, codes.OK},
+		{"create with bearer token", metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer writer-token"), func(ctx context.Context) error {
+			_, err := env.client.CreateEvent(ctx, create)
+			return err
+		}, codes.OK},
+		{"list without credentials", context.Background(), func(ctx context.Context) error {
+			_, err := env.client.ListProducts(ctx, &productv1.ListProductsRequest{})
+			return err
+		}, codes.Unauthenticated},
+		{"list as reader", withKey("reader-key"), func(ctx context.Context) error {
+			_, err := env.client.ListProducts(ctx, &productv1.ListProductsRequest{})
+			return err
+		}, codes.OK},
+		{"watch without credentials", context.Background(), func(ctx context.Context) error {
+			stream, err := env.client.WatchProducts(ctx, &productv1.WatchProductsRequest{})
+			if err != nil {
+				return err
+			}
+			_, err = stream.Recv()
+			return err
+		}, codes.Unauthenticated},
+		{"health without credentials", context.Background(), func(ctx context.Context) error {
+			_, err := healthpb.NewHealthClient(env.conn).Check(ctx, &healthpb.HealthCheckRequest{})
+			return err
+		}, codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(tt.ctx, time.Second)
+			defer cancel()
+			assert.Equal(t, tt.code, status.Code(tt.call(ctx)))
+		})
+	}
+}
+
+func TestAuthorizer_RecordsPrincipal(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	env := setupTest(t, NewAuthorizer(testCredentials, logger).ServerOptions()...)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "writer-key")
+	_, err := env.client.CreateEvent(ctx, &productv1.CreateEventRequest{ProductId: "abc123", Price: 10, Stock: 5})
+	require.NoError(t, err)
+
+	event, err := env.queue.Dequeue()
+	require.NoError(t, err)
+	assert.Equal(t, "importer", event.Principal)
+}
@@ -0,0 +1,94 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/raufhm/vfc/internal/auth"
+	"github.com/raufhm/vfc/internal/ratelimit"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RateLimiter applies the HTTP API's token buckets to RPCs: methods that need
+// writer or admin take from the write bucket and the rest from the read
+// bucket, and health checks are not limited. Clients are keyed the same way
+// as over HTTP, by principal or else by peer IP, so pass it the limiters the
+// HTTP middleware uses and chain it after the Authorizer.
+type RateLimiter struct {
+	read   *ratelimit.Limiter
+	write  *ratelimit.Limiter
+	logger *zap.Logger
+}
+
+func NewRateLimiter(read, write *ratelimit.Limiter, logger *zap.Logger) *RateLimiter {
+	return &RateLimiter{
+		read:   read,
+		write:  write,
+		logger: logger,
+	}
+}
+
+// ServerOptions returns the interceptors to pass to grpc.NewServer.
+func (l *RateLimiter) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(l.unary),
+		grpc.ChainStreamInterceptor(l.stream),
+	}
+}
+
+func (l *RateLimiter) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := l.allow(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (l *RateLimiter) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := l.allow(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func (l *RateLimiter) allow(ctx context.Context, method string) error {
+	required := RequiredRole(method)
+	if required == auth.RoleNone {
+		return nil
+	}
+
+	limiter := l.read
+	if required.Allows(auth.RoleWriter) {
+		limiter = l.write
+	}
+
+	client := clientKey(ctx)
+	result := limiter.Allow(client)
+	if !result.Allowed {
+		l.logger.Warn("Rate limit exceeded",
+			zap.String("client", client),
+			zap.String("method", method))
+		return status.Error(codes.ResourceExhausted,
+			fmt.Sprintf("rate limit exceeded, retry after %ds", result.RetryAfterSeconds()))
+	}
+	return nil
+}
+
+// clientKey matches the keys of the HTTP rate limiter, so a client shares its
+// buckets across both APIs.
+func clientKey(ctx context.Context) string {
+	if id := auth.PrincipalID(ctx); id != "" {
+		return "principal:" + id
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return "ip:" + host
+		}
+		return "ip:" + p.Addr.String()
+	}
+	return "ip:"
+}
@@ -0,0 +1,54 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	productv1 "github.com/raufhm/vfc/api/product/v1"
+	"github.com/raufhm/vfc/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimiter(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	read := ratelimit.NewLimiter(ratelimit.Limit{Rate: 0.001, Burst: 2})
+	write := ratelimit.NewLimiter(ratelimit.Limit{Rate: 0.001, Burst: 1})
+
+	var opts []grpc.ServerOption
+	opts = append(opts, NewAuthorizer(testCredentials, logger).ServerOptions()...)
+	opts = append(opts, NewRateLimiter(read, write, logger).ServerOptions()...)
+	env := setupTest(t, opts...)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "writer-key")
+	create := &productv1.CreateEventRequest{ProductId: "abc123", Price: 10, Stock: 5}
+
+	_, err := env.client.CreateEvent(ctx, create)
+	require.NoError(t, err)
+	_, err = env.client.CreateEvent(ctx, create)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Reads have their own bucket.
+	_, err = env.client.GetProduct(ctx, &productv1.GetProductRequest{ProductId: "abc123"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = env.client.GetProduct(ctx, &productv1.GetProductRequest{ProductId: "abc123"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = env.client.GetProduct(ctx, &productv1.GetProductRequest{ProductId: "abc123"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// The buckets are the ones the HTTP API charges, keyed by principal.
+	assert.False(t, write.Allow("principal:importer").Allowed)
+
+	// Health checks are not limited.
+	health := healthpb.NewHealthClient(env.conn)
+	for i := 0; i < 3; i++ {
+		_, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+	}
+}
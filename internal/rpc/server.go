@@ -0,0 +1,186 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	productv1 "github.com/raufhm/vfc/api/product/v1"
+	"github.com/raufhm/vfc/internal/auth"
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/queue"
+	"github.com/raufhm/vfc/internal/repository"
+	"github.com/raufhm/vfc/internal/service"
+	"github.com/raufhm/vfc/internal/stream"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const defaultPageSize = 100
+
+// ProductServer implements productv1.ProductServiceServer on top of the same
+// ProductService and stream broker as the HTTP handlers.
+type ProductServer struct {
+	productv1.UnimplementedProductServiceServer
+
+	service *service.ProductService
+	broker  *stream.Broker
+	health  *health.Server
+	logger  *zap.Logger
+}
+
+func NewProductServer(service *service.ProductService, broker *stream.Broker, logger *zap.Logger) *ProductServer {
+	return &ProductServer{
+		service: service,
+		broker:  broker,
+		health:  health.NewServer(),
+		logger:  logger,
+	}
+}
+
+// Register adds the product service and the standard health service to s.
+func (s *ProductServer) Register(server *grpc.Server) {
+	productv1.RegisterProductServiceServer(server, s)
+	healthpb.RegisterHealthServer(server, s.health)
+
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	s.health.SetServingStatus(productv1.ProductService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+}
+
+// Shutdown reports NOT_SERVING to health checks so clients stop sending
+// new requests.
+func (s *ProductServer) Shutdown() {
+	s.health.Shutdown()
+}
+
+func (s *ProductServer) CreateEvent(ctx context.Context, req *productv1.CreateEventRequest) (*productv1.CreateEventResponse, error) {
+	event := domain.NewEvent(req.GetProductId(), req.GetPrice(), int(req.GetStock()))
+	event.Principal = auth.PrincipalID(ctx)
+
+	if err := event.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.service.EnqueueProductUpdate(ctx, event); err != nil {
+		s.logger.Error("Failed to enqueue event", zap.Error(err))
+		if queue.IsUnavailable(err) {
+			return nil, status.Error(codes.Unavailable, "event queue is unavailable")
+		}
+		return nil, status.Error(codes.Internal, "failed to enqueue event")
+	}
+
+	s.logger.Info("Event enqueued", zap.String("product_id", req.GetProductId()))
+
+	return &productv1.CreateEventResponse{}, nil
+}
+
+func (s *ProductServer) GetProduct(ctx context.Context, req *productv1.GetProductRequest) (*productv1.GetProductResponse, error) {
+	if req.GetProductId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "product_id is required")
+	}
+
+	product, err := s.service.GetProduct(ctx, req.GetProductId())
+	if err != nil {
+		if errors.Is(err, repository.ErrProductNotFound) {
+			return nil, status.Error(codes.NotFound, "product not found")
+		}
+		if ctx.Err() != nil {
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		s.logger.Error("Failed to get product", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get product")
+	}
+
+	return &productv1.GetProductResponse{Product: toProto(product)}, nil
+}
+
+func (s *ProductServer) ListProducts(ctx context.Context, req *productv1.ListProductsRequest) (*productv1.ListProductsResponse, error) {
+	pageSize := int(req.GetPageSize())
+	if pageSize < 0 {
+		return nil, status.Error(codes.InvalidArgument, "page_size must be non-negative")
+	}
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+
+	filter := repository.ListFilter{
+		ProductIDs:  req.GetProductIds(),
+		MinPrice:    req.MinPrice,
+		MaxPrice:    req.MaxPrice,
+		InStockOnly: req.GetInStockOnly(),
+		After:       req.GetPageToken(),
+		// One extra product tells us whether there is another page.
+		Limit: pageSize + 1,
+	}
+
+	products, err := s.service.ListProducts(ctx, filter)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		s.logger.Error("Failed to list products", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list products")
+	}
+
+	resp := &productv1.ListProductsResponse{}
+	if len(products) > pageSize {
+		products = products[:pageSize]
+		resp.NextPageToken = products[pageSize-1].ProductID
+	}
+	for _, product := range products {
+		resp.Products = append(resp.Products, toProto(product))
+	}
+
+	return resp, nil
+}
+
+func (s *ProductServer) WatchProducts(req *productv1.WatchProductsRequest, stream grpc.ServerStreamingServer[productv1.WatchProductsResponse]) error {
+	sub := s.broker.Subscribe(req.GetProductIds(), req.GetLastEventId())
+	defer s.broker.Unsubscribe(sub)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case msg, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.Unavailable, "stream closed, resume with last_event_id")
+			}
+
+			if err := stream.Send(&productv1.WatchProductsResponse{
+				Change: &productv1.ProductChange{
+					EventId:    msg.ID,
+					Type:       toProtoChangeType(msg.Change.Type),
+					Product:    toProto(msg.Change.Product),
+					OccurredAt: timestamppb.New(msg.Change.OccurredAt),
+				},
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func toProto(product *domain.Product) *productv1.Product {
+	return &productv1.Product{
+		ProductId: product.ProductID,
+		Price:     product.Price,
+		Stock:     int64(product.Stock),
+		UpdatedAt: timestamppb.New(product.UpdatedAt),
+	}
+}
+
+func toProtoChangeType(t domain.ChangeType) productv1.ChangeType {
+	switch t {
+	case domain.ChangeCreated:
+		return productv1.ChangeType_CHANGE_TYPE_CREATED
+	case domain.ChangeUpdated:
+		return productv1.ChangeType_CHANGE_TYPE_UPDATED
+	default:
+		return productv1.ChangeType_CHANGE_TYPE_UNSPECIFIED
+	}
+}
@@ -0,0 +1,193 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	productv1 "github.com/raufhm/vfc/api/product/v1"
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/queue"
+	"github.com/raufhm/vfc/internal/repository"
+	"github.com/raufhm/vfc/internal/service"
+	"github.com/raufhm/vfc/internal/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testEnv struct {
+	client productv1.ProductServiceClient
+	conn   *grpc.ClientConn
+	repo   *repository.InMemoryRepository
+	queue  *queue.InMemoryQueue
+	broker *stream.Broker
+}
+
+func setupTest(t *testing.T, opts ...grpc.ServerOption) *testEnv {
+	t.Helper()
+
+	logger, _ := zap.NewDevelopment()
+	repo := repository.NewInMemoryRepository()
+	q := queue.NewInMemoryQueue(10, logger)
+	svc := service.NewProductService(repo, q)
+	broker := stream.NewBroker(10, 10, logger)
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(opts...)
+	NewProductServer(svc, broker, logger).Register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return &testEnv{
+		client: productv1.NewProductServiceClient(conn),
+		conn:   conn,
+		repo:   repo,
+		queue:  q,
+		broker: broker,
+	}
+}
+
+func TestCreateEvent(t *testing.T) {
+	env := setupTest(t)
+
+	_, err := env.client.CreateEvent(context.Background(), &productv1.CreateEventRequest{
+		ProductId: "abc123",
+		Price:     49.99,
+		Stock:     100,
+	})
+	require.NoError(t, err)
+
+	event, err := env.queue.Dequeue()
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", event.ProductID)
+	assert.Equal(t, 100, event.Stock)
+}
+
+func TestCreateEvent_InvalidArgument(t *testing.T) {
+	env := setupTest(t)
+
+	_, err := env.client.CreateEvent(context.Background(), &productv1.CreateEventRequest{
+		ProductId: "abc123",
+		Price:     -1,
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "price must be non-negative", status.Convert(err).Message())
+}
+
+func TestGetProduct(t *testing.T) {
+	env := setupTest(t)
+	require.NoError(t, env.repo.Save(context.Background(), domain.NewProduct("abc123", 49.99, 100)))
+
+	resp, err := env.client.GetProduct(context.Background(), &productv1.GetProductRequest{ProductId: "abc123"})
+	require.NoError(t, err)
+	assert.Equal(t, 49.99, resp.GetProduct().GetPrice())
+
+	_, err = env.client.GetProduct(context.Background(), &productv1.GetProductRequest{ProductId: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestListProducts_Paginates(t *testing.T) {
+	env := setupTest(t)
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, env.repo.Save(context.Background(), domain.NewProduct(id, 1, 1)))
+	}
+
+	first, err := env.client.ListProducts(context.Background(), &productv1.ListProductsRequest{PageSize: 2})
+	require.NoError(t, err)
+	require.Len(t, first.GetProducts(), 2)
+	assert.Equal(t, "b", first.GetNextPageToken())
+
+	second, err := env.client.ListProducts(context.Background(), &productv1.ListProductsRequest{
+		PageSize:  2,
+		PageToken: first.GetNextPageToken(),
+	})
+	require.NoError(t, err)
+	require.Len(t, second.GetProducts(), 1)
+	assert.Equal(t, "c", second.GetProducts()[0].GetProductId())
+	assert.Empty(t, second.GetNextPageToken())
+}
+
+func TestWatchProducts(t *testing.T) {
+	env := setupTest(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	watch, err := env.client.WatchProducts(ctx, &productv1.WatchProductsRequest{ProductIds: []string{"a"}})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return env.broker.SubscriberCount() == 1 }, time.Second, 5*time.Millisecond)
+	env.broker.Publish(domain.NewProductChange(domain.ChangeUpdated, domain.NewProduct("b", 1, 1)))
+	env.broker.Publish(domain.NewProductChange(domain.ChangeCreated, domain.NewProduct("a", 2, 2)))
+
+	resp, err := watch.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "a", resp.GetChange().GetProduct().GetProductId())
+	assert.Equal(t, productv1.ChangeType_CHANGE_TYPE_CREATED, resp.GetChange().GetType())
+	assert.Equal(t, uint64(2), resp.GetChange().GetEventId())
+}
+
+func TestHealthCheck(t *testing.T) {
+	env := setupTest(t)
+
+	resp, err := healthpb.NewHealthClient(env.conn).Check(context.Background(), &healthpb.HealthCheckRequest{
+		Service: productv1.ProductService_ServiceDesc.ServiceName,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+}
+
+func TestCreateEvent_QueueClosed(t *testing.T) {
+	env := setupTest(t)
+	require.NoError(t, env.queue.Close())
+
+	_, err := env.client.CreateEvent(context.Background(), &productv1.CreateEventRequest{
+		ProductId: "abc123",
+		Price:     10,
+		Stock:     5,
+	})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+type failingQueue struct {
+	queue.QueueProvider
+	err error
+}
+
+func (q *failingQueue) Enqueue(ctx context.Context, event *domain.Event) error {
+	return q.err
+}
+
+func TestCreateEvent_QueueUnavailable(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+
+	for _, queueErr := range []error{queue.ErrNotConnected, queue.ErrPublishNotAcked, queue.ErrQueueClosed} {
+		t.Run(queueErr.Error(), func(t *testing.T) {
+			svc := service.NewProductService(repository.NewInMemoryRepository(), &failingQueue{err: queueErr})
+			server := NewProductServer(svc, stream.NewBroker(10, 10, logger), logger)
+
+			_, err := server.CreateEvent(context.Background(), &productv1.CreateEventRequest{
+				ProductId: "abc123",
+				Price:     10,
+				Stock:     5,
+			})
+			assert.Equal(t, codes.Unavailable, status.Code(err))
+		})
+	}
+}
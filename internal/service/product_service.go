@@ -1,9 +1,16 @@
 package service
 
 import (
+	"context"
+
 	"github.com/raufhm/vfc/internal/domain"
 	"github.com/raufhm/vfc/internal/queue"
 	"github.com/raufhm/vfc/internal/repository"
+	"github.com/raufhm/vfc/internal/requestid"
+	"github.com/raufhm/vfc/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ProductService handles business logic for products
@@ -20,12 +27,36 @@ func NewProductService(repo repository.ProductRepository, queue queue.QueueProvi
 	}
 }
 
-// EnqueueProductUpdate enqueues a product update event
-func (s *ProductService) EnqueueProductUpdate(event *domain.Event) error {
-	return s.queue.Enqueue(event)
+// EnqueueProductUpdate enqueues a product update event, giving up once ctx is
+// done, such as when the client disconnects while the queue is full. The
+// event carries the request ID and trace context of ctx so the worker's logs
+// and span can be tied back to the request.
+func (s *ProductService) EnqueueProductUpdate(ctx context.Context, event *domain.Event) error {
+	if event.RequestID == "" {
+		event.RequestID = requestid.FromContext(ctx)
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "enqueue product update",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("product.id", event.ProductID)))
+	defer span.End()
+
+	tracing.Inject(ctx, event)
+	if err := s.queue.Enqueue(ctx, event); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "enqueue failed")
+		return err
+	}
+	return nil
+}
+
+// GetProduct retrieves a product by ID. The lookup is abandoned once ctx is
+// done, such as when the client disconnects.
+func (s *ProductService) GetProduct(ctx context.Context, productID string) (*domain.Product, error) {
+	return s.repo.Get(ctx, productID)
 }
 
-// GetProduct retrieves a product by ID
-func (s *ProductService) GetProduct(productID string) (*domain.Product, error) {
-	return s.repo.Get(productID)
+// ListProducts returns products matching the filter, ordered by ID
+func (s *ProductService) ListProducts(ctx context.Context, filter repository.ListFilter) ([]*domain.Product, error) {
+	return s.repo.List(ctx, filter)
 }
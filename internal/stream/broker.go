@@ -0,0 +1,211 @@
+package stream
+
+import (
+	"sync"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"go.uber.org/zap"
+)
+
+// Message is a product change tagged with the broker's sequence number, which
+// clients use to resume a stream.
+type Message struct {
+	ID     uint64
+	Change *domain.ProductChange
+}
+
+// Subscriber receives messages for the products it watches, or for every
+// product. Its channel is closed when it unsubscribes, when the broker
+// closes, or when it falls a full buffer behind.
+type Subscriber struct {
+	C <-chan Message
+
+	ch       chan Message
+	all      bool
+	products map[string]bool
+	evicted  bool
+}
+
+// Evicted reports whether C was closed because the subscriber fell behind
+// rather than because it unsubscribed or the broker closed. It is only
+// meaningful once C is closed.
+func (s *Subscriber) Evicted() bool {
+	return s.evicted
+}
+
+func (s *Subscriber) wants(productID string) bool {
+	return s.all || s.products[productID]
+}
+
+// Broker fans product changes out to subscribers. Publishing never blocks: a
+// subscriber whose buffer is full is dropped so it can reconnect and replay
+// from the broker's history instead of stalling the worker pool.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[*Subscriber]struct{}
+	history     []Message
+	historySize int
+	bufferSize  int
+	nextID      uint64
+	closed      bool
+	logger      *zap.Logger
+}
+
+func NewBroker(bufferSize, historySize int, logger *zap.Logger) *Broker {
+	return &Broker{
+		subscribers: make(map[*Subscriber]struct{}),
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		logger:      logger,
+	}
+}
+
+// Publish assigns the change the next sequence number and delivers it to
+// every interested subscriber.
+func (b *Broker) Publish(change *domain.ProductChange) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.nextID++
+	msg := Message{ID: b.nextID, Change: change}
+
+	b.history = append(b.history, msg)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for sub := range b.subscribers {
+		if !sub.wants(change.Product.ProductID) {
+			continue
+		}
+
+		select {
+		case sub.ch <- msg:
+		default:
+			b.logger.Warn("Stream subscriber too slow, disconnecting",
+				zap.Uint64("event_id", msg.ID))
+			sub.evicted = true
+			b.remove(sub)
+		}
+	}
+}
+
+// Subscribe registers a subscriber for productIDs (all products if empty).
+// Messages after lastEventID that are still in history are replayed first,
+// so a reconnecting client sees no gap as long as it was not away too long.
+func (b *Broker) Subscribe(productIDs []string, lastEventID uint64) *Subscriber {
+	sub := &Subscriber{
+		all:      len(productIDs) == 0,
+		products: make(map[string]bool, len(productIDs)),
+	}
+	for _, id := range productIDs {
+		sub.products[id] = true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Message
+	if lastEventID > 0 {
+		for _, msg := range b.history {
+			if msg.ID > lastEventID && sub.wants(msg.Change.Product.ProductID) {
+				replay = append(replay, msg)
+			}
+		}
+	}
+
+	sub.ch = make(chan Message, b.bufferSize+len(replay))
+	sub.C = sub.ch
+	for _, msg := range replay {
+		sub.ch <- msg
+	}
+
+	if b.closed {
+		close(sub.ch)
+		return sub
+	}
+
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// SubscribeNone registers a subscriber that receives nothing until it is
+// given products to Watch.
+func (b *Broker) SubscribeNone() *Subscriber {
+	sub := &Subscriber{
+		ch:       make(chan Message, b.bufferSize),
+		products: make(map[string]bool),
+	}
+	sub.C = sub.ch
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(sub.ch)
+		return sub
+	}
+
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Watch adds productIDs to the products sub receives.
+func (b *Broker) Watch(sub *Subscriber, productIDs ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, id := range productIDs {
+		sub.products[id] = true
+	}
+}
+
+// Unwatch stops sub receiving productIDs. A subscriber that was receiving
+// every product only receives the products it watches afterwards.
+func (b *Broker) Unwatch(sub *Subscriber, productIDs ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub.all = false
+	for _, id := range productIDs {
+		delete(sub.products, id)
+	}
+}
+
+func (b *Broker) Unsubscribe(sub *Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(sub)
+}
+
+// Close disconnects every subscriber and stops accepting new messages.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		b.remove(sub)
+	}
+}
+
+// SubscriberCount returns the number of connected subscribers.
+func (b *Broker) SubscriberCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.subscribers)
+}
+
+func (b *Broker) remove(sub *Subscriber) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+	delete(b.subscribers, sub)
+	close(sub.ch)
+}
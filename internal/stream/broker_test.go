@@ -0,0 +1,107 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func change(productID string) *domain.ProductChange {
+	return domain.NewProductChange(domain.ChangeUpdated, domain.NewProduct(productID, 1, 1))
+}
+
+func drain(sub *Subscriber) []Message {
+	var msgs []Message
+	for {
+		select {
+		case msg, ok := <-sub.C:
+			if !ok {
+				return msgs
+			}
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
+	}
+}
+
+func TestBroker_FiltersByProduct(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	b := NewBroker(10, 10, logger)
+
+	sub := b.Subscribe([]string{"a"}, 0)
+	b.Publish(change("a"))
+	b.Publish(change("b"))
+
+	msgs := drain(sub)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "a", msgs[0].Change.Product.ProductID)
+	assert.Equal(t, uint64(1), msgs[0].ID)
+}
+
+func TestBroker_ResumeReplaysHistory(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	b := NewBroker(10, 10, logger)
+
+	for _, id := range []string{"a", "b", "c"} {
+		b.Publish(change(id))
+	}
+
+	sub := b.Subscribe(nil, 1)
+	b.Publish(change("d"))
+
+	var ids []uint64
+	for _, msg := range drain(sub) {
+		ids = append(ids, msg.ID)
+	}
+	assert.Equal(t, []uint64{2, 3, 4}, ids)
+}
+
+func TestBroker_SlowSubscriberIsDropped(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	b := NewBroker(2, 10, logger)
+
+	slow := b.Subscribe(nil, 0)
+	fast := b.Subscribe(nil, 0)
+
+	for i := 0; i < 2; i++ {
+		b.Publish(change("a"))
+		drain(fast)
+	}
+	b.Publish(change("a"))
+
+	assert.Equal(t, 1, b.SubscriberCount())
+	msgs := drain(slow)
+	assert.Len(t, msgs, 2)
+	_, open := <-slow.C
+	assert.False(t, open)
+	assert.True(t, slow.Evicted())
+	assert.Len(t, drain(fast), 1)
+
+	b.Close()
+	assert.False(t, fast.Evicted())
+}
+
+func TestBroker_WatchAndUnwatch(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	b := NewBroker(10, 10, logger)
+
+	sub := b.SubscribeNone()
+	b.Publish(change("a"))
+	assert.Empty(t, drain(sub))
+
+	b.Watch(sub, "a", "b")
+	b.Publish(change("a"))
+	b.Publish(change("b"))
+	assert.Len(t, drain(sub), 2)
+
+	b.Unwatch(sub, "a")
+	b.Publish(change("a"))
+	b.Publish(change("b"))
+	msgs := drain(sub)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "b", msgs[0].Change.Product.ProductID)
+}
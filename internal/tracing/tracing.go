@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName identifies spans created by this service.
+const TracerName = "github.com/raufhm/vfc"
+
+// Config selects where spans are exported. Exporter is "none", "stdout" or
+// "file". stdout prints one JSON span per line for reading by eye; the file
+// exporter appends OTLP/JSON to FilePath, one batch of spans per line, for
+// replaying into a collector.
+type Config struct {
+	Exporter    string
+	FilePath    string
+	ServiceName string
+	SampleRatio float64
+}
+
+// Setup installs a global tracer provider and W3C trace context propagation.
+// The returned provider must be shut down to flush buffered spans.
+func Setup(cfg Config) (*sdktrace.TracerProvider, error) {
+	exporter, err := newExporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	}
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	return Install(opts...), nil
+}
+
+// Install sets a tracer provider built from opts as the global one. Tests
+// use it with an in-memory exporter.
+func Install(opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return provider
+}
+
+func newExporter(cfg Config) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case "none", "":
+		return nil, nil
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "file":
+		f, err := os.OpenFile(cfg.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		return newOTLPFileExporter(f), nil
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+}
+
+// Tracer returns the service's tracer from the global provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// Inject stores the span context of ctx on the event so the worker that
+// processes it can continue the trace.
+func Inject(ctx context.Context, event *domain.Event) {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) > 0 {
+		event.TraceContext = carrier
+	}
+}
+
+// Extract returns a context carrying the span context stored on the event.
+func Extract(ctx context.Context, event *domain.Event) context.Context {
+	if len(event.TraceContext) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(event.TraceContext))
+}
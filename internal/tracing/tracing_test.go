@@ -0,0 +1,110 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInjectExtract_SurvivesSerialization(t *testing.T) {
+	provider := Install()
+	defer provider.Shutdown(context.Background())
+
+	ctx, span := Tracer().Start(context.Background(), "submit")
+	defer span.End()
+
+	event := domain.NewEvent("abc", 1, 1)
+	Inject(ctx, event)
+	require.Contains(t, event.TraceContext, "traceparent")
+
+	// Broker-backed queues carry the event as JSON.
+	body, err := json.Marshal(event)
+	require.NoError(t, err)
+	var received domain.Event
+	require.NoError(t, json.Unmarshal(body, &received))
+
+	remote := trace.SpanContextFromContext(Extract(context.Background(), &received))
+	assert.Equal(t, span.SpanContext().TraceID(), remote.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), remote.SpanID())
+	assert.True(t, remote.IsRemote())
+}
+
+func TestInject_WithoutSpanLeavesEventUntouched(t *testing.T) {
+	provider := Install()
+	defer provider.Shutdown(context.Background())
+
+	event := domain.NewEvent("abc", 1, 1)
+	Inject(context.Background(), event)
+	assert.Nil(t, event.TraceContext)
+
+	ctx := context.Background()
+	assert.Equal(t, ctx, Extract(ctx, event))
+}
+
+func TestSetup_FileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	provider, err := Setup(Config{Exporter: "file", FilePath: path, ServiceName: "vfc-test", SampleRatio: 1})
+	require.NoError(t, err)
+
+	ctx, parent := Tracer().Start(context.Background(), "parent")
+	_, span := Tracer().Start(ctx, "exported span",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.Int("worker.id", 3)))
+	span.SetStatus(codes.Error, "failed")
+	span.End()
+	parent.End()
+	require.NoError(t, provider.Shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	// OTLP/JSON, as the collector's file exporter writes it.
+	var traces struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []map[string]interface{} `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Scope struct {
+					Name string `json:"name"`
+				} `json:"scope"`
+				Spans []map[string]interface{} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	require.NoError(t, json.Unmarshal(data, &traces))
+	require.Len(t, traces.ResourceSpans, 1)
+	assert.Contains(t, traces.ResourceSpans[0].Resource.Attributes, map[string]interface{}{
+		"key":   "service.name",
+		"value": map[string]interface{}{"stringValue": "vfc-test"},
+	})
+	scope := traces.ResourceSpans[0].ScopeSpans[0]
+	assert.Equal(t, TracerName, scope.Scope.Name)
+	require.Len(t, scope.Spans, 2)
+
+	exported := scope.Spans[0]
+	assert.Equal(t, "exported span", exported["name"])
+	assert.Equal(t, span.SpanContext().TraceID().String(), exported["traceId"])
+	assert.Equal(t, parent.SpanContext().SpanID().String(), exported["parentSpanId"])
+	assert.Equal(t, 5.0, exported["kind"])
+	assert.Equal(t, map[string]interface{}{"code": 2.0, "message": "failed"}, exported["status"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"key":   "worker.id",
+		"value": map[string]interface{}{"intValue": "3"},
+	}}, exported["attributes"])
+	assert.IsType(t, "", exported["startTimeUnixNano"])
+}
+
+func TestSetup_RejectsUnknownExporter(t *testing.T) {
+	_, err := Setup(Config{Exporter: "jaeger"})
+	assert.Error(t, err)
+}
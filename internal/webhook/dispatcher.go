@@ -0,0 +1,188 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"go.uber.org/zap"
+)
+
+type job struct {
+	sub    *Subscription
+	change *domain.ProductChange
+}
+
+type payload struct {
+	SubscriptionID string `json:"subscription_id"`
+	*domain.ProductChange
+}
+
+// Dispatcher delivers product changes to matching subscriptions. Each
+// delivery is signed with the subscription secret and retried with
+// exponential backoff; every attempt is recorded in the store's delivery log.
+type Dispatcher struct {
+	store       *Store
+	client      *http.Client
+	workers     int
+	maxAttempts int
+	baseDelay   time.Duration
+	logger      *zap.Logger
+	jobs        chan job
+	wg          sync.WaitGroup
+	ctx         context.Context
+	cancel      context.CancelFunc
+}
+
+func NewDispatcher(store *Store, workers, maxAttempts int, timeout, baseDelay time.Duration, logger *zap.Logger) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		store:       store,
+		client:      &http.Client{Timeout: timeout},
+		workers:     workers,
+		maxAttempts: maxAttempts,
+		baseDelay:   baseDelay,
+		logger:      logger,
+		jobs:        make(chan job, 1000),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+func (d *Dispatcher) Start() {
+	d.logger.Info("Starting webhook dispatcher", zap.Int("worker_count", d.workers))
+
+	for i := 0; i < d.workers; i++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+}
+
+func (d *Dispatcher) Stop() {
+	d.logger.Info("Stopping webhook dispatcher")
+	d.cancel()
+	d.wg.Wait()
+	d.logger.Info("Webhook dispatcher stopped")
+}
+
+// Notify queues change for every matching subscription. It never blocks, so
+// it is safe to use as a worker pool save hook; if the backlog is full the
+// delivery is dropped and logged.
+func (d *Dispatcher) Notify(change *domain.ProductChange) {
+	for _, sub := range d.store.List() {
+		if !sub.Matches(change) {
+			continue
+		}
+
+		select {
+		case d.jobs <- job{sub: sub, change: change}:
+		default:
+			d.logger.Warn("Webhook backlog full, dropping delivery",
+				zap.String("subscription_id", sub.ID),
+				zap.String("product_id", change.Product.ProductID))
+			d.store.RecordDelivery(sub.ID, Delivery{
+				ChangeID:    change.ID,
+				ProductID:   change.Product.ProductID,
+				Error:       "delivery backlog full",
+				AttemptedAt: time.Now(),
+			})
+		}
+	}
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case j := <-d.jobs:
+			d.deliver(j)
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(j job) {
+	body, err := json.Marshal(payload{SubscriptionID: j.sub.ID, ProductChange: j.change})
+	if err != nil {
+		d.logger.Error("Failed to encode webhook payload", zap.Error(err))
+		return
+	}
+
+	delay := d.baseDelay
+	for attempt := 1; attempt <= d.maxAttempts; attempt++ {
+		start := time.Now()
+		status, err := d.post(j.sub, j.change.Type, body)
+
+		delivery := Delivery{
+			ChangeID:    j.change.ID,
+			ProductID:   j.change.Product.ProductID,
+			Attempt:     attempt,
+			StatusCode:  status,
+			Success:     err == nil,
+			Duration:    time.Since(start).String(),
+			AttemptedAt: start,
+		}
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		d.store.RecordDelivery(j.sub.ID, delivery)
+
+		if err == nil {
+			return
+		}
+
+		d.logger.Warn("Webhook delivery failed",
+			zap.String("subscription_id", j.sub.ID),
+			zap.String("product_id", j.change.Product.ProductID),
+			zap.Int("attempt", attempt),
+			zap.Error(err))
+
+		if attempt == d.maxAttempts {
+			break
+		}
+
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+
+	d.logger.Error("Webhook delivery abandoned",
+		zap.String("subscription_id", j.sub.ID),
+		zap.String("product_id", j.change.Product.ProductID),
+		zap.Int("attempts", d.maxAttempts))
+}
+
+func (d *Dispatcher) post(sub *Subscription, changeType domain.ChangeType, body []byte) (int, error) {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, "product."+string(changeType))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestDispatcher(t *testing.T, store *Store) *Dispatcher {
+	t.Helper()
+
+	logger, _ := zap.NewDevelopment()
+	d := NewDispatcher(store, 2, 3, time.Second, 10*time.Millisecond, logger)
+	d.Start()
+	t.Cleanup(d.Stop)
+	return d
+}
+
+func TestDispatcher_DeliversSignedPayload(t *testing.T) {
+	received := make(chan bool, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		expected := Sign("s3cret", timestamp, body)
+		received <- hmac.Equal([]byte(expected), []byte(r.Header.Get(SignatureHeader)))
+	}))
+	defer server.Close()
+
+	store := NewStore(10)
+	sub, err := store.Create(&Subscription{URL: server.URL, Secret: "s3cret"})
+	require.NoError(t, err)
+
+	d := newTestDispatcher(t, store)
+	d.Notify(domain.NewProductChange(domain.ChangeCreated, domain.NewProduct("abc123", 49.99, 100)))
+
+	select {
+	case valid := <-received:
+		assert.True(t, valid, "signature did not verify")
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+
+	assert.Eventually(t, func() bool {
+		log, _ := store.Deliveries(sub.ID)
+		return len(log) == 1 && log[0].Success
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestDispatcher_RetriesWithBackoff(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	store := NewStore(10)
+	sub, err := store.Create(&Subscription{URL: server.URL})
+	require.NoError(t, err)
+
+	d := newTestDispatcher(t, store)
+	d.Notify(domain.NewProductChange(domain.ChangeUpdated, domain.NewProduct("abc123", 1, 1)))
+
+	require.Eventually(t, func() bool {
+		log, _ := store.Deliveries(sub.ID)
+		return len(log) == 3
+	}, 2*time.Second, 5*time.Millisecond)
+
+	log, _ := store.Deliveries(sub.ID)
+	assert.False(t, log[0].Success)
+	assert.Equal(t, http.StatusBadGateway, log[0].StatusCode)
+	assert.Equal(t, 3, log[2].Attempt)
+	assert.True(t, log[2].Success)
+}
+
+func TestSubscription_Matches(t *testing.T) {
+	sub := &Subscription{
+		ProductPattern: "sku-*",
+		ChangeTypes:    []domain.ChangeType{domain.ChangeUpdated},
+	}
+
+	tests := []struct {
+		productID  string
+		changeType domain.ChangeType
+		want       bool
+	}{
+		{"sku-1", domain.ChangeUpdated, true},
+		{"sku-1", domain.ChangeCreated, false},
+		{"other", domain.ChangeUpdated, false},
+	}
+
+	for _, tt := range tests {
+		change := domain.NewProductChange(tt.changeType, domain.NewProduct(tt.productID, 1, 1))
+		assert.Equal(t, tt.want, sub.Matches(change), "%s/%s", tt.productID, tt.changeType)
+	}
+}
@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrSubscriptionNotFound = errors.New("subscription not found")
+
+// Store keeps subscriptions and a bounded delivery log per subscription in
+// memory.
+type Store struct {
+	mu            sync.RWMutex
+	subscriptions map[string]*Subscription
+	deliveries    map[string][]Delivery
+	logSize       int
+}
+
+func NewStore(logSize int) *Store {
+	return &Store{
+		subscriptions: make(map[string]*Subscription),
+		deliveries:    make(map[string][]Delivery),
+		logSize:       logSize,
+	}
+}
+
+// Create assigns an ID, and a secret if none was given, and stores sub.
+func (s *Store) Create(sub *Subscription) (*Subscription, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Secret == "" {
+		if sub.Secret, err = randomHex(32); err != nil {
+			return nil, err
+		}
+	}
+	sub.ID = id
+	sub.CreatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscriptions[sub.ID] = sub
+	return sub, nil
+}
+
+func (s *Store) Get(id string) (*Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sub, ok := s.subscriptions[id]
+	if !ok {
+		return nil, ErrSubscriptionNotFound
+	}
+	return sub, nil
+}
+
+// List returns all subscriptions, oldest first.
+func (s *Store) List() []*Subscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subs := make([]*Subscription, 0, len(s.subscriptions))
+	for _, sub := range s.subscriptions {
+		subs = append(subs, sub)
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].CreatedAt.Before(subs[j].CreatedAt)
+	})
+	return subs
+}
+
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscriptions[id]; !ok {
+		return ErrSubscriptionNotFound
+	}
+	delete(s.subscriptions, id)
+	delete(s.deliveries, id)
+	return nil
+}
+
+// RecordDelivery appends to the subscription's log, dropping the oldest
+// entries beyond the log size.
+func (s *Store) RecordDelivery(id string, delivery Delivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscriptions[id]; !ok {
+		return
+	}
+
+	log := append(s.deliveries[id], delivery)
+	if len(log) > s.logSize {
+		log = log[len(log)-s.logSize:]
+	}
+	s.deliveries[id] = log
+}
+
+// Deliveries returns the subscription's log, most recent last.
+func (s *Store) Deliveries(id string) ([]Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.subscriptions[id]; !ok {
+		return nil, ErrSubscriptionNotFound
+	}
+
+	log := make([]Delivery, len(s.deliveries[id]))
+	copy(log, s.deliveries[id])
+	return log, nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
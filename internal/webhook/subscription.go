@@ -0,0 +1,72 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/raufhm/vfc/internal/domain"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+)
+
+// Subscription is a partner endpoint that receives product changes matching
+// its filters. An empty ProductPattern or ChangeTypes matches everything.
+type Subscription struct {
+	ID             string              `json:"id"`
+	URL            string              `json:"url"`
+	ProductPattern string              `json:"product_pattern,omitempty"`
+	ChangeTypes    []domain.ChangeType `json:"change_types,omitempty"`
+	Secret         string              `json:"-"`
+	CreatedAt      time.Time           `json:"created_at"`
+}
+
+// Matches reports whether change passes the subscription's filters.
+// ProductPattern uses path.Match syntax, e.g. "sku-*".
+func (s *Subscription) Matches(change *domain.ProductChange) bool {
+	if s.ProductPattern != "" {
+		if ok, _ := path.Match(s.ProductPattern, change.Product.ProductID); !ok {
+			return false
+		}
+	}
+
+	if len(s.ChangeTypes) == 0 {
+		return true
+	}
+	for _, t := range s.ChangeTypes {
+		if t == change.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery records a single attempt to deliver a change to a subscription.
+type Delivery struct {
+	ChangeID    uint64    `json:"change_id,string"`
+	ProductID   string    `json:"product_id"`
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Success     bool      `json:"success"`
+	Duration    string    `json:"duration"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// Sign returns the signature sent in SignatureHeader: the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the subscription secret. Receivers should
+// recompute it and compare with hmac.Equal.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,220 @@
+package worker
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"go.uber.org/zap"
+)
+
+// tolerance is how far a signal may drift from its target before the
+// autoscaler acts, so small fluctuations do not resize the pool.
+const tolerance = 0.1
+
+// Reasons reported with each scaling decision.
+const (
+	ReasonQueueDepth = "queue_depth"
+	ReasonLatency    = "latency"
+	ReasonIdle       = "idle"
+	ReasonBounds     = "bounds"
+)
+
+// AutoscaleConfig bounds and tunes the autoscaler. A zero target disables
+// that signal.
+type AutoscaleConfig struct {
+	Min               int
+	Max               int
+	Interval          time.Duration
+	TargetQueueDepth  float64 // events waiting per worker
+	TargetLatency     time.Duration
+	ScaleUpCooldown   time.Duration
+	ScaleDownCooldown time.Duration
+}
+
+// ScaleObserver is told about every resize the autoscaler makes.
+type ScaleObserver interface {
+	ObserveScale(from, to int, reason string)
+}
+
+// Decision is the outcome of one evaluation.
+type Decision struct {
+	From       int
+	To         int
+	Reason     string
+	QueueDepth int
+	Latency    time.Duration
+}
+
+// Autoscaler resizes a pool between Min and Max workers. Each interval it
+// compares the queue depth per worker and the average event latency since
+// the last evaluation with their targets, and scales in proportion to the
+// signal furthest above target. It grows as far as needed at once but shrinks
+// by at most half per step.
+type Autoscaler struct {
+	pool     *Pool
+	depth    func() int
+	config   AutoscaleConfig
+	observer ScaleObserver
+	logger   *zap.Logger
+	now      func() time.Time
+
+	mu           sync.Mutex
+	latencySum   time.Duration
+	latencyCount int
+	lastScale    time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewAutoscaler creates an autoscaler for pool. depth reports the number of
+// queued events, or is nil when the queue cannot tell. Register the
+// autoscaler with pool.Observe so it sees event latencies.
+func NewAutoscaler(pool *Pool, depth func() int, config AutoscaleConfig, logger *zap.Logger) *Autoscaler {
+	return &Autoscaler{
+		pool:   pool,
+		depth:  depth,
+		config: config,
+		logger: logger,
+		now:    time.Now,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// OnScale registers an observer for resizes, such as metrics. It must be set
+// before Start.
+func (a *Autoscaler) OnScale(observer ScaleObserver) {
+	a.observer = observer
+}
+
+// ObserveEvent records how long the event took from submission to save.
+func (a *Autoscaler) ObserveEvent(workerID int, event *domain.Event, latency time.Duration, err error) {
+	if latency <= 0 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.latencySum += latency
+	a.latencyCount++
+}
+
+func (a *Autoscaler) Start() {
+	a.logger.Info("Starting autoscaler",
+		zap.Int("min_workers", a.config.Min),
+		zap.Int("max_workers", a.config.Max))
+
+	go func() {
+		defer close(a.done)
+
+		ticker := time.NewTicker(a.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-a.stop:
+				return
+			case <-ticker.C:
+				a.Evaluate()
+			}
+		}
+	}()
+}
+
+func (a *Autoscaler) Stop() {
+	close(a.stop)
+	<-a.done
+	a.logger.Info("Autoscaler stopped")
+}
+
+// Evaluate decides on and applies a new pool size. It does nothing while the
+// pool is paused, since the signals then say nothing about capacity.
+func (a *Autoscaler) Evaluate() Decision {
+	current := a.pool.Size()
+	decision := Decision{From: current, To: current}
+
+	latency, samples := a.takeLatency()
+	decision.Latency = latency
+	if a.pool.Paused() {
+		return decision
+	}
+
+	ratio, reason := 0.0, ReasonIdle
+	if a.depth != nil && a.config.TargetQueueDepth > 0 {
+		decision.QueueDepth = a.depth()
+		if r := float64(decision.QueueDepth) / (a.config.TargetQueueDepth * float64(current)); r > ratio {
+			ratio, reason = r, ReasonQueueDepth
+		}
+	}
+	if samples > 0 && a.config.TargetLatency > 0 {
+		if r := float64(latency) / float64(a.config.TargetLatency); r > ratio {
+			ratio, reason = r, ReasonLatency
+		}
+	}
+
+	// Grow as far as the signal asks, but shrink by at most half.
+	target := current
+	if math.Abs(ratio-1) > tolerance {
+		target = max(int(math.Ceil(float64(current)*ratio)), (current+1)/2)
+	}
+	desired := min(max(target, a.config.Min), a.config.Max)
+	if desired == current {
+		return decision
+	}
+	// The pool may sit outside the bounds after an admin resize.
+	if target == current || (desired > current) != (target > current) {
+		reason = ReasonBounds
+	}
+
+	a.mu.Lock()
+	cooldown := a.config.ScaleUpCooldown
+	if desired < current {
+		cooldown = a.config.ScaleDownCooldown
+	}
+	now := a.now()
+	if !a.lastScale.IsZero() && now.Sub(a.lastScale) < cooldown {
+		a.mu.Unlock()
+		a.logger.Debug("Autoscaler waiting for cooldown",
+			zap.Int("workers", current),
+			zap.Int("desired_workers", desired),
+			zap.String("reason", reason))
+		return decision
+	}
+	a.lastScale = now
+	a.mu.Unlock()
+
+	if err := a.pool.Resize(desired); err != nil {
+		a.logger.Error("Failed to resize worker pool", zap.Int("desired_workers", desired), zap.Error(err))
+		return decision
+	}
+
+	decision.To = desired
+	decision.Reason = reason
+	a.logger.Info("Autoscaler resized worker pool",
+		zap.Int("from", current),
+		zap.Int("to", desired),
+		zap.String("reason", reason),
+		zap.Int("queue_depth", decision.QueueDepth),
+		zap.Duration("avg_latency", latency))
+	if a.observer != nil {
+		a.observer.ObserveScale(current, desired, reason)
+	}
+	return decision
+}
+
+// takeLatency returns the average latency since the last call and resets it.
+func (a *Autoscaler) takeLatency() (time.Duration, int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	count := a.latencyCount
+	if count == 0 {
+		return 0, 0
+	}
+	avg := a.latencySum / time.Duration(count)
+	a.latencySum, a.latencyCount = 0, 0
+	return avg, count
+}
@@ -0,0 +1,132 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/queue"
+	"github.com/raufhm/vfc/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type scaleRecorder []string
+
+func (r *scaleRecorder) ObserveScale(from, to int, reason string) {
+	*r = append(*r, reason)
+}
+
+// setupAutoscaler returns an autoscaler for an unstarted pool of size
+// workers, with a queue depth the test controls and a clock it advances.
+func setupAutoscaler(size int, config AutoscaleConfig) (*Autoscaler, *int, *time.Time) {
+	logger, _ := zap.NewDevelopment()
+	pool := NewPool(size, queue.NewInMemoryQueue(10, logger), repository.NewInMemoryRepository(), logger)
+
+	depth := 0
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := NewAutoscaler(pool, func() int { return depth }, config, logger)
+	a.now = func() time.Time { return now }
+	return a, &depth, &now
+}
+
+var testAutoscaleConfig = AutoscaleConfig{
+	Min:               2,
+	Max:               10,
+	TargetQueueDepth:  5,
+	TargetLatency:     100 * time.Millisecond,
+	ScaleUpCooldown:   10 * time.Second,
+	ScaleDownCooldown: time.Minute,
+}
+
+func TestAutoscaler_GrowsWithQueueDepth(t *testing.T) {
+	a, depth, _ := setupAutoscaler(2, testAutoscaleConfig)
+	var recorded scaleRecorder
+	a.OnScale(&recorded)
+
+	// 30 queued events for 2 workers is three times the target of 5 each.
+	*depth = 30
+	d := a.Evaluate()
+	assert.Equal(t, 6, d.To)
+	assert.Equal(t, ReasonQueueDepth, d.Reason)
+	assert.Equal(t, 6, a.pool.Size())
+	assert.Equal(t, scaleRecorder{ReasonQueueDepth}, recorded)
+}
+
+func TestAutoscaler_GrowsWithLatency(t *testing.T) {
+	a, depth, _ := setupAutoscaler(4, testAutoscaleConfig)
+	*depth = 20
+
+	a.ObserveEvent(1, domain.NewEvent("abc", 1, 1), 200*time.Millisecond, nil)
+
+	d := a.Evaluate()
+	assert.Equal(t, 8, d.To)
+	assert.Equal(t, ReasonLatency, d.Reason)
+	assert.Equal(t, 200*time.Millisecond, d.Latency)
+}
+
+func TestAutoscaler_RespectsBounds(t *testing.T) {
+	a, depth, _ := setupAutoscaler(4, testAutoscaleConfig)
+
+	*depth = 1000
+	assert.Equal(t, 10, a.Evaluate().To)
+
+	a, _, _ = setupAutoscaler(3, testAutoscaleConfig)
+	assert.Equal(t, 2, a.Evaluate().To)
+}
+
+func TestAutoscaler_ShrinksByHalfAtMost(t *testing.T) {
+	a, _, now := setupAutoscaler(10, testAutoscaleConfig)
+
+	d := a.Evaluate()
+	assert.Equal(t, 5, d.To)
+	assert.Equal(t, ReasonIdle, d.Reason)
+
+	*now = now.Add(time.Minute)
+	assert.Equal(t, 3, a.Evaluate().To)
+}
+
+func TestAutoscaler_WaitsForCooldown(t *testing.T) {
+	a, depth, now := setupAutoscaler(2, testAutoscaleConfig)
+
+	*depth = 20
+	assert.Equal(t, 4, a.Evaluate().To)
+
+	*depth = 40
+	assert.Equal(t, 4, a.Evaluate().To, "scale-up cooldown")
+	*now = now.Add(10 * time.Second)
+	assert.Equal(t, 8, a.Evaluate().To)
+
+	*depth = 0
+	*now = now.Add(30 * time.Second)
+	assert.Equal(t, 8, a.Evaluate().To, "scale-down cooldown")
+	*now = now.Add(30 * time.Second)
+	assert.Equal(t, 4, a.Evaluate().To)
+}
+
+func TestAutoscaler_HoldsWithinTolerance(t *testing.T) {
+	a, depth, _ := setupAutoscaler(4, testAutoscaleConfig)
+
+	*depth = 21
+	d := a.Evaluate()
+	assert.Equal(t, 4, d.To)
+	assert.Empty(t, d.Reason)
+}
+
+func TestAutoscaler_IgnoresPausedPool(t *testing.T) {
+	a, depth, _ := setupAutoscaler(2, testAutoscaleConfig)
+	a.pool.Pause()
+
+	*depth = 100
+	assert.Equal(t, 2, a.Evaluate().To)
+}
+
+func TestAutoscaler_ReturnsToBoundsAfterManualResize(t *testing.T) {
+	a, depth, _ := setupAutoscaler(4, testAutoscaleConfig)
+	a.pool.Resize(20)
+
+	*depth = 100
+	d := a.Evaluate()
+	assert.Equal(t, 10, d.To)
+	assert.Equal(t, ReasonBounds, d.Reason)
+}
@@ -0,0 +1,284 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidSize = errors.New("worker pool size must be at least 1")
+	ErrPoolStopped = errors.New("worker pool is stopped or draining")
+)
+
+// WorkerState is what a worker is doing right now.
+type WorkerState string
+
+const (
+	StateIdle       WorkerState = "idle"
+	StateProcessing WorkerState = "processing"
+	StatePaused     WorkerState = "paused"
+	// StateRestarting workers are waiting out the backoff after a panic.
+	StateRestarting WorkerState = "restarting"
+	// StateRetiring workers finish their current event and exit after the
+	// pool has been shrunk.
+	StateRetiring WorkerState = "retiring"
+)
+
+// LastEvent describes the most recent event a worker finished.
+type LastEvent struct {
+	ProductID   string    `json:"product_id"`
+	RequestID   string    `json:"request_id,omitempty"`
+	ProcessedAt time.Time `json:"processed_at"`
+	Error       string    `json:"error,omitempty"`
+}
+
+type WorkerStatus struct {
+	ID        int         `json:"id"`
+	State     WorkerState `json:"state"`
+	Processed int64       `json:"processed"`
+	Failed    int64       `json:"failed"`
+	Restarts  int         `json:"restarts"`
+	LastEvent *LastEvent  `json:"last_event,omitempty"`
+}
+
+// Status is a snapshot of the pool for the admin API.
+type Status struct {
+	Paused  bool           `json:"paused"`
+	Size    int            `json:"size"`
+	Running int            `json:"running"`
+	Workers []WorkerStatus `json:"workers"`
+}
+
+type workerState struct {
+	id     int
+	retire chan struct{}
+
+	mu        sync.Mutex
+	state     WorkerState
+	retiring  bool
+	processed int64
+	failed    int64
+	restarts  int
+	last      *LastEvent
+}
+
+func (w *workerState) setState(state WorkerState) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.state = state
+}
+
+func (w *workerState) restarting() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.state = StateRestarting
+	w.restarts++
+}
+
+func (w *workerState) record(event *domain.Event, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.processed++
+	w.last = &LastEvent{
+		ProductID:   event.ProductID,
+		RequestID:   event.RequestID,
+		ProcessedAt: time.Now(),
+	}
+	if err != nil {
+		w.failed++
+		w.last.Error = err.Error()
+	}
+}
+
+func (w *workerState) status() WorkerStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	s := WorkerStatus{
+		ID:        w.id,
+		State:     w.state,
+		Processed: w.processed,
+		Failed:    w.failed,
+		Restarts:  w.restarts,
+	}
+	if w.retiring {
+		s.State = StateRetiring
+	}
+	if w.last != nil {
+		last := *w.last
+		s.LastEvent = &last
+	}
+	return s
+}
+
+// Pause stops workers from taking new events. Events already being processed
+// finish, and producers can keep enqueuing; broker-backed queues hold the
+// backlog, while enqueuing to a full in-memory queue blocks until resumed.
+func (p *Pool) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused {
+		return
+	}
+	p.paused = true
+	close(p.pauseCh)
+	p.resumeCh = make(chan struct{})
+	p.logger.Info("Worker pool paused")
+}
+
+// Resume lets paused workers take events again.
+func (p *Pool) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resume()
+}
+
+// resume is Resume with p.mu held.
+func (p *Pool) resume() {
+	if !p.paused {
+		return
+	}
+	p.paused = false
+	close(p.resumeCh)
+	p.pauseCh = make(chan struct{})
+	p.logger.Info("Worker pool resumed")
+}
+
+// Drain lets the workers process every event already queued and then stops
+// the pool. Producers must be stopped first, or the pool may never empty. If
+// ctx ends before the queue does, the pool is stopped anyway and ctx.Err() is
+// returned; the events left are still in the queue. A paused pool is resumed
+// so that it can drain.
+func (p *Pool) Drain(ctx context.Context) error {
+	p.mu.Lock()
+	p.logger.Info("Draining worker pool", zap.Int("workers", len(p.workers)))
+	p.resume()
+	if !p.draining {
+		p.draining = true
+		close(p.drainCh)
+	}
+	p.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		p.logger.Info("Worker pool drained")
+		p.Stop()
+		return nil
+	case <-ctx.Done():
+		p.logger.Warn("Drain deadline passed, stopping worker pool", zap.Error(ctx.Err()))
+		p.Stop()
+		return ctx.Err()
+	}
+}
+
+// Paused reports whether the pool is paused.
+func (p *Pool) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// Resize changes the number of workers. New workers start at once; surplus
+// workers, newest first, finish their current event and exit.
+func (p *Pool) Resize(size int) error {
+	if size < 1 {
+		return ErrInvalidSize
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ctx.Err() != nil || p.draining {
+		return ErrPoolStopped
+	}
+
+	previous := p.workerCount
+	p.workerCount = size
+	if !p.started {
+		return nil
+	}
+
+	active := p.active()
+	for i := len(active); i < size; i++ {
+		p.spawn()
+	}
+	for _, w := range active[min(size, len(active)):] {
+		w.mu.Lock()
+		w.retiring = true
+		w.mu.Unlock()
+		close(w.retire)
+	}
+
+	p.logger.Info("Worker pool resized", zap.Int("from", previous), zap.Int("to", size))
+	return nil
+}
+
+// Status reports the pool and each of its workers, ordered by worker ID.
+func (p *Pool) Status() Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := Status{
+		Paused:  p.paused,
+		Size:    p.workerCount,
+		Running: p.Running(),
+		Workers: make([]WorkerStatus, 0, len(p.workers)),
+	}
+	for _, w := range p.sorted() {
+		status.Workers = append(status.Workers, w.status())
+	}
+	return status
+}
+
+// gates returns the channels a worker waits on, each closed when the pool
+// pauses, resumes or starts draining.
+func (p *Pool) gates() (paused, resumed, drain <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pauseCh, p.resumeCh, p.drainCh
+}
+
+func (p *Pool) remove(w *workerState) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.workers, w.id)
+}
+
+// active returns the workers that are not retiring, oldest first. p.mu must
+// be held.
+func (p *Pool) active() []*workerState {
+	var active []*workerState
+	for _, w := range p.sorted() {
+		w.mu.Lock()
+		retiring := w.retiring
+		w.mu.Unlock()
+		if !retiring {
+			active = append(active, w)
+		}
+	}
+	return active
+}
+
+// sorted returns every worker by ID. p.mu must be held.
+func (p *Pool) sorted() []*workerState {
+	workers := make([]*workerState, 0, len(p.workers))
+	for _, w := range p.workers {
+		workers = append(workers, w)
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].id < workers[j].id })
+	return workers
+}
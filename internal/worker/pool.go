@@ -2,96 +2,443 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/raufhm/vfc/internal/domain"
 	"github.com/raufhm/vfc/internal/queue"
 	"github.com/raufhm/vfc/internal/repository"
+	"github.com/raufhm/vfc/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+// SaveHook is called after a worker has successfully saved a product.
+type SaveHook func(change *domain.ProductChange)
+
+// Observer is told the outcome of every event a worker processes. latency is
+// the time from the event's timestamp until the repository save returned,
+// before any save hooks ran; it is zero when the event has no timestamp or
+// never reached the save.
+type Observer interface {
+	ObserveEvent(workerID int, event *domain.Event, latency time.Duration, err error)
+}
+
 type Pool struct {
+	queue     queue.QueueProvider
+	repo      repository.ProductRepository
+	outbox    repository.OutboxRepository
+	hooks     []SaveHook
+	observers []Observer
+	running   atomic.Int32
+	logger    *zap.Logger
+	wg        sync.WaitGroup
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	// changeID numbers changes when there is no outbox to do it.
+	changeID atomic.Uint64
+
+	// mu guards the worker set and the pause state, which the admin API
+	// changes while the pool runs.
+	mu          sync.Mutex
 	workerCount int
-	queue       queue.QueueProvider
-	repo        repository.ProductRepository
-	logger      *zap.Logger
-	wg          sync.WaitGroup
-	ctx         context.Context
-	cancel      context.CancelFunc
+	workers     map[int]*workerState
+	started     bool
+	paused      bool
+	pauseCh     chan struct{} // closed while paused
+	resumeCh    chan struct{} // closed while consuming
+	draining    bool
+	drainCh     chan struct{} // closed once Drain is called
+
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	eventTimeout time.Duration
+
+	abandonedMu sync.Mutex
+	abandoned   []*domain.Event
 }
 
 func NewPool(workerCount int, queue queue.QueueProvider, repo repository.ProductRepository, logger *zap.Logger) *Pool {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &Pool{
+	resumeCh := make(chan struct{})
+	close(resumeCh)
+	p := &Pool{
 		workerCount: workerCount,
 		queue:       queue,
 		repo:        repo,
 		logger:      logger,
 		ctx:         ctx,
 		cancel:      cancel,
+		workers:     make(map[int]*workerState),
+		pauseCh:     make(chan struct{}),
+		resumeCh:    resumeCh,
+		drainCh:     make(chan struct{}),
+		minBackoff:  DefaultMinBackoff,
+		maxBackoff:  DefaultMaxBackoff,
 	}
+	// Starting from the clock keeps change IDs unique across restarts.
+	p.changeID.Store(uint64(time.Now().UnixNano()))
+	return p
+}
+
+// UseOutbox makes workers save products through the outbox repository so
+// each save also records a ProductChanged entry for the relay to publish.
+func (p *Pool) UseOutbox(outbox repository.OutboxRepository) {
+	p.outbox = outbox
+}
+
+// RestartBackoff sets how long a worker waits before restarting after a
+// panic. The wait starts at initial and doubles, up to limit, while the
+// worker keeps panicking. It must be set before Start.
+func (p *Pool) RestartBackoff(initial, limit time.Duration) {
+	p.minBackoff = initial
+	p.maxBackoff = limit
+}
+
+// EventTimeout limits how long a worker may spend on one event. An event
+// that runs out of time fails like any other error; zero means no limit. It
+// must be set before Start.
+func (p *Pool) EventTimeout(timeout time.Duration) {
+	p.eventTimeout = timeout
+}
+
+// OnSave registers a hook to run after every successful save. Hooks must be
+// registered before Start and should return quickly.
+func (p *Pool) OnSave(hook SaveHook) {
+	p.hooks = append(p.hooks, hook)
+}
+
+// Observe registers an observer for processing outcomes, such as metrics or
+// the autoscaler. Observers must be registered before Start.
+func (p *Pool) Observe(observer Observer) {
+	p.observers = append(p.observers, observer)
 }
 
 func (p *Pool) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	p.logger.Info("Starting worker pool", zap.Int("worker_count", p.workerCount))
 
+	p.started = true
 	for i := 0; i < p.workerCount; i++ {
-		p.wg.Add(1)
-		go p.worker(i + 1)
+		p.spawn()
 	}
 }
 
 func (p *Pool) Stop() {
 	p.logger.Info("Stopping worker pool")
+
+	// Cancel under the lock so Resize cannot add workers once Wait starts.
+	p.mu.Lock()
 	p.cancel()
+	p.mu.Unlock()
+
 	p.wg.Wait()
 	p.logger.Info("Worker pool stopped")
 }
 
-func (p *Pool) worker(id int) {
+// Size returns the number of workers the pool is meant to run.
+func (p *Pool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.workerCount
+}
+
+// Running returns the number of workers currently consuming events.
+func (p *Pool) Running() int {
+	return int(p.running.Load())
+}
+
+// spawn starts a worker with the lowest free ID. Reusing the IDs of workers
+// that have exited keeps them, and the metrics labelled with them, bounded
+// by the pool size. p.mu must be held.
+func (p *Pool) spawn() {
+	id := 1
+	for p.workers[id] != nil {
+		id++
+	}
+	w := &workerState{id: id, state: StateIdle, retire: make(chan struct{})}
+	p.workers[w.id] = w
+
+	p.wg.Add(1)
+	go p.worker(w)
+}
+
+// worker supervises one worker: it runs the event loop and, if processing
+// panics, fails the event and restarts the loop after a backoff.
+func (p *Pool) worker(w *workerState) {
 	defer p.wg.Done()
-	p.logger.Info("Worker started", zap.Int("worker_id", id))
+	p.running.Add(1)
+	defer p.running.Add(-1)
+	defer p.remove(w)
+	p.logger.Info("Worker started", zap.Int("worker_id", w.id))
+
+	backoff := p.minBackoff
+	for {
+		c, handled := p.run(w)
+		if c == nil {
+			return
+		}
+
+		p.fail(w, c)
+
+		// Only back off further when the worker keeps crashing without
+		// finishing anything in between, such as on a poison event.
+		if handled > 0 {
+			backoff = p.minBackoff
+		}
+		w.restarting()
+		p.logger.Warn("Restarting worker", zap.Int("worker_id", w.id), zap.Duration("backoff", backoff))
+
+		// A drain restarts the worker at once, so it neither waits out the
+		// backoff nor leaves the queue undrained.
+		_, _, drain := p.gates()
+		select {
+		case <-p.ctx.Done():
+			p.logger.Info("Worker stopping", zap.Int("worker_id", w.id))
+			return
+		case <-w.retire:
+			p.logger.Info("Worker retired", zap.Int("worker_id", w.id))
+			return
+		case <-drain:
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, p.maxBackoff)
+	}
+}
 
+// run consumes events until the worker should exit, returning nil, or until
+// processing an event panics. It also returns how many events it handled.
+func (p *Pool) run(w *workerState) (*crash, int) {
 	eventChan := p.queue.GetChannel()
+	handled := 0
 
 	for {
+		event, ok := p.next(w, eventChan)
+		if !ok {
+			return nil, handled
+		}
+
+		if c := p.handle(w, event); c != nil {
+			return c, handled
+		}
+		handled++
+	}
+}
+
+// next waits for the worker's next event. It returns false when the worker
+// should exit: the pool is stopping, the worker is retired, the queue is
+// closed, or the pool is draining and the queue is empty.
+func (p *Pool) next(w *workerState, events <-chan *domain.Event) (*domain.Event, bool) {
+	for {
+		// A retiring worker must not take another event, even if one is
+		// ready in the same select.
 		select {
 		case <-p.ctx.Done():
-			p.logger.Info("Worker stopping", zap.Int("worker_id", id))
-			return
-		case event, ok := <-eventChan:
-			if !ok {
-				p.logger.Info("Queue closed", zap.Int("worker_id", id))
-				return
+			p.logger.Info("Worker stopping", zap.Int("worker_id", w.id))
+			return nil, false
+		case <-w.retire:
+			p.logger.Info("Worker retired", zap.Int("worker_id", w.id))
+			return nil, false
+		default:
+		}
+
+		paused, resumed, drain := p.gates()
+
+		// While draining, take whatever is left without waiting for more.
+		select {
+		case <-drain:
+			select {
+			case event, ok := <-events:
+				if !ok {
+					p.logger.Info("Queue closed", zap.Int("worker_id", w.id))
+					return nil, false
+				}
+				if event == nil {
+					continue
+				}
+				return event, true
+			default:
+				p.logger.Info("Worker drained", zap.Int("worker_id", w.id))
+				return nil, false
 			}
+		default:
+		}
 
-			if event == nil {
-				continue
+		select {
+		case <-resumed:
+		default:
+			w.setState(StatePaused)
+			select {
+			case <-p.ctx.Done():
+				p.logger.Info("Worker stopping", zap.Int("worker_id", w.id))
+				return nil, false
+			case <-w.retire:
+				p.logger.Info("Worker retired", zap.Int("worker_id", w.id))
+				return nil, false
+			case <-resumed:
 			}
+		}
+		w.setState(StateIdle)
 
-			p.processEvent(id, event)
+		select {
+		case <-p.ctx.Done():
+			p.logger.Info("Worker stopping", zap.Int("worker_id", w.id))
+			return nil, false
+		case <-w.retire:
+			p.logger.Info("Worker retired", zap.Int("worker_id", w.id))
+			return nil, false
+		case <-paused:
+		case <-drain:
+		case event, ok := <-events:
+			if !ok {
+				p.logger.Info("Queue closed", zap.Int("worker_id", w.id))
+				return nil, false
+			}
+			if event != nil {
+				return event, true
+			}
 		}
 	}
 }
 
-func (p *Pool) processEvent(workerID int, event *domain.Event) {
-	p.logger.Info("Processing event",
-		zap.Int("worker_id", workerID),
-		zap.String("product_id", event.ProductID))
+// Abandoned returns the events whose processing was cut short by Stop, so
+// that they can be kept rather than lost. Call it after Stop returns.
+func (p *Pool) Abandoned() []*domain.Event {
+	p.abandonedMu.Lock()
+	defer p.abandonedMu.Unlock()
+	return append([]*domain.Event(nil), p.abandoned...)
+}
+
+// finish records the outcome of an event and settles it with the queue.
+func (p *Pool) finish(w *workerState, event *domain.Event, latency time.Duration, err error) {
+	if errors.Is(err, context.Canceled) && p.ctx.Err() != nil {
+		p.abandonedMu.Lock()
+		p.abandoned = append(p.abandoned, event)
+		p.abandonedMu.Unlock()
+	}
+
+	w.record(event, err)
+	for _, observer := range p.observers {
+		observer.ObserveEvent(w.id, event, latency, err)
+	}
+	p.settle(w.id, event, err)
+}
+
+func (p *Pool) processEvent(workerID int, event *domain.Event) (latency time.Duration, err error) {
+	// Processing is its own trace, linked to the request that enqueued the
+	// event, so the request's trace ends when the request does. The
+	// queue.wait_ms attribute is the time spent waiting in the queue.
+	ctx, span := tracing.Tracer().Start(p.ctx, "process product update",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(tracing.Extract(p.ctx, event))),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("product.id", event.ProductID),
+			attribute.Int("worker.id", workerID),
+			attribute.Int64("queue.wait_ms", time.Since(event.Timestamp).Milliseconds()),
+		))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "processing failed")
+		}
+		span.End()
+	}()
+
+	if p.eventTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.eventTimeout)
+		defer cancel()
+	}
+
+	log := p.eventLogger(workerID, event)
+	log.Info("Processing event", zap.String("principal", event.Principal))
 
 	product := event.ToProduct()
 
-	if err := p.repo.Save(product); err != nil {
-		p.logger.Error("Failed to save product",
-			zap.Int("worker_id", workerID),
-			zap.String("product_id", product.ProductID),
-			zap.Error(err))
+	change, err := p.save(ctx, product)
+	if !event.Timestamp.IsZero() {
+		latency = time.Since(event.Timestamp)
+	}
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Error("Failed to save product in time", zap.Duration("timeout", p.eventTimeout), zap.Error(err))
+		} else {
+			log.Error("Failed to save product", zap.Error(err))
+		}
+		return latency, err
+	}
+
+	log.Info("Product updated successfully",
+		zap.Float64("price", product.Price),
+		zap.Int("stock", product.Stock),
+		zap.String("principal", event.Principal))
+
+	for _, hook := range p.hooks {
+		hook(change)
+	}
+
+	return latency, nil
+}
+
+func (p *Pool) save(ctx context.Context, product *domain.Product) (*domain.ProductChange, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "save product")
+	defer span.End()
+
+	if p.outbox != nil {
+		return p.outbox.SaveWithOutbox(ctx, product)
+	}
+
+	changeType := domain.ChangeUpdated
+	if len(p.hooks) > 0 {
+		if _, err := p.repo.Get(ctx, product.ProductID); errors.Is(err, repository.ErrProductNotFound) {
+			changeType = domain.ChangeCreated
+		}
+	}
+
+	if err := p.repo.Save(ctx, product); err != nil {
+		return nil, err
+	}
+	change := domain.NewProductChange(changeType, product)
+	change.ID = p.changeID.Add(1)
+	return change, nil
+}
+
+// settle reports the processing outcome to queues that track deliveries.
+func (p *Pool) settle(workerID int, event *domain.Event, processErr error) {
+	acker, ok := p.queue.(queue.Acknowledger)
+	if !ok {
 		return
 	}
 
-	p.logger.Info("Product updated successfully",
+	var err error
+	if processErr != nil {
+		err = acker.Nack(event)
+	} else {
+		err = acker.Ack(event)
+	}
+
+	if err != nil {
+		p.eventLogger(workerID, event).Error("Failed to acknowledge event", zap.Error(err))
+	}
+}
+
+// eventLogger returns a logger tagged with the worker, the product and the
+// ID of the request that submitted the event.
+func (p *Pool) eventLogger(workerID int, event *domain.Event) *zap.Logger {
+	fields := []zap.Field{
 		zap.Int("worker_id", workerID),
-		zap.String("product_id", product.ProductID),
-		zap.Float64("price", product.Price),
-		zap.Int("stock", product.Stock))
+		zap.String("product_id", event.ProductID),
+	}
+	if event.RequestID != "" {
+		fields = append(fields, zap.String("request_id", event.RequestID))
+	}
+	return p.logger.With(fields...)
 }
@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/raufhm/vfc/internal/domain"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// PanicError is the failure recorded for an event whose processing panicked.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// PanicObserver is implemented by observers that also count worker panics.
+type PanicObserver interface {
+	ObservePanic(workerID int)
+}
+
+// crash is an event whose processing panicked.
+type crash struct {
+	event *domain.Event
+	err   *PanicError
+}
+
+// handle processes one event and settles it. A panic while processing,
+// including in save hooks, becomes a crash, which the caller settles through
+// fail instead, so every event is settled exactly once.
+func (p *Pool) handle(w *workerState, event *domain.Event) *crash {
+	w.setState(StateProcessing)
+	c, latency, err := p.recoverProcess(w.id, event)
+	if c != nil {
+		return c
+	}
+	p.finish(w, event, latency, err)
+	return nil
+}
+
+// recoverProcess runs processEvent, turning a panic into a crash.
+func (p *Pool) recoverProcess(workerID int, event *domain.Event) (c *crash, latency time.Duration, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			c = &crash{event: event, err: &PanicError{Value: v, Stack: debug.Stack()}}
+		}
+	}()
+	latency, err = p.processEvent(workerID, event)
+	return nil, latency, err
+}
+
+// fail logs a crash with the event that caused it and settles the event as
+// failed, so broker-backed queues retry or dead-letter it.
+func (p *Pool) fail(w *workerState, c *crash) {
+	p.eventLogger(w.id, c.event).Error("Worker panicked",
+		zap.Any("panic", c.err.Value),
+		zap.String("stack", string(c.err.Stack)),
+		zap.Any("event", c.event))
+
+	for _, observer := range p.observers {
+		if po, ok := observer.(PanicObserver); ok {
+			po.ObservePanic(w.id)
+		}
+	}
+	p.finish(w, c.event, 0, c.err)
+}
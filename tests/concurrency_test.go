@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"testing"
@@ -24,7 +25,7 @@ func TestConcurrentWrites(t *testing.T) {
 			for j := 0; j < 10; j++ {
 				productID := fmt.Sprintf("product-%d", j)
 				product := domain.NewProduct(productID, float64(id*10+j), id*10+j)
-				err := repo.Save(product)
+				err := repo.Save(context.Background(), product)
 				require.NoError(t, err)
 			}
 		}(i)
@@ -34,7 +35,7 @@ func TestConcurrentWrites(t *testing.T) {
 
 	for i := 0; i < 10; i++ {
 		productID := fmt.Sprintf("product-%d", i)
-		product, err := repo.Get(productID)
+		product, err := repo.Get(context.Background(), productID)
 		require.NoError(t, err)
 		assert.Equal(t, productID, product.ProductID)
 	}
@@ -46,7 +47,7 @@ func TestConcurrentReads(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		productID := fmt.Sprintf("product-%d", i)
 		product := domain.NewProduct(productID, float64(i)*10, i*100)
-		err := repo.Save(product)
+		err := repo.Save(context.Background(), product)
 		require.NoError(t, err)
 	}
 
@@ -59,7 +60,7 @@ func TestConcurrentReads(t *testing.T) {
 			defer wg.Done()
 			for j := 0; j < 10; j++ {
 				productID := fmt.Sprintf("product-%d", j)
-				product, err := repo.Get(productID)
+				product, err := repo.Get(context.Background(), productID)
 				require.NoError(t, err)
 				assert.Equal(t, productID, product.ProductID)
 			}
@@ -75,7 +76,7 @@ func TestMixedReadWrite(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		productID := fmt.Sprintf("product-%d", i)
 		product := domain.NewProduct(productID, float64(i)*10, i*100)
-		err := repo.Save(product)
+		err := repo.Save(context.Background(), product)
 		require.NoError(t, err)
 	}
 
@@ -88,7 +89,7 @@ func TestMixedReadWrite(t *testing.T) {
 			for j := 0; j < 20; j++ {
 				productID := fmt.Sprintf("product-%d", j%10)
 				product := domain.NewProduct(productID, float64(id*20+j), id*20+j)
-				err := repo.Save(product)
+				err := repo.Save(context.Background(), product)
 				require.NoError(t, err)
 			}
 		}(i)
@@ -100,7 +101,7 @@ func TestMixedReadWrite(t *testing.T) {
 			defer wg.Done()
 			for j := 0; j < 20; j++ {
 				productID := fmt.Sprintf("product-%d", j%10)
-				product, err := repo.Get(productID)
+				product, err := repo.Get(context.Background(), productID)
 				require.NoError(t, err)
 				assert.NotNil(t, product)
 			}
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raufhm/vfc/internal/handler"
+	"github.com/raufhm/vfc/internal/queue"
+	"github.com/raufhm/vfc/internal/repository"
+	"github.com/raufhm/vfc/internal/service"
+	"github.com/raufhm/vfc/internal/tracing"
+	"github.com/raufhm/vfc/internal/worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func TestTracePropagatesThroughQueue(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.Install(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(t.Context())
+
+	logger, _ := zap.NewDevelopment()
+	repo := repository.NewInMemoryRepository()
+	q := queue.NewInMemoryQueue(10, logger)
+	svc := service.NewProductService(repo, q)
+	pool := worker.NewPool(1, q, repo, logger)
+	pool.Start()
+	defer pool.Stop()
+
+	router := handler.SetupRouter(handler.NewProductHandler(svc, logger), logger, handler.NewTracer())
+
+	// The caller's own span, as an upstream gateway would send it.
+	const upstreamTrace = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const upstreamSpan = "00f067aa0ba902b7"
+	req := httptest.NewRequest("POST", "/events", strings.NewReader(`{"product_id":"abc","price":1,"stock":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-"+upstreamTrace+"-"+upstreamSpan+"-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusAccepted, rr.Code)
+
+	require.Eventually(t, func() bool { return len(exporter.GetSpans()) == 4 }, time.Second, 10*time.Millisecond)
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, s := range exporter.GetSpans() {
+		spans[s.Name] = s
+	}
+
+	server := spans["POST /events"]
+	producer := spans["enqueue product update"]
+	consumer := spans["process product update"]
+	save := spans["save product"]
+
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind)
+	assert.Equal(t, upstreamTrace, server.SpanContext.TraceID().String())
+	assert.Equal(t, upstreamSpan, server.Parent.SpanID().String())
+	assert.Equal(t, trace.SpanKindProducer, producer.SpanKind)
+	assert.Equal(t, upstreamTrace, producer.SpanContext.TraceID().String())
+	assert.Equal(t, server.SpanContext.SpanID(), producer.Parent.SpanID())
+
+	// Processing starts a new trace that links back to the producer.
+	assert.Equal(t, trace.SpanKindConsumer, consumer.SpanKind)
+	assert.False(t, consumer.Parent.IsValid())
+	assert.NotEqual(t, upstreamTrace, consumer.SpanContext.TraceID().String())
+	require.Len(t, consumer.Links, 1)
+	assert.Equal(t, producer.SpanContext.TraceID(), consumer.Links[0].SpanContext.TraceID())
+	assert.Equal(t, producer.SpanContext.SpanID(), consumer.Links[0].SpanContext.SpanID())
+	assert.Equal(t, consumer.SpanContext.TraceID(), save.SpanContext.TraceID())
+	assert.Equal(t, consumer.SpanContext.SpanID(), save.Parent.SpanID())
+}
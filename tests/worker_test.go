@@ -1,17 +1,21 @@
 package tests
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/raufhm/vfc/internal/domain"
+	"github.com/raufhm/vfc/internal/metrics"
 	"github.com/raufhm/vfc/internal/queue"
 	"github.com/raufhm/vfc/internal/repository"
 	"github.com/raufhm/vfc/internal/worker"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestWorkerPoolProcessing(t *testing.T) {
@@ -24,14 +28,14 @@ func TestWorkerPoolProcessing(t *testing.T) {
 
 	for i := 0; i < 10; i++ {
 		event := domain.NewEvent("product-1", float64(i)*10, i*100)
-		err := q.Enqueue(event)
+		err := q.Enqueue(context.Background(), event)
 		require.NoError(t, err)
 	}
 
 	time.Sleep(500 * time.Millisecond)
 	pool.Stop()
 
-	product, err := repo.Get("product-1")
+	product, err := repo.Get(context.Background(), "product-1")
 	require.NoError(t, err)
 	assert.Equal(t, "product-1", product.ProductID)
 }
@@ -47,7 +51,7 @@ func TestMultipleProducts(t *testing.T) {
 	for i := 0; i < 20; i++ {
 		productID := fmt.Sprintf("product-%d", i)
 		event := domain.NewEvent(productID, float64(i)*10, i*100)
-		err := q.Enqueue(event)
+		err := q.Enqueue(context.Background(), event)
 		require.NoError(t, err)
 	}
 
@@ -56,7 +60,7 @@ func TestMultipleProducts(t *testing.T) {
 
 	for i := 0; i < 20; i++ {
 		productID := fmt.Sprintf("product-%d", i)
-		product, err := repo.Get(productID)
+		product, err := repo.Get(context.Background(), productID)
 		require.NoError(t, err)
 		assert.Equal(t, productID, product.ProductID)
 		assert.Equal(t, float64(i)*10, product.Price)
@@ -74,7 +78,7 @@ func TestGracefulShutdown(t *testing.T) {
 
 	for i := 0; i < 5; i++ {
 		event := domain.NewEvent("test", float64(i), i)
-		err := q.Enqueue(event)
+		err := q.Enqueue(context.Background(), event)
 		require.NoError(t, err)
 	}
 
@@ -93,3 +97,637 @@ func TestGracefulShutdown(t *testing.T) {
 		t.Fatal("Worker pool did not shut down within timeout")
 	}
 }
+
+func TestWorkerPoolReportsRunningWorkers(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	q := queue.NewInMemoryQueue(10, logger)
+	pool := worker.NewPool(3, q, repository.NewInMemoryRepository(), logger)
+	assert.Equal(t, 3, pool.Size())
+	assert.Equal(t, 0, pool.Running())
+
+	pool.Start()
+	require.Eventually(t, func() bool { return pool.Running() == 3 }, time.Second, 10*time.Millisecond)
+
+	pool.Stop()
+	assert.Equal(t, 0, pool.Running())
+}
+
+func TestWorkerPoolPauseLeavesEventsQueued(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := repository.NewInMemoryRepository()
+	q := queue.NewInMemoryQueue(10, logger)
+	pool := worker.NewPool(2, q, repo, logger)
+	pool.Start()
+	defer pool.Stop()
+
+	pool.Pause()
+	require.Eventually(t, func() bool {
+		for _, w := range pool.Status().Workers {
+			if w.State != worker.StatePaused {
+				return false
+			}
+		}
+		return true
+	}, time.Second, 10*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent(fmt.Sprintf("p%d", i), 1, 1)))
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 3, q.Len())
+	assert.Equal(t, 0, repo.Count())
+
+	pool.Resume()
+	require.Eventually(t, func() bool { return repo.Count() == 3 }, time.Second, 10*time.Millisecond)
+}
+
+func TestWorkerPoolResize(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	q := queue.NewInMemoryQueue(10, logger)
+	pool := worker.NewPool(2, q, repository.NewInMemoryRepository(), logger)
+	pool.Start()
+	defer pool.Stop()
+
+	require.NoError(t, pool.Resize(4))
+	require.Eventually(t, func() bool { return pool.Running() == 4 }, time.Second, 10*time.Millisecond)
+
+	// Shrinking retires the newest workers.
+	require.NoError(t, pool.Resize(1))
+	require.Eventually(t, func() bool { return pool.Running() == 1 }, time.Second, 10*time.Millisecond)
+	status := pool.Status()
+	assert.Equal(t, 1, status.Size)
+	require.Len(t, status.Workers, 1)
+	assert.Equal(t, 1, status.Workers[0].ID)
+
+	// New workers reuse the lowest free ID, keeping metric labels bounded.
+	require.NoError(t, pool.Resize(3))
+	status = pool.Status()
+	require.Len(t, status.Workers, 3)
+	assert.Equal(t, []int{1, 2, 3}, []int{status.Workers[0].ID, status.Workers[1].ID, status.Workers[2].ID})
+
+	assert.ErrorIs(t, pool.Resize(0), worker.ErrInvalidSize)
+}
+
+func TestAutoscalerReportsDecisions(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	q := queue.NewInMemoryQueue(10, logger)
+	m := metrics.New()
+	pool := worker.NewPool(1, q, repository.NewInMemoryRepository(), logger)
+	m.WatchPool(pool.Size, pool.Running)
+	autoscaler := worker.NewAutoscaler(pool, q.Len, worker.AutoscaleConfig{
+		Min:              1,
+		Max:              4,
+		Interval:         time.Hour,
+		TargetQueueDepth: 3,
+	}, logger)
+	autoscaler.OnScale(m)
+	pool.Observe(autoscaler)
+
+	for i := 0; i < 9; i++ {
+		require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent(fmt.Sprintf("p%d", i), 1, 1)))
+	}
+
+	decision := autoscaler.Evaluate()
+	assert.Equal(t, 3, decision.To)
+	assert.Equal(t, worker.ReasonQueueDepth, decision.Reason)
+	assert.Equal(t, 3.0, gauge(t, m, "vfc_workers"))
+
+	pool.Start()
+	defer pool.Stop()
+	require.Eventually(t, func() bool { return gauge(t, m, "vfc_workers_running") == 3 }, time.Second, 10*time.Millisecond)
+
+	families, err := m.Registry().Gather()
+	require.NoError(t, err)
+	for _, f := range families {
+		if f.GetName() == "vfc_autoscaler_decisions_total" {
+			require.Len(t, f.GetMetric(), 1)
+			assert.Equal(t, 1.0, f.GetMetric()[0].GetCounter().GetValue())
+			return
+		}
+	}
+	t.Fatal("vfc_autoscaler_decisions_total not exported")
+}
+
+// panickingRepository panics when saving the product "poison".
+type panickingRepository struct {
+	*repository.InMemoryRepository
+}
+
+func (r panickingRepository) Save(ctx context.Context, product *domain.Product) error {
+	if product.ProductID == "poison" {
+		panic("corrupt index")
+	}
+	return r.InMemoryRepository.Save(ctx, product)
+}
+
+// ackingQueue records how the pool settles each event.
+type ackingQueue struct {
+	*queue.InMemoryQueue
+	mu     sync.Mutex
+	nacked []string
+}
+
+func (q *ackingQueue) Ack(event *domain.Event) error { return nil }
+
+func (q *ackingQueue) Nack(event *domain.Event) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nacked = append(q.nacked, event.ProductID)
+	return nil
+}
+
+func TestWorkerPoolRecoversFromPanics(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+	repo := repository.NewInMemoryRepository()
+	q := &ackingQueue{InMemoryQueue: queue.NewInMemoryQueue(10, logger)}
+	m := metrics.New()
+	pool := worker.NewPool(1, q, panickingRepository{repo}, logger)
+	pool.RestartBackoff(10*time.Millisecond, 100*time.Millisecond)
+	pool.Observe(m)
+	pool.Start()
+	defer pool.Stop()
+
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("poison", 1, 1)))
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("good", 2, 2)))
+
+	// The restarted worker keeps the pool at full strength.
+	require.Eventually(t, func() bool { return repo.Count() == 1 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, pool.Running())
+
+	status := pool.Status()
+	require.Len(t, status.Workers, 1)
+	assert.Equal(t, 1, status.Workers[0].Restarts)
+	assert.Equal(t, int64(1), status.Workers[0].Failed)
+
+	q.mu.Lock()
+	assert.Equal(t, []string{"poison"}, q.nacked)
+	q.mu.Unlock()
+
+	panics := logs.FilterMessage("Worker panicked").All()
+	require.Len(t, panics, 1)
+	fields := panics[0].ContextMap()
+	assert.Equal(t, "poison", fields["product_id"])
+	assert.Equal(t, "corrupt index", fields["panic"])
+	assert.Contains(t, fields["stack"], "panickingRepository.Save")
+
+	assert.Equal(t, 1.0, counter(t, m, "vfc_worker_panics_total"))
+	assert.Equal(t, 1.0, counter(t, m, "vfc_events_failed_total"))
+}
+
+func TestWorkerPoolSettlesPanickingEventOnce(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	q := &ackingQueue{InMemoryQueue: queue.NewInMemoryQueue(10, logger)}
+	m := metrics.New()
+	pool := worker.NewPool(1, q, repository.NewInMemoryRepository(), logger)
+	pool.RestartBackoff(10*time.Millisecond, 100*time.Millisecond)
+	pool.Observe(m)
+	pool.OnSave(func(change *domain.ProductChange) {
+		if change.Product.ProductID == "poison" {
+			panic("hook failed")
+		}
+	})
+	pool.Start()
+	defer pool.Stop()
+
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("poison", 1, 1)))
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("good", 2, 2)))
+	require.Eventually(t, func() bool {
+		status := pool.Status()
+		return len(status.Workers) == 1 && status.Workers[0].Processed == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// The panic came after the save, but the event is only settled as failed.
+	q.mu.Lock()
+	assert.Equal(t, []string{"poison"}, q.nacked)
+	q.mu.Unlock()
+	assert.Equal(t, 1.0, counter(t, m, "vfc_events_failed_total"))
+	assert.Equal(t, 1.0, counter(t, m, "vfc_events_processed_total"))
+	assert.Equal(t, int64(1), pool.Status().Workers[0].Failed)
+}
+
+func TestWorkerPoolBacksOffOnRepeatedPanics(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+	q := queue.NewInMemoryQueue(10, logger)
+	pool := worker.NewPool(1, q, panickingRepository{repository.NewInMemoryRepository()}, logger)
+	pool.RestartBackoff(10*time.Millisecond, 40*time.Millisecond)
+	pool.Start()
+	defer pool.Stop()
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("poison", 1, 1)))
+	}
+
+	require.Eventually(t, func() bool {
+		return logs.FilterMessage("Restarting worker").Len() == 4
+	}, 2*time.Second, 10*time.Millisecond)
+
+	var backoffs []time.Duration
+	for _, entry := range logs.FilterMessage("Restarting worker").All() {
+		backoffs = append(backoffs, entry.ContextMap()["backoff"].(time.Duration))
+	}
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond}, backoffs)
+}
+
+func TestWorkerPoolDrainSkipsRestartBackoff(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+	repo := repository.NewInMemoryRepository()
+	q := queue.NewInMemoryQueue(10, logger)
+	pool := worker.NewPool(1, q, panickingRepository{repo}, logger)
+	pool.RestartBackoff(time.Hour, time.Hour)
+	pool.Start()
+
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("poison", 1, 1)))
+	require.Eventually(t, func() bool {
+		return logs.FilterMessage("Restarting worker").Len() == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("good", 2, 2)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, pool.Drain(ctx))
+	assert.Equal(t, 1, repo.Count())
+}
+
+// slowRepository takes delay for every save, or gives up when ctx is done.
+type slowRepository struct {
+	*repository.InMemoryRepository
+	delay time.Duration
+}
+
+func (r slowRepository) Save(ctx context.Context, product *domain.Product) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(r.delay):
+	}
+	return r.InMemoryRepository.Save(ctx, product)
+}
+
+func TestWorkerPoolDrainsQueue(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := repository.NewInMemoryRepository()
+	q := queue.NewInMemoryQueue(10, logger)
+	pool := worker.NewPool(2, q, repo, logger)
+	pool.Start()
+
+	// Pausing lets the queue fill up as if the workers had fallen behind.
+	pool.Pause()
+	for i := 0; i < 8; i++ {
+		require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent(fmt.Sprintf("p%d", i), 1, 1)))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, pool.Drain(ctx))
+
+	assert.Equal(t, 8, repo.Count())
+	assert.Equal(t, 0, q.Len())
+	assert.Equal(t, 0, pool.Running())
+	assert.ErrorIs(t, pool.Resize(3), worker.ErrPoolStopped)
+}
+
+func TestWorkerPoolDrainStopsAtDeadline(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := repository.NewInMemoryRepository()
+	q := queue.NewInMemoryQueue(10, logger)
+	pool := worker.NewPool(1, q, slowRepository{repo, 50 * time.Millisecond}, logger)
+	pool.Pause()
+	pool.Start()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent(fmt.Sprintf("p%d", i), 1, 1)))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, pool.Drain(ctx), context.DeadlineExceeded)
+
+	// The event in progress at the deadline is abandoned; the rest stay
+	// queued.
+	remaining := q.Flush()
+	abandoned := pool.Abandoned()
+	assert.NotEmpty(t, remaining)
+	assert.Len(t, abandoned, 1)
+	assert.Equal(t, 10, repo.Count()+len(remaining)+len(abandoned))
+}
+
+func TestWorkerPoolTimesOutSlowEvents(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := repository.NewInMemoryRepository()
+	q := queue.NewInMemoryQueue(10, logger)
+	pool := worker.NewPool(1, q, slowRepository{repo, time.Hour}, logger)
+	pool.EventTimeout(20 * time.Millisecond)
+	pool.Start()
+	defer pool.Stop()
+
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("product-1", 10, 1)))
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("product-2", 20, 2)))
+
+	// Neither save can finish, but the worker moves on from each in time.
+	require.Eventually(t, func() bool {
+		return pool.Status().Workers[0].Failed == 2
+	}, time.Second, 5*time.Millisecond)
+
+	last := pool.Status().Workers[0].LastEvent
+	require.NotNil(t, last)
+	assert.Equal(t, "product-2", last.ProductID)
+	assert.Contains(t, last.Error, context.DeadlineExceeded.Error())
+	assert.Equal(t, 0, repo.Count())
+}
+
+func TestWorkerPoolCoalescesUpdates(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := repository.NewInMemoryRepository()
+	q := queue.NewInMemoryQueue(50, logger)
+	m := metrics.New()
+	coalescer := queue.NewCoalescer(q, 100*time.Millisecond, logger)
+	coalescer.OnCoalesce(m)
+	pool := worker.NewPool(1, coalescer, repo, logger)
+	pool.Observe(m)
+	coalescer.Start()
+	pool.Start()
+
+	for i := 1; i <= 20; i++ {
+		require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("product-1", float64(i), i)))
+	}
+
+	require.Eventually(t, func() bool {
+		product, err := repo.Get(context.Background(), "product-1")
+		return err == nil && product.Price == 20
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, coalescer.Stop(context.Background()))
+	require.NoError(t, pool.Drain(context.Background()))
+
+	saved := counter(t, m, "vfc_events_processed_total")
+	assert.Less(t, saved, 20.0)
+	assert.Equal(t, 20-saved, counter(t, m, "vfc_events_coalesced_total"))
+}
+
+// recordingRepository takes delay to save slowPrice and records the order
+// in which saves finish.
+type recordingRepository struct {
+	*repository.InMemoryRepository
+	slowPrice float64
+	delay     time.Duration
+	mu        sync.Mutex
+	saved     []float64
+}
+
+func (r *recordingRepository) Save(ctx context.Context, product *domain.Product) error {
+	if product.Price == r.slowPrice {
+		time.Sleep(r.delay)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.saved = append(r.saved, product.Price)
+	return r.InMemoryRepository.Save(ctx, product)
+}
+
+func TestWorkerPoolCoalescerOrdersSlowSaves(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := &recordingRepository{InMemoryRepository: repository.NewInMemoryRepository(), slowPrice: 1, delay: 200 * time.Millisecond}
+	q := queue.NewInMemoryQueue(10, logger)
+	coalescer := queue.NewCoalescer(q, 10*time.Millisecond, logger)
+	pool := worker.NewPool(2, coalescer, repo, logger)
+	coalescer.Start()
+	pool.Start()
+
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("product-1", 1, 1)))
+	require.Eventually(t, func() bool {
+		status := pool.Status()
+		for _, w := range status.Workers {
+			if w.State == worker.StateProcessing {
+				return true
+			}
+		}
+		return false
+	}, time.Second, time.Millisecond)
+
+	// The newer update must not be saved by the idle worker while the
+	// older one is still being saved.
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("product-1", 2, 2)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.Eventually(t, func() bool {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+		return len(repo.saved) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+	require.NoError(t, coalescer.Stop(ctx))
+	require.NoError(t, pool.Drain(ctx))
+
+	assert.Equal(t, []float64{1, 2}, repo.saved)
+	product, err := repo.Get(context.Background(), "product-1")
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, product.Price)
+}
+
+func TestWorkerPoolDrainsCoalescedEvents(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := repository.NewInMemoryRepository()
+	q := queue.NewInMemoryQueue(10, logger)
+	coalescer := queue.NewCoalescer(q, time.Hour, logger)
+	pool := worker.NewPool(2, coalescer, repo, logger)
+	coalescer.Start()
+	pool.Start()
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent(fmt.Sprintf("p%d", i), 1, 1)))
+	}
+
+	// Held events are handed to the workers at shutdown rather than lost.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, coalescer.Stop(ctx))
+	require.NoError(t, pool.Drain(ctx))
+
+	assert.Equal(t, 5, repo.Count())
+	assert.Empty(t, coalescer.Flush())
+}
+
+func TestWorkerPoolNumbersChangesWithoutOutbox(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := repository.NewInMemoryRepository()
+	q := queue.NewInMemoryQueue(10, logger)
+	pool := worker.NewPool(1, q, repo, logger)
+
+	var mu sync.Mutex
+	var first, second []uint64
+	pool.OnSave(func(change *domain.ProductChange) {
+		mu.Lock()
+		defer mu.Unlock()
+		first = append(first, change.ID)
+	})
+	pool.OnSave(func(change *domain.ProductChange) {
+		mu.Lock()
+		defer mu.Unlock()
+		second = append(second, change.ID)
+	})
+	pool.Start()
+
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("product-1", 10, 1)))
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("product-1", 20, 2)))
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(second) == 2
+	}, time.Second, 5*time.Millisecond)
+	pool.Stop()
+
+	// Every hook sees the same ID for a change, and no two changes share one.
+	assert.Equal(t, first, second)
+	assert.NotZero(t, first[0])
+	assert.NotEqual(t, first[0], first[1])
+}
+
+func TestWorkerPoolWritesOutbox(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := repository.NewInMemoryRepository()
+	q := queue.NewInMemoryQueue(10, logger)
+	pool := worker.NewPool(1, q, repo, logger)
+	pool.UseOutbox(repo)
+
+	pool.Start()
+
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("product-1", 10, 1)))
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("product-1", 20, 2)))
+
+	time.Sleep(200 * time.Millisecond)
+	pool.Stop()
+
+	changes, err := repo.PendingOutbox(context.Background(), 0)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, domain.ChangeCreated, changes[0].Type)
+	assert.Equal(t, domain.ChangeUpdated, changes[1].Type)
+	assert.Equal(t, 20.0, changes[1].Product.Price)
+}
+
+func TestWorkerPoolReportsMetrics(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	repo := repository.NewInMemoryRepository()
+	q := queue.NewInMemoryQueue(10, logger)
+	m := metrics.New()
+	m.WatchQueue(q.Len, q.Cap)
+	m.WatchRepository(repo.Count)
+	pool := worker.NewPool(1, q, repo, logger)
+	pool.Observe(m)
+
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("product-1", 10, 1)))
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("product-2", 20, 2)))
+	assert.Equal(t, 2.0, gauge(t, m, "vfc_queue_depth"))
+
+	pool.Start()
+	time.Sleep(200 * time.Millisecond)
+	pool.Stop()
+
+	families, err := m.Registry().Gather()
+	require.NoError(t, err)
+	for _, f := range families {
+		switch f.GetName() {
+		case "vfc_events_processed_total":
+			require.Len(t, f.GetMetric(), 1)
+			assert.Equal(t, 2.0, f.GetMetric()[0].GetCounter().GetValue())
+		case "vfc_event_processing_latency_seconds":
+			assert.Equal(t, uint64(2), f.GetMetric()[0].GetHistogram().GetSampleCount())
+		}
+	}
+	assert.Equal(t, 0.0, gauge(t, m, "vfc_queue_depth"))
+	assert.Equal(t, 10.0, gauge(t, m, "vfc_queue_capacity"))
+	assert.Equal(t, 2.0, gauge(t, m, "vfc_repository_products"))
+}
+
+// latencyRecorder keeps the latencies reported for processed events.
+type latencyRecorder struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+}
+
+func (r *latencyRecorder) ObserveEvent(workerID int, event *domain.Event, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencies = append(r.latencies, latency)
+}
+
+func TestWorkerPoolLatencyEndsAtSave(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	q := queue.NewInMemoryQueue(10, logger)
+	pool := worker.NewPool(1, q, repository.NewInMemoryRepository(), logger)
+	var recorder latencyRecorder
+	pool.Observe(&recorder)
+	pool.OnSave(func(change *domain.ProductChange) {
+		time.Sleep(300 * time.Millisecond)
+	})
+	pool.Start()
+	defer pool.Stop()
+
+	require.NoError(t, q.Enqueue(context.Background(), domain.NewEvent("product-1", 10, 1)))
+	require.Eventually(t, func() bool {
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		return len(recorder.latencies) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// Slow save hooks, such as webhook fan-out, do not count as latency.
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	assert.Positive(t, recorder.latencies[0])
+	assert.Less(t, recorder.latencies[0], 300*time.Millisecond)
+}
+
+func counter(t *testing.T, m *metrics.Metrics, name string) float64 {
+	t.Helper()
+
+	families, err := m.Registry().Gather()
+	require.NoError(t, err)
+	for _, f := range families {
+		if f.GetName() == name {
+			return f.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	t.Fatalf("metric %s not found", name)
+	return 0
+}
+
+func gauge(t *testing.T, m *metrics.Metrics, name string) float64 {
+	t.Helper()
+
+	families, err := m.Registry().Gather()
+	require.NoError(t, err)
+	for _, f := range families {
+		if f.GetName() == name {
+			return f.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	t.Fatalf("metric %s not found", name)
+	return 0
+}
+
+func TestWorkerLogsCarryRequestID(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+	repo := repository.NewInMemoryRepository()
+	q := queue.NewInMemoryQueue(10, logger)
+	pool := worker.NewPool(1, q, repo, logger)
+
+	event := domain.NewEvent("product-1", 10, 1)
+	event.RequestID = "req-123"
+	require.NoError(t, q.Enqueue(context.Background(), event))
+
+	pool.Start()
+	time.Sleep(100 * time.Millisecond)
+	pool.Stop()
+
+	entries := logs.FilterField(zap.String("product_id", "product-1")).All()
+	require.Len(t, entries, 2)
+	for _, e := range entries {
+		assert.Equal(t, "req-123", e.ContextMap()["request_id"], e.Message)
+	}
+}